@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+
+	"ninoai/pkg/bot"
+	"ninoai/pkg/classifier"
+	"ninoai/pkg/config"
+	"ninoai/pkg/embedding"
+	"ninoai/pkg/eval"
+)
+
+// runEvalClassifier implements `ninoai eval-classifier`, which runs a
+// labelled dataset through the classifier and reports how well the reply and
+// task decisions match, to tune labels and thresholds by. With -replay it
+// runs offline, from answers saved by an earlier run with -record.
+func runEvalClassifier(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("eval-classifier", flag.ExitOnError)
+	datasetPath := flags.String("dataset", "eval/classifier.jsonl", "JSONL file of labelled messages")
+	replayPath := flags.String("replay", "", "answer from this recording instead of calling any classifier")
+	recordPath := flags.String("record", "", "append the classifier's answers to this recording")
+	only := flags.String("classifier", "", "evaluate this classifier (hf, embedding or llm) instead of the configured chain")
+	guildID := flags.String("guild", "", "evaluate the reply triggers of this guild")
+	channelID := flags.String("channel", "", "evaluate the reply triggers of this channel")
+	step := flags.Float64("step", eval.DefaultStep, "distance between the thresholds of the sweeps")
+	flags.Parse(args)
+
+	if *replayPath != "" && (*recordPath != "" || *only != "") {
+		log.Fatal("-replay can't be combined with -record or -classifier")
+	}
+
+	examples, err := eval.LoadDataset(*datasetPath)
+	if err != nil {
+		log.Fatalf("Failed to load dataset: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var cl bot.Classifier
+	if *replayPath != "" {
+		replay, err := classifier.LoadReplay(*replayPath)
+		if err != nil {
+			log.Fatalf("Failed to load recording: %v", err)
+		}
+		cl = replay
+	} else {
+		cl = liveClassifier(cfg, *only)
+		if *recordPath != "" {
+			recorder, err := classifier.NewRecorder(cl, *recordPath)
+			if err != nil {
+				log.Fatalf("Failed to start recording: %v", err)
+			}
+			defer recorder.Close()
+			cl = recorder
+		}
+	}
+
+	policy := replyPolicies(cfg).Resolve(*guildID, *channelID)
+	log.Printf("Evaluating %d examples", len(examples))
+	report, err := eval.Run(ctx, cl, policy, examples, *step)
+	if err != nil {
+		log.Fatalf("Evaluation failed: %v", err)
+	}
+	if err := report.Write(os.Stdout); err != nil {
+		log.Fatalf("Failed to write report: %v", err)
+	}
+}
+
+// liveClassifier builds the configured classifier chain, or only the named
+// classifier.
+func liveClassifier(cfg *config.Config, only string) bot.Classifier {
+	if only != "" {
+		switch only {
+		case config.ClassifierHF, config.ClassifierEmbedding, config.ClassifierLLM:
+		default:
+			log.Fatalf("Unknown classifier %q (want hf, embedding or llm)", only)
+		}
+		cfg.Classifier.Chain = []string{only}
+	}
+
+	// Only ask for the credentials of the classifiers in use
+	var embedder classifier.Embedder
+	if slices.Contains(cfg.Classifier.Chain, config.ClassifierEmbedding) {
+		embeddingAPI, embeddingModel := newEmbeddingAPI(cfg)
+		dimensions, err := embedding.Dimensions(context.Background(), embeddingAPI, cfg.Embedding.Dimensions, 0)
+		if err != nil {
+			log.Fatalf("Failed to determine embedding dimensions: %v", err)
+		}
+		embeddingCache := newEmbeddingCache(cfg, embeddingModel, dimensions)
+		embedder = embedding.NewCachedClient(embeddingAPI, embeddingCache)
+	}
+	var llm classifier.JSONCompleter
+	if slices.Contains(cfg.Classifier.Chain, config.ClassifierLLM) {
+		llm = newLLMClient(cfg)
+	}
+	return buildClassifier(cfg, embedder, llm)
+}
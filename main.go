@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"ninoai/pkg/bot"
 	"ninoai/pkg/cerebras"
@@ -9,10 +12,13 @@ import (
 	"ninoai/pkg/embedding"
 	"ninoai/pkg/memory"
 	"ninoai/pkg/surreal"
+	"ninoai/pkg/usage"
 
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
+	_ "time/tzdata" // The current_time tool needs timezones the image may lack
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/joho/godotenv"
@@ -30,65 +36,80 @@ func main() {
 		log.Println("No .env file found, relying on environment variables")
 	}
 
-	token := os.Getenv("DISCORD_TOKEN")
-	cerebrasKey := os.Getenv("CEREBRAS_API_KEY")
-	embeddingKey := os.Getenv("EMBEDDING_API_KEY")
-	hfKey := os.Getenv("HF_API_KEY")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "reembed":
+			runReembed(cfg, os.Args[2:])
+			return
+		case "eval-classifier":
+			runEvalClassifier(cfg, os.Args[2:])
+			return
+		}
+	}
 
-	// Check each required environment variable individually for better error messages
+	token := os.Getenv("DISCORD_TOKEN")
 	if token == "" {
 		log.Fatal("Missing required environment variable: DISCORD_TOKEN")
 	}
-	if cerebrasKey == "" {
-		log.Fatal("Missing required environment variable: CEREBRAS_API_KEY")
-	}
-	if embeddingKey == "" {
-		log.Fatal("Missing required environment variable: EMBEDDING_API_KEY")
-	}
-	if hfKey == "" {
-		log.Fatal("Missing required environment variable: HF_API_KEY")
-	}
 
-	embeddingURL := os.Getenv("EMBEDDING_API_URL")
-	if embeddingURL == "" {
-		embeddingURL = "https://vector.mishl.dev/embed"
-	}
 	// Initialize Clients
-	cerebrasClient := cerebras.NewClient(cerebrasKey, cfg.ModelSettings.Temperature, cfg.ModelSettings.TopP)
-	embeddingClient := embedding.NewClient(embeddingKey, embeddingURL)
-	classifierClient := classifier.NewClient(hfKey)
+	cerebrasClient := newLLMClient(cfg)
+	usageTracker := usage.NewTracker("storage/usage.json")
+	cerebrasClient.SetUsageRecorder(usageTracker)
+	embeddingAPI, embeddingModel := newEmbeddingAPI(cfg)
 
 	// Initialize Memory Store (SurrealDB)
-	surrealHost := os.Getenv("SURREAL_DB_HOST")
-	surrealUser := os.Getenv("SURREAL_DB_USER")
-	surrealPass := os.Getenv("SURREAL_DB_PASS")
+	surrealClient := connectSurreal(cfg)
+	defer surrealClient.Close()
 
-	if surrealHost == "" {
-		log.Fatal("Missing required environment variable: SURREAL_DB_HOST")
-	}
-	if surrealUser == "" {
-		log.Fatal("Missing required environment variable: SURREAL_DB_USER")
+	// The memories table only accepts vectors of the embedding model's length
+	memoryStore := memory.NewSurrealStore(surrealClient)
+	memoryStore.SetRanking(memoryRanking(cfg))
+	schemaDimensions, err := memoryStore.SchemaDimensions(context.Background())
+	if err != nil {
+		log.Printf("Warning: Failed to read the memories schema: %v", err)
 	}
-	if surrealPass == "" {
-		log.Fatal("Missing required environment variable: SURREAL_DB_PASS")
+	// Probe the API directly, as a cached vector may come from a previous model
+	dimensions, err := embedding.Dimensions(context.Background(), embeddingAPI, cfg.Embedding.Dimensions, schemaDimensions)
+	if err != nil {
+		log.Fatalf("Failed to determine embedding dimensions: %v", err)
 	}
-
-	// Add protocol if missing
-	if len(surrealHost) > 0 && surrealHost[:4] != "ws://" && surrealHost[:5] != "wss://" {
-		surrealHost = "wss://" + surrealHost + "/rpc"
+	log.Printf("Using %d-dimensional embeddings", dimensions)
+	if err := memoryStore.Init(context.Background(), dimensions); err != nil {
+		var mismatch *memory.DimensionMismatchError
+		if errors.As(err, &mismatch) {
+			log.Fatalf("Embedding dimensions do not match the database: %v", err)
+		}
+		// The DB might be reachable later, or the schema already exists
+		log.Printf("Warning: Failed to initialize SurrealDB schema: %v", err)
 	}
 
-	log.Printf("Connecting to SurrealDB at %s", surrealHost)
-	surrealClient, err := surreal.NewClient(surrealHost, surrealUser, surrealPass, "nino", "memory")
-	if err != nil {
-		log.Fatalf("Failed to connect to SurrealDB: %v", err)
+	embeddingCache := newEmbeddingCache(cfg, embeddingModel, dimensions)
+	cachedEmbedding := embedding.NewCachedClient(embeddingAPI, embeddingCache)
+	var embeddingClient bot.EmbeddingClient = cachedEmbedding
+	if cfg.Embedding.LocalFallback {
+		// Same length as the API's vectors, which the memories table requires
+		embeddingClient = embedding.NewFallbackClient(embeddingClient, embedding.NewLocalEmbedder(dimensions))
 	}
-	defer surrealClient.Close()
-
-	memoryStore := memory.NewSurrealStore(surrealClient)
+	// Without the local fallback, so that messages and labels are embedded alike
+	classifierClient := buildClassifier(cfg, cachedEmbedding, cerebrasClient)
 
 	// Initialize Bot Handler
 	handler := bot.NewHandler(cerebrasClient, classifierClient, embeddingClient, memoryStore, cfg.Delays.MessageProcessing)
+	handler.SetUsageTracker(usageTracker)
+	handler.SetMemoryQueue(memory.NewEmbedQueue("storage/embed_queue.json"), config.Seconds(cfg.Embedding.RetryInterval))
+	handler.SetBudget(usage.Budget{
+		Global: usage.Limits{Daily: cfg.Budgets.Global.Daily, Hourly: cfg.Budgets.Global.Hourly},
+		Guild:  usage.Limits{Daily: cfg.Budgets.Guild.Daily, Hourly: cfg.Budgets.Guild.Hourly},
+		User:   usage.Limits{Daily: cfg.Budgets.User.Daily, Hourly: cfg.Budgets.User.Hourly},
+	})
+	handler.SetReplyPolicies(replyPolicies(cfg))
+	if cfg.Streaming.Enabled {
+		handler.EnableStreaming(config.Seconds(cfg.Streaming.EditInterval))
+	}
+	if cfg.Tools.Enabled {
+		handler.EnableTools(cfg.Tools.MaxIterations)
+	}
 
 	// Create Discord Session
 	dg, err := discordgo.New("Bot " + token)
@@ -144,10 +165,215 @@ func main() {
 		log.Printf("Error setting custom status: %v", err)
 	}
 
+	// Periodically report models that are cooling down
+	go logModelHealth(cerebrasClient, 5*time.Minute)
+
 	// Wait for signal
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
+	// Stop receiving events first, then let in-flight work and background
+	// memory updates finish before aborting what is left.
+	log.Println("Shutting down...")
 	dg.Close()
+	handler.Shutdown()
+	usageTracker.Flush()
+}
+
+// newLLMClient creates the client for the configured LLM providers.
+func newLLMClient(cfg *config.Config) *cerebras.Client {
+	models, err := buildModelChain(cfg.Providers)
+	if err != nil {
+		log.Fatalf("Failed to configure LLM providers: %v", err)
+	}
+	return cerebras.NewClient(models, cfg.ModelSettings.Temperature, cfg.ModelSettings.TopP, config.Seconds(*cfg.Timeouts.LLM))
+}
+
+// newEmbeddingAPI creates the embedding API client from the environment. It
+// also returns the name of the model, which keys the embedding cache.
+func newEmbeddingAPI(cfg *config.Config) (*embedding.Client, string) {
+	embeddingKey := os.Getenv("EMBEDDING_API_KEY")
+	if embeddingKey == "" {
+		log.Fatal("Missing required environment variable: EMBEDDING_API_KEY")
+	}
+	embeddingURL := os.Getenv("EMBEDDING_API_URL")
+	if embeddingURL == "" {
+		embeddingURL = "https://vector.mishl.dev/embed"
+	}
+
+	client := embedding.NewClient(embeddingKey, embeddingURL, config.Seconds(*cfg.Timeouts.Embedding))
+	client.SetBatchSize(cfg.Embedding.BatchSize)
+	model := cfg.Embedding.Model
+	if model == "" {
+		model = embeddingURL
+	}
+	return client, model
+}
+
+// newEmbeddingCache creates the embedding cache for vectors of the given
+// dimensions, and removes the ones on disk that expired in the background.
+func newEmbeddingCache(cfg *config.Config, model string, dimensions int) *embedding.Cache {
+	cache := embedding.NewCache(model, dimensions, cfg.Embedding.CacheSize, cfg.Embedding.CacheDir)
+	cache.SetMaxAge(time.Duration(cfg.Embedding.CacheMaxAge * float64(24*time.Hour)))
+	go func() {
+		n, err := cache.Prune()
+		if err != nil {
+			log.Printf("Error pruning the embedding cache: %v", err)
+		} else if n > 0 {
+			log.Printf("Removed %d expired embeddings from the cache", n)
+		}
+	}()
+	return cache
+}
+
+// buildClassifier chains the classifiers listed in the config.
+func buildClassifier(cfg *config.Config, embedder classifier.Embedder, llm classifier.JSONCompleter) bot.Classifier {
+	var chain []classifier.Named
+	for _, name := range cfg.Classifier.Chain {
+		var c classifier.Classifier
+		switch name {
+		case config.ClassifierHF:
+			hfKey := os.Getenv("HF_API_KEY")
+			if hfKey == "" {
+				log.Fatal("Missing required environment variable: HF_API_KEY")
+			}
+			hfClassifier := classifier.NewClient(hfKey, config.Seconds(*cfg.Timeouts.Classifier))
+			hfClassifier.SetMultiLabel(cfg.Classifier.MultiLabel)
+			c = hfClassifier
+		case config.ClassifierEmbedding:
+			c = classifier.NewEmbeddingClassifier(embedder)
+		case config.ClassifierLLM:
+			llmClassifier := classifier.NewLLMClassifier(llm)
+			llmClassifier.SetMultiLabel(cfg.Classifier.MultiLabel)
+			c = llmClassifier
+		}
+		chain = append(chain, classifier.Named{Name: name, Classifier: c})
+	}
+	return classifier.NewChain(chain...)
+}
+
+// replyPolicies converts the reply section of the config.
+func replyPolicies(cfg *config.Config) bot.ReplyPolicies {
+	convert := func(p config.ReplyPolicy) bot.ReplyPolicy {
+		policy := bot.ReplyPolicy{Mode: p.Mode, Probability: p.Probability}
+		for _, t := range p.Triggers {
+			policy.Triggers = append(policy.Triggers, bot.ReplyTrigger{Label: t.Label, Threshold: t.Threshold})
+		}
+		return policy
+	}
+
+	policies := bot.ReplyPolicies{
+		Default:  convert(cfg.Reply.ReplyPolicy),
+		Guilds:   make(map[string]bot.ReplyPolicy, len(cfg.Reply.Guilds)),
+		Channels: make(map[string]bot.ReplyPolicy, len(cfg.Reply.Channels)),
+	}
+	for id, p := range cfg.Reply.Guilds {
+		policies.Guilds[id] = convert(p)
+	}
+	for id, p := range cfg.Reply.Channels {
+		policies.Channels[id] = convert(p)
+	}
+	return policies
+}
+
+// memoryRanking converts the retrieval section of the config.
+func memoryRanking(cfg *config.Config) memory.Ranking {
+	r := cfg.Retrieval
+	return memory.Ranking{
+		Similarity:    r.Weights.Similarity,
+		Keyword:       r.Weights.Keyword,
+		Recency:       r.Weights.Recency,
+		Importance:    r.Weights.Importance,
+		HalfLife:      time.Duration(r.HalfLife * float64(24*time.Hour)),
+		MinSimilarity: *r.MinSimilarity,
+	}
+}
+
+// connectSurreal connects to the SurrealDB instance named in the environment.
+func connectSurreal(cfg *config.Config) *surreal.Client {
+	surrealHost := os.Getenv("SURREAL_DB_HOST")
+	surrealUser := os.Getenv("SURREAL_DB_USER")
+	surrealPass := os.Getenv("SURREAL_DB_PASS")
+
+	if surrealHost == "" {
+		log.Fatal("Missing required environment variable: SURREAL_DB_HOST")
+	}
+	if surrealUser == "" {
+		log.Fatal("Missing required environment variable: SURREAL_DB_USER")
+	}
+	if surrealPass == "" {
+		log.Fatal("Missing required environment variable: SURREAL_DB_PASS")
+	}
+
+	// Add protocol if missing
+	if len(surrealHost) > 0 && surrealHost[:4] != "ws://" && surrealHost[:5] != "wss://" {
+		surrealHost = "wss://" + surrealHost + "/rpc"
+	}
+
+	log.Printf("Connecting to SurrealDB at %s", surrealHost)
+	dbTimeout := config.Seconds(*cfg.Timeouts.Database)
+	connectCtx := context.Background()
+	if dbTimeout > 0 {
+		var cancelConnect context.CancelFunc
+		connectCtx, cancelConnect = context.WithTimeout(connectCtx, dbTimeout)
+		defer cancelConnect()
+	}
+	surrealClient, err := surreal.NewClient(connectCtx, surrealHost, surrealUser, surrealPass, "nino", "memory", dbTimeout)
+	if err != nil {
+		log.Fatalf("Failed to connect to SurrealDB: %v", err)
+	}
+	return surrealClient
+}
+
+// buildModelChain turns the configured providers into the LLM fallback chain,
+// skipping disabled models.
+func buildModelChain(providers []config.ProviderConfig) ([]cerebras.Model, error) {
+	var models []cerebras.Model
+	for _, pc := range providers {
+		var apiKey string
+		if pc.APIKeyEnv != "" {
+			apiKey = os.Getenv(pc.APIKeyEnv)
+			if apiKey == "" {
+				return nil, fmt.Errorf("missing required environment variable %s for provider %s", pc.APIKeyEnv, pc.Name)
+			}
+		}
+
+		provider, err := cerebras.NewProvider(pc.Type, pc.Name, pc.BaseURL, apiKey)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mc := range pc.Models {
+			if !mc.IsEnabled() {
+				log.Printf("Model %s/%s is disabled, skipping", pc.Name, mc.ID)
+				continue
+			}
+			models = append(models, cerebras.Model{
+				ID:          mc.ID,
+				MaxCtx:      mc.MaxCtx,
+				MaxTokens:   mc.MaxTokens,
+				Temperature: mc.Temperature,
+				Provider:    provider,
+			})
+		}
+	}
+
+	return models, nil
+}
+
+// logModelHealth logs every model whose circuit breaker is not closed.
+func logModelHealth(client *cerebras.Client, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		for _, status := range client.ModelHealth() {
+			if status.State == cerebras.BreakerClosed {
+				continue
+			}
+			log.Printf("Model health: %s/%s is %s (failures: %d, cooldown until: %s, last error: %s)",
+				status.Provider, status.ID, status.State, status.ConsecutiveFailures, status.CooldownUntil.Format(time.RFC3339), status.LastError)
+		}
+	}
 }
@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"context"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"ninoai/pkg/classifier"
+)
+
+// analysisCacheSize is how many analyses are kept. A message is only
+// analysed while it is handled, so this only needs to cover the messages
+// being handled at once.
+const analysisCacheSize = 256
+
+// splitTolerance is how far from 1 the scores of a single-label
+// classification may sum, allowing for rounding
+const splitTolerance = 0.01
+
+// Analysis is what a single classification says about a message: every
+// decision made about it reads from the same scores.
+type Analysis struct {
+	Results []classifier.ClassificationResult // Every label's score, highest first; see Analyze
+	Topic   classifier.ClassificationResult   // Best scoring reply trigger label
+	Task    classifier.ClassificationResult   // Best scoring label of the task group
+}
+
+// IsTask reports whether the message asks for work Nino refuses to do.
+func (a *Analysis) IsTask() bool {
+	return IsTask(a.Task.Label, a.Task.Score, TaskThreshold)
+}
+
+// AnalysisLabels returns the labels messages under policy are classified
+// with: its reply triggers, then the task group.
+func AnalysisLabels(policy ReplyPolicy) []string {
+	labels := policy.Labels()
+	for _, label := range append([]string{chatLabel}, taskLabels...) {
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// Analyze classifies text once with the labels of every decision. The
+// labels share one call, so a single-label classifier splits one unit of
+// probability between the reply triggers and the task labels; the scores
+// are rescaled per group to what separate calls would have given (see
+// splitByGroup), so the thresholds keep their meaning.
+func Analyze(ctx context.Context, cl Classifier, text string, policy ReplyPolicy) (*Analysis, error) {
+	results, err := cl.ClassifyAll(ctx, text, AnalysisLabels(policy))
+	if err != nil {
+		return nil, err
+	}
+
+	a := &Analysis{Results: splitByGroup(results)}
+	topicSet, taskSet := false, false
+	for _, r := range a.Results {
+		// Results are sorted, so the first of each kind scores highest
+		if inTaskGroup(r.Label) {
+			if !taskSet {
+				a.Task, taskSet = r, true
+			}
+		} else if !topicSet {
+			a.Topic, topicSet = r, true
+		}
+	}
+	return a, nil
+}
+
+// inTaskGroup reports whether label is scored with the task labels rather
+// than the reply triggers.
+func inTaskGroup(label string) bool {
+	return label == chatLabel || slices.Contains(taskLabels, label)
+}
+
+// splitByGroup rescales the scores of a single-label classification so that
+// the reply triggers and the task group each sum to 1. The classifiers
+// split with a softmax, so this gives the scores each group would have had
+// if classified on its own, which is what the thresholds are tuned for.
+// Multi-label scores, which don't sum to 1, are returned as they are.
+func splitByGroup(results []classifier.ClassificationResult) []classifier.ClassificationResult {
+	var total, tasks float64
+	for _, r := range results {
+		total += r.Score
+		if inTaskGroup(r.Label) {
+			tasks += r.Score
+		}
+	}
+	if math.Abs(total-1) > splitTolerance {
+		return results
+	}
+
+	scaled := make([]classifier.ClassificationResult, len(results))
+	for i, r := range results {
+		group := total - tasks
+		if inTaskGroup(r.Label) {
+			group = tasks
+		}
+		if group > 0 {
+			r.Score /= group
+		}
+		scaled[i] = r
+	}
+	sort.SliceStable(scaled, func(i, j int) bool { return scaled[i].Score > scaled[j].Score })
+	return scaled
+}
+
+// Analyzer shares the analysis of a message between the Handler and the
+// TaskAgent, so that each message is classified once.
+type Analyzer struct {
+	classifier Classifier
+	mu         sync.Mutex
+	cache      map[string]*Analysis // Message ID -> analysis
+	order      []string             // Cached message IDs, oldest first
+}
+
+func NewAnalyzer(cl Classifier) *Analyzer {
+	return &Analyzer{
+		classifier: cl,
+		cache:      make(map[string]*Analysis),
+	}
+}
+
+// Analyze returns the analysis of the message with messageID, classifying
+// text only the first time. Failures aren't cached, so a later call tries
+// again, and neither are messages without an ID.
+func (a *Analyzer) Analyze(ctx context.Context, messageID, text string, policy ReplyPolicy) (*Analysis, error) {
+	if messageID == "" {
+		return Analyze(ctx, a.classifier, text, policy)
+	}
+
+	a.mu.Lock()
+	cached, ok := a.cache[messageID]
+	a.mu.Unlock()
+	if ok {
+		return cached, nil
+	}
+
+	analysis, err := Analyze(ctx, a.classifier, text, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.cache[messageID]; !ok {
+		a.order = append(a.order, messageID)
+		if len(a.order) > analysisCacheSize {
+			delete(a.cache, a.order[0])
+			a.order = a.order[1:]
+		}
+	}
+	a.cache[messageID] = analysis
+	return analysis, nil
+}
@@ -0,0 +1,124 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"ninoai/pkg/classifier"
+)
+
+// fixedClassifier answers with the same scores for every text
+type fixedClassifier struct {
+	scores map[string]float64
+	err    error
+	calls  int
+}
+
+func (f *fixedClassifier) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := f.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (f *fixedClassifier) ClassifyAll(_ context.Context, _ string, labels []string) ([]classifier.ClassificationResult, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	var results []classifier.ClassificationResult
+	for _, label := range labels {
+		results = append(results, result(label, f.scores[label]))
+	}
+	// Highest first, like the real classifiers
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+func TestAnalyze(t *testing.T) {
+	const essay = "request for essay"
+	policy := ReplyPolicy{Triggers: []ReplyTrigger{{Label: "food", Threshold: 0.5}, {Label: "casual"}}}
+
+	if labels := AnalysisLabels(policy); len(labels) != 3+len(taskLabels) || labels[0] != "food" || labels[2] != chatLabel || labels[3] != taskLabels[0] {
+		t.Errorf("expected the triggers followed by the task group, got %v", labels)
+	}
+
+	tests := []struct {
+		name      string
+		scores    map[string]float64
+		wantTopic string
+		wantTask  bool
+	}{
+		{name: "Chat", scores: map[string]float64{"casual": 0.8, essay: 0.1}, wantTopic: "casual"},
+		{name: "Task", scores: map[string]float64{"casual": 0.2, essay: 0.7}, wantTopic: "casual", wantTask: true},
+		{name: "Unsure task", scores: map[string]float64{"food": 0.45, essay: 0.5}, wantTopic: "food"},
+		// A single-label classifier split these between both groups
+		{name: "Split task", scores: map[string]float64{"casual": 0.5, "food": 0.1, chatLabel: 0.1, essay: 0.3}, wantTopic: "casual", wantTask: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Analyze(context.Background(), &fixedClassifier{scores: tt.scores}, "text", policy)
+			if err != nil {
+				t.Fatalf("Analyze() error = %v", err)
+			}
+			if a.Topic.Label != tt.wantTopic || a.Task.Label != essay || a.IsTask() != tt.wantTask {
+				t.Errorf("got topic %q, task %q and IsTask %v; want %q, %q and %v", a.Topic.Label, a.Task.Label, a.IsTask(), tt.wantTopic, essay, tt.wantTask)
+			}
+		})
+	}
+}
+
+func TestSplitByGroup(t *testing.T) {
+	writing := taskLabels[0]
+	split := []classifier.ClassificationResult{result("casual", 0.45), result(writing, 0.3), result("food", 0.15), result(chatLabel, 0.1)}
+
+	got := splitByGroup(split)
+	want := map[string]float64{writing: 0.75, "casual": 0.75, "food": 0.25, chatLabel: 0.25}
+	for _, r := range got {
+		if math.Abs(r.Score-want[r.Label]) > 1e-9 {
+			t.Errorf("%s scored %v, want %v", r.Label, r.Score, want[r.Label])
+		}
+	}
+	if got[2].Score > got[1].Score {
+		t.Errorf("expected results to stay sorted, got %+v", got)
+	}
+
+	multi := []classifier.ClassificationResult{result("casual", 0.9), result(writing, 0.6)}
+	if got := splitByGroup(multi); got[0].Score != 0.9 || got[1].Score != 0.6 {
+		t.Errorf("expected multi-label scores to be left alone, got %+v", got)
+	}
+}
+
+func TestAnalyzer_Cache(t *testing.T) {
+	cl := &fixedClassifier{err: errors.New("api down")}
+	analyzer := NewAnalyzer(cl)
+	ctx := context.Background()
+	policy := ReplyPolicies{}.Resolve("", "")
+
+	if _, err := analyzer.Analyze(ctx, "msg", "hi", policy); err == nil {
+		t.Fatal("expected the classifier's error")
+	}
+	cl.err = nil
+	first, err := analyzer.Analyze(ctx, "msg", "hi", policy)
+	if err != nil {
+		t.Fatalf("expected a failure not to be cached, got %v", err)
+	}
+	if again, _ := analyzer.Analyze(ctx, "msg", "hi", policy); again != first || cl.calls != 2 {
+		t.Errorf("expected the cached analysis, got %d calls", cl.calls)
+	}
+
+	// Old messages make room for new ones
+	for i := range analysisCacheSize {
+		analyzer.Analyze(ctx, fmt.Sprint(i), "hi", policy)
+	}
+	calls := cl.calls
+	analyzer.Analyze(ctx, "msg", "hi", policy)
+	if cl.calls != calls+1 || len(analyzer.cache) != analysisCacheSize {
+		t.Errorf("expected the oldest analysis to be evicted, got %d cached", len(analyzer.cache))
+	}
+}
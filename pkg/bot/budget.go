@@ -0,0 +1,75 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"ninoai/pkg/usage"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Refusals sent instead of a reply once a token budget is used up
+const (
+	userDailyRefusal  = "ugh, I'm done talking to you today. come back tomorrow."
+	userHourlyRefusal = "you've been talking my ear off... give me a break and come back in a bit."
+	sharedRefusal     = "I'm exhausted, everyone's been nonstop today. later."
+)
+
+// SetBudget enforces token budgets on replies. It only takes effect together
+// with SetUsageTracker, which provides the spending it is checked against.
+func (h *Handler) SetBudget(b usage.Budget) {
+	h.budget = b
+}
+
+// overBudget returns the budget that keeps the user and guild ctx is
+// attributed to from calling the LLM, or nil if there is none.
+func (h *Handler) overBudget(ctx context.Context) *usage.BudgetError {
+	if h.usageTracker == nil {
+		return nil
+	}
+	a := usage.AttributionFrom(ctx)
+	var budgetErr *usage.BudgetError
+	if errors.As(h.usageTracker.CheckBudget(h.budget, a.UserID, a.GuildID), &budgetErr) {
+		return budgetErr
+	}
+	return nil
+}
+
+// withinBudget reports whether a reply to m may call the LLM. When a budget is
+// exhausted it sends an in-character refusal, once per user until the budget
+// resets, and returns false.
+func (h *Handler) withinBudget(ctx context.Context, s Session, m *discordgo.MessageCreate) bool {
+	budgetErr := h.overBudget(ctx)
+	if budgetErr == nil {
+		return true
+	}
+
+	h.budgetMu.Lock()
+	notified := time.Now().Before(h.budgetNotified[m.Author.ID])
+	if !notified {
+		h.budgetNotified[m.Author.ID] = budgetErr.ResetAt
+	}
+	h.budgetMu.Unlock()
+
+	if notified {
+		return false
+	}
+
+	log.Printf("Not replying to user %s: %v", m.Author.ID, budgetErr)
+	h.sendSplitMessage(s, m.ChannelID, budgetRefusal(budgetErr), m.Reference())
+	return false
+}
+
+// budgetRefusal returns what Nino says when err keeps her from calling the LLM.
+func budgetRefusal(err *usage.BudgetError) string {
+	if err.Scope != usage.ScopeUser {
+		return sharedRefusal
+	}
+	if err.Window == usage.WindowHourly {
+		return userHourlyRefusal
+	}
+	return userDailyRefusal
+}
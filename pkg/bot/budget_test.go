@@ -0,0 +1,116 @@
+package bot
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"ninoai/pkg/cerebras"
+	"ninoai/pkg/usage"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestHandler_RefusesOverBudget(t *testing.T) {
+	tracker := usage.NewTracker(filepath.Join(t.TempDir(), "usage.json"))
+	ctx := usage.WithAttribution(context.Background(), usage.Attribution{UserID: "user123", Kind: usage.KindReply})
+	tracker.RecordUsage(ctx, "cerebras/m", cerebras.Usage{PromptTokens: 900, CompletionTokens: 200})
+
+	llmCalls := 0
+	client := &mockCerebrasClient{
+		ChatCompletionFunc: func(messages []cerebras.Message) (string, error) {
+			llmCalls++
+			return "hmph.", nil
+		},
+	}
+
+	handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	handler.SetBotID("testbot")
+	handler.SetUsageTracker(tracker)
+	handler.SetBudget(usage.Budget{User: usage.Limits{Daily: 1000}})
+
+	session := &MockSession{}
+	message := func(userID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ChannelID: "test_channel",
+				Author:    &discordgo.User{ID: userID, Username: "testuser"},
+				Content:   "hey nino",
+				Mentions:  []*discordgo.User{{ID: "testbot"}},
+			},
+		}
+	}
+
+	// The refusal is only sent once until the budget resets
+	handler.HandleMessage(session, message("user123"))
+	handler.HandleMessage(session, message("user123"))
+	handler.WaitForReady()
+
+	if llmCalls != 0 {
+		t.Errorf("expected no LLM calls over budget, got %d", llmCalls)
+	}
+	if len(session.SentMessages) != 1 || session.SentMessages[0] != userDailyRefusal {
+		t.Fatalf("expected a single refusal, got %v", session.SentMessages)
+	}
+
+	// Other users still get replies
+	handler.HandleMessage(session, message("user456"))
+	handler.WaitForReady()
+	if llmCalls == 0 {
+		t.Error("expected a user within budget to get a reply")
+	}
+}
+
+func TestHandler_SpendsNothingOverBudget(t *testing.T) {
+	tracker := usage.NewTracker(filepath.Join(t.TempDir(), "usage.json"))
+	spend := func(userID string, tokens int) {
+		ctx := usage.WithAttribution(context.Background(), usage.Attribution{UserID: userID, Kind: usage.KindReply})
+		tracker.RecordUsage(ctx, "cerebras/m", cerebras.Usage{PromptTokens: tokens})
+	}
+	spend("user123", 1000)
+
+	var extractions int
+	client := &mockCerebrasClient{
+		// The reply uses up the rest of the budget
+		ChatCompletionFunc: func(messages []cerebras.Message) (string, error) {
+			spend("user456", 1000)
+			return "hmph.", nil
+		},
+		ChatCompletionJSONFunc: func(messages []cerebras.Message, schema *cerebras.JSONSchema) (string, error) {
+			if schema.Name == "memory_extraction" {
+				extractions++
+			}
+			return `{"memories": [], "emojis": []}`, nil
+		},
+	}
+	cl := &countingClassifier{}
+
+	handler := NewHandler(client, cl, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	handler.SetBotID("testbot")
+	handler.SetUsageTracker(tracker)
+	handler.SetBudget(usage.Budget{User: usage.Limits{Daily: 1000}})
+
+	// A message she might have answered isn't even classified
+	session := &MockSession{}
+	handler.HandleMessage(session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "untagged",
+		ChannelID: "test_channel",
+		Author:    &discordgo.User{ID: "user123", Username: "testuser"},
+		Content:   "Nino, what should I cook?",
+	}})
+	handler.WaitForReady()
+	if cl.calls != 0 || len(session.SentMessages) != 0 {
+		t.Errorf("expected no classification or refusal over budget, got %d calls and %v", cl.calls, session.SentMessages)
+	}
+
+	handler.HandleMessage(session, &discordgo.MessageCreate{Message: &discordgo.Message{
+		ChannelID: "test_channel",
+		Author:    &discordgo.User{ID: "user456", Username: "testuser"},
+		Content:   "I have a cat named Mochi",
+		Mentions:  []*discordgo.User{{ID: "testbot"}},
+	}})
+	handler.WaitForReady()
+	if len(session.SentMessages) != 1 || extractions != 0 {
+		t.Errorf("expected a reply without memory extraction, got %v and %d extractions", session.SentMessages, extractions)
+	}
+}
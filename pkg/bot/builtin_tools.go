@@ -0,0 +1,154 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// memorySearchLimit is how many memories search_memories returns
+	memorySearchLimit = 5
+	// maxReminderDelay is how far ahead a reminder can be set
+	maxReminderDelay = 7 * 24 * time.Hour
+	// maxPendingReminders caps the reminders a user can have waiting
+	maxPendingReminders = 5
+)
+
+// builtinTools returns the tools enabled by EnableTools.
+func (h *Handler) builtinTools() []Tool {
+	return []Tool{
+		{
+			Name:        "search_memories",
+			Description: "Search your long-term memories about the user you are talking to. Use it when they ask what you remember or refer to something they told you before.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"query": {"type": "string", "description": "What to look for, e.g. 'favorite food'"}},
+				"required": ["query"]
+			}`),
+			Run: h.searchMemoriesTool,
+		},
+		{
+			Name:        "set_reminder",
+			Description: "Remind the user about something later by mentioning them in this channel. Reminders are lost if you restart.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {
+					"minutes": {"type": "integer", "description": "Minutes from now, at most 10080 (one week)"},
+					"text": {"type": "string", "description": "What to remind them about"}
+				},
+				"required": ["minutes", "text"]
+			}`),
+			Run: h.setReminderTool,
+		},
+		{
+			Name:        "current_time",
+			Description: "Get the current date and time in a timezone, e.g. the user's if you know where they live.",
+			Parameters: json.RawMessage(`{
+				"type": "object",
+				"properties": {"timezone": {"type": "string", "description": "IANA timezone such as 'Asia/Tokyo'; defaults to UTC"}}
+			}`),
+			Run: currentTimeTool,
+		},
+	}
+}
+
+func (h *Handler) searchMemoriesTool(ctx context.Context, call ToolInvocation) (string, error) {
+	var args struct {
+		Query string `json:"query"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil || strings.TrimSpace(args.Query) == "" {
+		return "", errors.New("query is required")
+	}
+
+	emb, err := h.embeddingClient.Embed(ctx, args.Query)
+	if err != nil {
+		return "", fmt.Errorf("failed to embed query: %w", err)
+	}
+	matches, err := h.memoryStore.SearchMemories(ctx, call.Message.Author.ID, args.Query, emb, memorySearchLimit)
+	if err != nil {
+		return "", fmt.Errorf("failed to search memories: %w", err)
+	}
+	if len(matches) == 0 {
+		return "You don't remember anything about that.", nil
+	}
+	return formatMemories(matches, time.Now()), nil
+}
+
+// setReminderTool schedules a mention of the user. Reminders live in memory
+// only and are dropped on shutdown.
+func (h *Handler) setReminderTool(_ context.Context, call ToolInvocation) (string, error) {
+	var args struct {
+		Minutes int    `json:"minutes"`
+		Text    string `json:"text"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	delay := time.Duration(args.Minutes) * time.Minute
+	if delay <= 0 || delay > maxReminderDelay {
+		return "", fmt.Errorf("minutes must be between 1 and %d", int(maxReminderDelay.Minutes()))
+	}
+	if strings.TrimSpace(args.Text) == "" {
+		return "", errors.New("text is required")
+	}
+
+	userID := call.Message.Author.ID
+	h.remindersMu.Lock()
+	if h.pendingReminders[userID] >= maxPendingReminders {
+		h.remindersMu.Unlock()
+		return "", fmt.Errorf("the user already has %d reminders pending", maxPendingReminders)
+	}
+	h.pendingReminders[userID]++
+	h.remindersMu.Unlock()
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer func() {
+			h.remindersMu.Lock()
+			if h.pendingReminders[userID]--; h.pendingReminders[userID] <= 0 {
+				delete(h.pendingReminders, userID)
+			}
+			h.remindersMu.Unlock()
+		}()
+
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-h.stopping:
+			return
+		}
+
+		content := fmt.Sprintf("<@%s> hey, you asked me to remind you: %s", userID, args.Text)
+		if _, err := call.Session.ChannelMessageSend(call.Message.ChannelID, content); err != nil {
+			log.Printf("Error sending reminder: %v", err)
+		}
+	}()
+
+	at := time.Now().Add(delay).UTC().Format(time.RFC1123)
+	return fmt.Sprintf("Reminder set for %s.", at), nil
+}
+
+func currentTimeTool(_ context.Context, call ToolInvocation) (string, error) {
+	var args struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := json.Unmarshal(call.Arguments, &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.Timezone == "" {
+		args.Timezone = "UTC"
+	}
+
+	loc, err := time.LoadLocation(args.Timezone)
+	if err != nil {
+		return "", fmt.Errorf("unknown timezone %q", args.Timezone)
+	}
+	return time.Now().In(loc).Format("Monday, 2 January 2006, 15:04 MST"), nil
+}
@@ -1,33 +1,48 @@
 package bot
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"testing"
 
 	"ninoai/pkg/cerebras"
+	"ninoai/pkg/memory"
 
 	"github.com/bwmarrin/discordgo"
 )
 
 // Mock Cerebras Client
 type mockCerebrasClient struct {
-	ChatCompletionFunc func(messages []cerebras.Message) (string, error)
+	ChatCompletionFunc     func(messages []cerebras.Message) (string, error)
+	ChatCompletionJSONFunc func(messages []cerebras.Message, schema *cerebras.JSONSchema) (string, error)
 }
 
-func (m *mockCerebrasClient) ChatCompletion(messages []cerebras.Message) (string, error) {
+func (m *mockCerebrasClient) ChatCompletion(_ context.Context, messages []cerebras.Message) (string, error) {
 	if m.ChatCompletionFunc != nil {
 		return m.ChatCompletionFunc(messages)
 	}
 	return "Default mock response", nil
 }
 
+func (m *mockCerebrasClient) ChatCompletionJSON(_ context.Context, messages []cerebras.Message, schema *cerebras.JSONSchema, out interface{}) error {
+	if m.ChatCompletionJSONFunc != nil {
+		reply, err := m.ChatCompletionJSONFunc(messages, schema)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal([]byte(reply), out)
+	}
+	return nil
+}
+
 // Mock Embedding Client
 type mockEmbeddingClient struct {
 	EmbedFunc func(text string) ([]float32, error)
 }
 
-func (m *mockEmbeddingClient) Embed(text string) ([]float32, error) {
+func (m *mockEmbeddingClient) Embed(_ context.Context, text string) ([]float32, error) {
 	if m.EmbedFunc != nil {
 		return m.EmbedFunc(text)
 	}
@@ -36,50 +51,90 @@ func (m *mockEmbeddingClient) Embed(text string) ([]float32, error) {
 
 // Mock Memory Store
 type mockMemoryStore struct {
-	AddFunc                 func(userId string, text string, vector []float32) error
-	SearchFunc              func(userId string, queryVector []float32, limit int) ([]string, error)
+	AddFunc                 func(userId string, m memory.Memory, vector []float32) error
+	ReviseFunc              func(userId, id string, m memory.Memory, vector []float32, relation string) error
+	SearchFunc              func(userId, query string, queryVector []float32, limit int) ([]memory.SearchResult, error)
+	GetProfileFunc          func(userId string) (memory.Profile, error)
+	UpdateProfileFunc       func(userId string, fields map[string]string) error
+	ListFunc                func(userId string) ([]memory.MemoryItem, error)
+	DeleteFunc              func(userId, id string) error
 	AddRecentMessageFunc    func(userId, message string) error
 	GetRecentMessagesFunc   func(userId string) ([]string, error)
 	ClearRecentMessagesFunc func(userId string) error
 	DeleteUserDataFunc      func(userId string) error
 }
 
-func (m *mockMemoryStore) Add(userId string, text string, vector []float32) error {
+func (m *mockMemoryStore) Add(_ context.Context, userId string, fact memory.Memory, vector []float32) error {
 	if m.AddFunc != nil {
-		return m.AddFunc(userId, text, vector)
+		return m.AddFunc(userId, fact, vector)
+	}
+	return nil
+}
+
+func (m *mockMemoryStore) Revise(_ context.Context, userId, id string, fact memory.Memory, vector []float32, relation string) error {
+	if m.ReviseFunc != nil {
+		return m.ReviseFunc(userId, id, fact, vector, relation)
 	}
 	return nil
 }
 
-func (m *mockMemoryStore) Search(userId string, queryVector []float32, limit int) ([]string, error) {
+func (m *mockMemoryStore) SearchMemories(_ context.Context, userId, query string, queryVector []float32, limit int) ([]memory.SearchResult, error) {
 	if m.SearchFunc != nil {
-		return m.SearchFunc(userId, queryVector, limit)
+		return m.SearchFunc(userId, query, queryVector, limit)
 	}
-	return []string{"retrieved memory 1", "retrieved memory 2"}, nil
+	return []memory.SearchResult{{ID: "m1", Text: "retrieved memory 1"}, {ID: "m2", Text: "retrieved memory 2"}}, nil
 }
 
-func (m *mockMemoryStore) AddRecentMessage(userId, message string) error {
+func (m *mockMemoryStore) GetProfile(_ context.Context, userId string) (memory.Profile, error) {
+	if m.GetProfileFunc != nil {
+		return m.GetProfileFunc(userId)
+	}
+	return memory.Profile{}, nil
+}
+
+func (m *mockMemoryStore) UpdateProfile(_ context.Context, userId string, fields map[string]string) error {
+	if m.UpdateProfileFunc != nil {
+		return m.UpdateProfileFunc(userId, fields)
+	}
+	return nil
+}
+
+func (m *mockMemoryStore) List(_ context.Context, userId string) ([]memory.MemoryItem, error) {
+	if m.ListFunc != nil {
+		return m.ListFunc(userId)
+	}
+	return nil, nil
+}
+
+func (m *mockMemoryStore) Delete(_ context.Context, userId, id string) error {
+	if m.DeleteFunc != nil {
+		return m.DeleteFunc(userId, id)
+	}
+	return nil
+}
+
+func (m *mockMemoryStore) AddRecentMessage(_ context.Context, userId, message string) error {
 	if m.AddRecentMessageFunc != nil {
 		return m.AddRecentMessageFunc(userId, message)
 	}
 	return nil
 }
 
-func (m *mockMemoryStore) GetRecentMessages(userId string) ([]string, error) {
+func (m *mockMemoryStore) GetRecentMessages(_ context.Context, userId string) ([]string, error) {
 	if m.GetRecentMessagesFunc != nil {
 		return m.GetRecentMessagesFunc(userId)
 	}
 	return []string{"recent message 1", "recent message 2"}, nil
 }
 
-func (m *mockMemoryStore) ClearRecentMessages(userId string) error {
+func (m *mockMemoryStore) ClearRecentMessages(_ context.Context, userId string) error {
 	if m.ClearRecentMessagesFunc != nil {
 		return m.ClearRecentMessagesFunc(userId)
 	}
 	return nil
 }
 
-func (m *mockMemoryStore) DeleteUserData(userId string) error {
+func (m *mockMemoryStore) DeleteUserData(_ context.Context, userId string) error {
 	if m.DeleteUserDataFunc != nil {
 		return m.DeleteUserDataFunc(userId)
 	}
@@ -91,6 +146,7 @@ type mockDiscordSession struct {
 	ChannelMessageSendFunc        func(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageSendReplyFunc   func(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageSendComplexFunc func(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditFunc        func(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelTypingFunc             func(channelID string, options ...discordgo.RequestOption) error
 	UserFunc                      func(userID string) (*discordgo.User, error)
 	ChannelFunc                   func(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
@@ -118,6 +174,13 @@ func (m *mockDiscordSession) ChannelMessageSendComplex(channelID string, data *d
 	return &discordgo.Message{}, nil
 }
 
+func (m *mockDiscordSession) ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	if m.ChannelMessageEditFunc != nil {
+		return m.ChannelMessageEditFunc(channelID, messageID, content, options...)
+	}
+	return &discordgo.Message{}, nil
+}
+
 func (m *mockDiscordSession) ChannelTyping(channelID string, options ...discordgo.RequestOption) error {
 	if m.ChannelTypingFunc != nil {
 		return m.ChannelTypingFunc(channelID, options...)
@@ -165,9 +228,9 @@ func TestMessageFlow(t *testing.T) {
 	var memoryTextAdded string
 	var finalPrompt string
 
-	mockMemory.SearchFunc = func(userId string, queryVector []float32, limit int) ([]string, error) {
+	mockMemory.SearchFunc = func(userId, query string, queryVector []float32, limit int) ([]memory.SearchResult, error) {
 		searchCalled = true
-		return []string{"retrieved memory"}, nil
+		return []memory.SearchResult{{Text: "retrieved memory"}}, nil
 	}
 
 	mockMemory.GetRecentMessagesFunc = func(userId string) ([]string, error) {
@@ -219,9 +282,9 @@ func TestMessageFlow(t *testing.T) {
 		return nil
 	}
 
-	mockMemory.AddFunc = func(userId string, text string, vector []float32) error {
+	mockMemory.AddFunc = func(userId string, m memory.Memory, vector []float32) error {
 		addMemoryCalled = true
-		memoryTextAdded = text
+		memoryTextAdded = m.Text
 		return nil
 	}
 
@@ -1,17 +1,22 @@
 package bot
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"ninoai/pkg/cerebras"
+	"ninoai/pkg/classifier"
 	"ninoai/pkg/memory"
+	"ninoai/pkg/usage"
 
 	"github.com/bwmarrin/discordgo"
 )
@@ -21,6 +26,7 @@ type Session interface {
 	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEdit(channelID, messageID, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
 	ChannelTyping(channelID string, options ...discordgo.RequestOption) (err error)
 	User(userID string) (*discordgo.User, error)
 	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
@@ -45,47 +51,78 @@ func (s *DiscordSession) GuildEmojis(guildID string, options ...discordgo.Reques
 }
 
 type CerebrasClient interface {
-	ChatCompletion(messages []cerebras.Message) (string, error)
+	ChatCompletion(ctx context.Context, messages []cerebras.Message) (string, error)
+	ChatCompletionJSON(ctx context.Context, messages []cerebras.Message, schema *cerebras.JSONSchema, out interface{}) error
 }
 
 type EmbeddingClient interface {
-	Embed(text string) ([]float32, error)
+	Embed(ctx context.Context, text string) ([]float32, error)
 }
 
 type Classifier interface {
-	Classify(text string, labels []string) (string, float64, error)
+	Classify(ctx context.Context, text string, labels []string) (string, float64, error)
+	ClassifyAll(ctx context.Context, text string, labels []string) ([]classifier.ClassificationResult, error)
 }
 
 type Handler struct {
 	cerebrasClient         CerebrasClient
-	classifierClient       Classifier
 	embeddingClient        EmbeddingClient
 	memoryStore            memory.Store
+	analyzer               *Analyzer // Classifies each message once for the reply and task decisions
 	taskAgent              *TaskAgent
 	botID                  string
 	emojiCache             map[string][]string // guildID -> filtered emoji names
 	emojiCacheMu           sync.RWMutex
-	emojiCachePath         string // Path to emoji cache file
+	emojiCachePath         string          // Path to emoji cache file
+	ctx                    context.Context // Cancelled by Shutdown to abort in-flight work
+	cancel                 context.CancelFunc
 	wg                     sync.WaitGroup
+	stopping               chan struct{} // Closed when Shutdown starts; no new work is accepted after that
+	stoppingMu             sync.Mutex    // Orders closing stopping against adding to wg
+	shutdownGrace          time.Duration // How long Shutdown lets in-flight work finish before cancelling it
 	lastMessageTimes       map[string]time.Time
 	lastMessageMu          sync.RWMutex
 	messageProcessingDelay time.Duration
 	processingUsers        map[string]bool
 	processingMu           sync.Mutex
+	streaming              bool
+	streamEditInterval     time.Duration
+	usageTracker           *usage.Tracker // Backs the /usage command; nil when usage isn't tracked
+	budget                 usage.Budget
+	budgetNotified         map[string]time.Time // userID -> when their exhausted budget resets
+	budgetMu               sync.Mutex
+	memoryQueue            *memory.EmbedQueue // Holds facts while the embedding API is down; nil drops them
+	tools                  *ToolRegistry      // Offered to the model when not empty
+	maxToolIterations      int
+	pendingReminders       map[string]int // userID -> reminders not yet sent
+	remindersMu            sync.Mutex
+	replyPolicies          ReplyPolicies
+	replyRoll              func() float64 // Dice for reply probabilities
 }
 
 func NewHandler(c CerebrasClient, cl Classifier, e EmbeddingClient, m memory.Store, messageProcessingDelay float64) *Handler {
+	ctx, cancel := context.WithCancel(context.Background())
+	analyzer := NewAnalyzer(cl)
 	h := &Handler{
 		cerebrasClient:         c,
-		classifierClient:       cl,
 		embeddingClient:        e,
 		memoryStore:            m,
-		taskAgent:              NewTaskAgent(c, cl),
+		analyzer:               analyzer,
+		taskAgent:              NewTaskAgent(c, analyzer),
 		emojiCache:             make(map[string][]string),
 		emojiCachePath:         "storage/emoji_cache.json",
+		ctx:                    ctx,
+		cancel:                 cancel,
+		stopping:               make(chan struct{}),
+		shutdownGrace:          defaultShutdownGrace,
 		lastMessageTimes:       make(map[string]time.Time),
 		messageProcessingDelay: time.Duration(messageProcessingDelay * float64(time.Second)),
 		processingUsers:        make(map[string]bool),
+		budgetNotified:         make(map[string]time.Time),
+		tools:                  NewToolRegistry(),
+		maxToolIterations:      defaultMaxToolIterations,
+		pendingReminders:       make(map[string]int),
+		replyRoll:              rand.Float64,
 	}
 
 	// Load emoji cache from disk
@@ -101,14 +138,20 @@ func (h *Handler) SetBotID(id string) {
 	h.botID = id
 }
 
-func (h *Handler) addRecentMessage(userId, message string) {
-	if err := h.memoryStore.AddRecentMessage(userId, message); err != nil {
+// SetUsageTracker makes token usage reports available through /usage. The
+// tracker should also be the LLM client's usage recorder.
+func (h *Handler) SetUsageTracker(t *usage.Tracker) {
+	h.usageTracker = t
+}
+
+func (h *Handler) addRecentMessage(ctx context.Context, userId, message string) {
+	if err := h.memoryStore.AddRecentMessage(ctx, userId, message); err != nil {
 		log.Printf("Error adding recent message: %v", err)
 	}
 }
 
-func (h *Handler) getRecentMessages(userId string) []string {
-	messages, err := h.memoryStore.GetRecentMessages(userId)
+func (h *Handler) getRecentMessages(ctx context.Context, userId string) []string {
+	messages, err := h.memoryStore.GetRecentMessages(ctx, userId)
 	if err != nil {
 		log.Printf("Error getting recent messages: %v", err)
 		return []string{}
@@ -116,16 +159,19 @@ func (h *Handler) getRecentMessages(userId string) []string {
 	return messages
 }
 
-func (h *Handler) ResetMemory(userId string) error {
-	if err := h.memoryStore.ClearRecentMessages(userId); err != nil {
+func (h *Handler) ResetMemory(ctx context.Context, userId string) error {
+	if err := h.memoryStore.ClearRecentMessages(ctx, userId); err != nil {
 		log.Printf("Error clearing recent messages: %v", err)
 	}
 	// Also clear long-term memory for this user?
 	// The user request said "ResetMemory" in the context of "Starting fresh".
 	// If we want a full reset, we should call DeleteUserData.
-	if err := h.memoryStore.DeleteUserData(userId); err != nil {
+	if err := h.memoryStore.DeleteUserData(ctx, userId); err != nil {
 		log.Printf("Error deleting user data: %v", err)
 	}
+	if h.memoryQueue != nil {
+		h.memoryQueue.DeleteUser(userId)
+	}
 	return nil
 }
 
@@ -175,7 +221,7 @@ func (h *Handler) saveEmojiCache() {
 
 // filterRelevantEmojis uses LLM to filter emojis that are relevant to Nino's character
 // Results are cached per guild to avoid redundant LLM calls
-func (h *Handler) filterRelevantEmojis(guildID string, emojis []*discordgo.Emoji) []string {
+func (h *Handler) filterRelevantEmojis(ctx context.Context, guildID string, emojis []*discordgo.Emoji) []string {
 	if len(emojis) == 0 {
 		return []string{}
 	}
@@ -217,14 +263,15 @@ EXCLUDE emojis related to:
 - Other anime characters (unless from Quintessential Quintuplets)
 - Random/nonsensical names
 
-Return ONLY the emoji names that should be kept, separated by commas. If none are relevant, return "NONE".`, strings.Join(emojiNames, ", "))
+Reply with JSON only: {"emojis": ["name", ...]}. If none are relevant, return an empty list.`, strings.Join(emojiNames, ", "))
 
 	messages := []cerebras.Message{
 		{Role: "system", Content: "You are an emoji filter for a character AI."},
 		{Role: "user", Content: filterPrompt},
 	}
 
-	resp, err := h.cerebrasClient.ChatCompletion(messages)
+	var selection emojiSelection
+	err := h.cerebrasClient.ChatCompletionJSON(usage.WithKind(ctx, usage.KindEmojiFilter), messages, emojiSelectionSchema, &selection)
 	if err != nil {
 		log.Printf("Error filtering emojis: %v", err)
 		// If filtering fails, return first 10 emojis as fallback
@@ -234,18 +281,15 @@ Return ONLY the emoji names that should be kept, separated by commas. If none ar
 		return emojiNames
 	}
 
-	// Parse response
-	var result []string
-	if strings.TrimSpace(resp) == "NONE" {
-		result = []string{}
-	} else {
-		// Split by comma and clean up
-		filtered := strings.Split(resp, ",")
-		for _, name := range filtered {
-			cleaned := strings.TrimSpace(name)
-			if cleaned != "" {
-				result = append(result, cleaned)
-			}
+	// Keep only names that exist, in case the model made some up
+	known := make(map[string]bool, len(emojiNames))
+	for _, name := range emojiNames {
+		known[name] = true
+	}
+	result := []string{}
+	for _, name := range selection.Emojis {
+		if name = strings.TrimSpace(name); known[name] {
+			result = append(result, name)
 		}
 	}
 
@@ -264,11 +308,24 @@ func (h *Handler) MessageCreate(s *discordgo.Session, m *discordgo.MessageCreate
 	h.HandleMessage(&DiscordSession{s}, m)
 }
 
+// HandleMessage processes a single message. Shutdown waits for it to finish,
+// and messages arriving after Shutdown was called are ignored.
 func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 	// Ignore own messages
 	if m.Author.ID == h.botID {
 		return
 	}
+	if !h.begin() {
+		return
+	}
+	defer h.wg.Done()
+
+	// LLM calls made for this message are billed to its author
+	ctx := usage.WithAttribution(h.ctx, usage.Attribution{
+		UserID:  m.Author.ID,
+		GuildID: m.GuildID,
+		Kind:    usage.KindReply,
+	})
 
 	// Ignore long messages
 	if len(m.Content) > 280 {
@@ -322,29 +379,23 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 	shouldReply := isMentioned || isDM
 
 	// Get recent context (Rolling Chat Context)
-	recentMsgs := h.getRecentMessages(m.Author.ID)
-
-	if !shouldReply {
-		// Use Classifier to decide if Nino should respond based on her personality
-		labels := []string{
-			"message directly addressing Nino or Nakano",
-			"discussion about cooking or food",
-			"discussion about fashion or appearance",
-			"discussion about romance or relationships",
-			"someone being pathetic",
-			"someone not taking care of themselves (health, sleep, eating)",
-			"casual conversation or blank message without mention of nino",
+	recentMsgs := h.getRecentMessages(ctx, m.Author.ID)
+
+	// Decide based on her personality, as the message's policy says
+	policy := h.replyPolicies.Resolve(m.GuildID, m.ChannelID)
+	if !shouldReply && policy.Classifies() {
+		// Classifying may call the LLM, and a user over budget gets no reply anyway
+		if budgetErr := h.overBudget(ctx); budgetErr != nil {
+			log.Printf("Not classifying message from user %s: %v", m.Author.ID, budgetErr)
+			return
 		}
-
-		label, score, err := h.classifierClient.Classify(m.Content, labels)
+		analysis, err := h.analyzer.Analyze(ctx, m.ID, m.Content, policy)
 		if err != nil {
 			log.Printf("Error classifying message: %v", err)
 		} else {
-			log.Printf("Reply Decision: '%s' (score: %.2f)", label, score)
-			// Reply if it matches her personality triggers (not casual conversation)
-			if label != "casual conversation unrelated to Nino's interests" && score > 0.6 {
-				shouldReply = true
-			}
+			var trigger classifier.ClassificationResult
+			shouldReply, trigger = policy.Decide(analysis.Results, h.replyRoll)
+			log.Printf("Reply Decision: %t (topic: '%s' %.2f, trigger: '%s' %.2f)", shouldReply, analysis.Topic.Label, analysis.Topic.Score, trigger.Label, trigger.Score)
 		}
 	}
 
@@ -363,10 +414,15 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 		displayName = m.Author.GlobalName
 	}
 
+	// Don't spend tokens on users, guilds or a bot that have used up their budget
+	if !h.withinBudget(ctx, s, m) {
+		return
+	}
+
 	s.ChannelTyping(m.ChannelID)
 
 	// Check if this is a long task request that should be refused
-	isTask, refusal := h.taskAgent.CheckTask(m.Content)
+	isTask, refusal := h.taskAgent.CheckTask(ctx, m.ID, m.Content, policy)
 	if isTask {
 		h.sendSplitMessage(s, m.ChannelID, refusal, m.Reference())
 
@@ -374,15 +430,15 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 		h.wg.Add(1)
 		go func() {
 			defer h.wg.Done()
-			h.addRecentMessage(m.Author.ID, fmt.Sprintf("%s: %s", displayName, m.Content))
-			h.addRecentMessage(m.Author.ID, fmt.Sprintf("Nino: %s", refusal))
+			h.addRecentMessage(ctx, m.Author.ID, fmt.Sprintf("%s: %s", displayName, m.Content))
+			h.addRecentMessage(ctx, m.Author.ID, fmt.Sprintf("Nino: %s", refusal))
 		}()
 		return
 	}
 
 	// 1. Generate Embedding for current message
 	// We use the user's message as the query for retrieval
-	emb, err := h.embeddingClient.Embed(m.Content)
+	emb, err := h.embeddingClient.Embed(ctx, m.Content)
 	if err != nil {
 		log.Printf("Error generating embedding: %v", err)
 	}
@@ -390,19 +446,30 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 	// 2. Search Memory (RAG)
 	var retrievedMemories string
 	if emb != nil {
-		matches, err := h.memoryStore.Search(m.Author.ID, emb, 5) // Top 5 relevant memories
+		matches, err := h.memoryStore.SearchMemories(ctx, m.Author.ID, m.Content, emb, 5) // Top 5 relevant memories
 		if err != nil {
 			log.Printf("Error searching memory: %v", err)
 		} else if len(matches) > 0 {
-			retrievedMemories = "Relevant past memories:\n- " + strings.Join(matches, "\n- ")
+			for _, match := range matches {
+				log.Printf("Memory match %s: %q (score %.3f, similarity %.3f)", match.ID, match.Text, match.Score, match.Similarity)
+			}
+			retrievedMemories = "Relevant past memories:\n" + formatMemories(matches, time.Now())
 		}
 	}
 
+	// The profile is always included, whatever the message is about
+	var profileText string
+	if profile, err := h.memoryStore.GetProfile(ctx, m.Author.ID); err != nil {
+		log.Printf("Error loading profile: %v", err)
+	} else if fields := profile.String(); fields != "" {
+		profileText = fmt.Sprintf("What you know about %s:\n%s", displayName, fields)
+	}
+
 	// 3. Prepare Context (Rolling Window)
 	// We already fetched recentMsgs above.
-	var rollingContext string
+	var rollingContext cerebras.Message
 	if len(recentMsgs) > 0 {
-		rollingContext = "Recent conversation:\n" + strings.Join(recentMsgs, "\n")
+		rollingContext = cerebras.RollingContext("Recent conversation:", recentMsgs)
 	}
 
 	// 4. Prepare Emojis
@@ -410,7 +477,7 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 	if channel != nil && channel.GuildID != "" {
 		emojis, err := s.GuildEmojis(channel.GuildID)
 		if err == nil && len(emojis) > 0 {
-			relevantNames := h.filterRelevantEmojis(channel.GuildID, emojis)
+			relevantNames := h.filterRelevantEmojis(ctx, channel.GuildID, emojis)
 
 			if len(relevantNames) > 0 {
 				nameToEmoji := make(map[string]*discordgo.Emoji)
@@ -434,89 +501,75 @@ func (h *Handler) HandleMessage(s Session, m *discordgo.MessageCreate) {
 
 	// 5. Construct Prompt
 	// [System Prompt]
+	// [Profile]
 	// [Retrieved Memories]
 	// [Rolling Chat Context]
 	// [Current User Message] (handled by appending as user message)
 
 	systemPrompt := fmt.Sprintf(SystemPrompt, displayName)
-	memoryInstruction := fmt.Sprintf(MemoryInstruction, displayName)
 	messages := []cerebras.Message{
 		{Role: "system", Content: systemPrompt},
-		{Role: "system", Content: memoryInstruction},
+	}
+	if profileText != "" {
+		messages = append(messages, cerebras.Message{Role: "system", Content: profileText})
 	}
 	log.Printf("Retrieved memories: %s", retrievedMemories)
 	if retrievedMemories != "" {
-		messages = append(messages, cerebras.Message{Role: "system", Content: retrievedMemories})
+		messages = append(messages, cerebras.Message{Role: "system", Content: retrievedMemories, Trim: cerebras.TrimMemories})
 	}
-	log.Printf("Rolling context: %s", rollingContext)
-	if rollingContext != "" {
-		messages = append(messages, cerebras.Message{Role: "system", Content: rollingContext})
+	log.Printf("Rolling context: %s", rollingContext.Content)
+	if rollingContext.Content != "" {
+		messages = append(messages, rollingContext)
 	}
 	if emojiText != "" {
-		messages = append(messages, cerebras.Message{Role: "system", Content: emojiText})
+		messages = append(messages, cerebras.Message{Role: "system", Content: emojiText, Trim: cerebras.TrimEmojis})
 	}
 
 	messages = append(messages, cerebras.Message{Role: "user", Content: m.Content})
 
 	// 6. Generate Reply
-	reply, err := h.cerebrasClient.ChatCompletion(messages)
+	reply, err := h.generateReply(ctx, s, m, messages)
 	if err != nil {
 		log.Printf("Error getting completion: %v", err)
 		h.sendSplitMessage(s, m.ChannelID, "(I'm having a headache... try again later.)", m.Reference())
 		return
 	}
 
-	// Check for memory tag to clean up the user-facing message
-	displayReply := reply
-	if idx := strings.Index(reply, "[MEMORY:"); idx != -1 {
-		displayReply = strings.TrimSpace(reply[:idx])
-	}
-
-	h.sendSplitMessage(s, m.ChannelID, displayReply, m.Reference())
-
 	// 7. Async Updates
 	h.wg.Add(1)
 	go func() {
 		defer h.wg.Done()
 
-		// Check for memory tag in the reply
-		finalReply := reply
-		var memoryFact string
-
-		if idx := strings.Index(reply, "[MEMORY:"); idx != -1 {
-			// Extract memory
-			memoryContent := reply[idx:]
-			if endIdx := strings.Index(memoryContent, "]"); endIdx != -1 {
-				memoryFact = strings.TrimSpace(memoryContent[8:endIdx])
-				// Remove tag from the reply that goes to context
-				finalReply = strings.TrimSpace(reply[:idx])
-			}
-		}
-
 		// Add to Rolling Context
-		h.addRecentMessage(m.Author.ID, fmt.Sprintf("%s: %s", displayName, m.Content))
-		h.addRecentMessage(m.Author.ID, fmt.Sprintf("Nino: %s", finalReply))
+		h.addRecentMessage(ctx, m.Author.ID, fmt.Sprintf("%s: %s", displayName, m.Content))
+		h.addRecentMessage(ctx, m.Author.ID, fmt.Sprintf("Nino: %s", reply))
 
-		// Store extracted memory if present
-		if memoryFact != "" {
+		// Store any facts worth remembering from this exchange, if the user
+		// told anything about themselves and the reply left budget for it
+		if !mayShareFacts(m.Content) {
+			return
+		}
+		if budgetErr := h.overBudget(ctx); budgetErr != nil {
+			log.Printf("Not extracting memories for user %s: %v", m.Author.ID, budgetErr)
+			return
+		}
+		memories, err := h.extractMemories(ctx, displayName, m.Content, reply)
+		if err != nil {
+			log.Printf("Error extracting memories: %v", err)
+			return
+		}
+		for _, memoryFact := range memories {
 			// Validate memory importance
-			if !h.isMemoryWorthStoring(memoryFact) {
-				log.Printf("Skipping trivial memory: %s", memoryFact)
-				return
+			if !h.isMemoryWorthStoring(memoryFact.Text) {
+				log.Printf("Skipping trivial memory: %s", memoryFact.Text)
+				continue
 			}
 
-			log.Printf("Detected memory update: %s", memoryFact)
-			factEmb, err := h.embeddingClient.Embed(memoryFact)
-			if err == nil {
-				log.Printf("Storing new memory for user %s: %s", m.Author.ID, memoryFact)
-				if err := h.memoryStore.Add(m.Author.ID, memoryFact, factEmb); err != nil {
-					// Check if this is a duplicate error
-					if strings.Contains(err.Error(), "duplicate memory") {
-						log.Printf("Skipping duplicate memory: %v", err)
-					} else {
-						log.Printf("Error storing memory: %v", err)
-					}
-				}
+			log.Printf("Detected memory update: %s", memoryFact.Text)
+			memoryFact.SourceMessageID = m.ID
+			memoryFact.SourceChannelID = m.ChannelID
+			if err := h.storeMemory(ctx, m.Author.ID, memoryFact); err != nil {
+				log.Printf("Error storing memory: %v", err)
 			}
 		}
 	}()
@@ -544,44 +597,57 @@ func (h *Handler) isMemoryWorthStoring(fact string) bool {
 }
 
 func (h *Handler) sendSplitMessage(s Session, channelID, content string, reference *discordgo.MessageReference) {
-	// Replace \n\n with a special separator for multi-part messages
-	content = strings.ReplaceAll(content, "\n\n", "---SPLIT---")
-	parts := strings.Split(content, "---SPLIT---")
-
-	isFirstPart := true
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if part == "" {
-			continue
+	for i, part := range splitMessage(content) {
+		if _, err := sendPart(s, channelID, part, reference, i == 0); err != nil {
+			log.Printf("Error sending message part: %v", err)
 		}
 
-		var err error
-		if reference == nil {
-			// If there's no reference, send a normal message
-			_, err = s.ChannelMessageSend(channelID, part)
-		} else {
-			if isFirstPart {
-				// The first part of a reply pings the user by default
-				_, err = s.ChannelMessageSendReply(channelID, part, reference)
-				isFirstPart = false
-			} else {
-				// Subsequent parts are sent as replies without pinging the user
-				_, err = s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
-					Content:   part,
-					Reference: reference,
-					AllowedMentions: &discordgo.MessageAllowedMentions{
-						RepliedUser: false, // This prevents pinging on subsequent parts
-					},
-				})
+		// Add a short delay between messages for a more natural feel
+		time.Sleep(h.messageProcessingDelay)
+	}
+}
+
+// maxMessageLength is the most characters Discord allows in a message
+const maxMessageLength = 2000
+
+// splitMessage splits content into the messages it is sent as: one per
+// paragraph, with paragraphs longer than maxMessageLength cut at the last
+// line break or space that fits.
+func splitMessage(content string) []string {
+	var parts []string
+	for _, part := range strings.Split(content, "\n\n") {
+		part = strings.TrimSpace(part)
+		for utf8.RuneCountInString(part) > maxMessageLength {
+			head := string([]rune(part)[:maxMessageLength])
+			if i := strings.LastIndexAny(head, "\n "); i > 0 {
+				head = head[:i]
 			}
+			parts = append(parts, strings.TrimSpace(head))
+			part = strings.TrimSpace(part[len(head):])
 		}
-
-		if err != nil {
-			log.Printf("Error sending message part: %v", err)
+		if part != "" {
+			parts = append(parts, part)
 		}
+	}
+	return parts
+}
 
-		// Add a short delay between messages for a more natural feel
-		time.Sleep(h.messageProcessingDelay)
+// sendPart sends one part of a reply. Only the first part of a reply pings
+// the user; the rest are sent as replies without pinging.
+func sendPart(s Session, channelID, part string, reference *discordgo.MessageReference, first bool) (*discordgo.Message, error) {
+	switch {
+	case reference == nil:
+		return s.ChannelMessageSend(channelID, part)
+	case first:
+		return s.ChannelMessageSendReply(channelID, part, reference)
+	default:
+		return s.ChannelMessageSendComplex(channelID, &discordgo.MessageSend{
+			Content:   part,
+			Reference: reference,
+			AllowedMentions: &discordgo.MessageAllowedMentions{
+				RepliedUser: false, // This prevents pinging on subsequent parts
+			},
+		})
 	}
 }
 
@@ -596,13 +662,19 @@ func (h *Handler) clearInactiveUsers() {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	for range ticker.C {
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
 		h.lastMessageMu.Lock()
 		for userID, lastTime := range h.lastMessageTimes {
 			// If user has been inactive for 30 minutes, clear their recent memory
 			if time.Since(lastTime) > 30*time.Minute {
 				log.Printf("User %s has been inactive for 30 minutes, clearing recent memory", userID)
-				if err := h.memoryStore.ClearRecentMessages(userID); err != nil {
+				if err := h.memoryStore.ClearRecentMessages(h.ctx, userID); err != nil {
 					log.Printf("Error clearing recent messages for inactive user %s: %v", userID, err)
 				}
 				// Remove from tracking map
@@ -616,3 +688,46 @@ func (h *Handler) clearInactiveUsers() {
 func (h *Handler) WaitForReady() {
 	h.wg.Wait()
 }
+
+// defaultShutdownGrace is how long Shutdown waits for replies and memory
+// updates to finish before cancelling them
+const defaultShutdownGrace = 10 * time.Second
+
+// begin registers a message or interaction with Shutdown, which waits for it
+// until the caller calls h.wg.Done. It returns false once Shutdown was called,
+// in which case the work should be dropped.
+func (h *Handler) begin() bool {
+	h.stoppingMu.Lock()
+	defer h.stoppingMu.Unlock()
+	select {
+	case <-h.stopping:
+		return false
+	default:
+		h.wg.Add(1)
+		return true
+	}
+}
+
+// Shutdown stops accepting messages and interactions, waits up to
+// shutdownGrace for in-flight replies and background memory updates to
+// finish, then cancels all LLM, embedding, classifier and database calls
+// still running and waits for them to wind down.
+func (h *Handler) Shutdown() {
+	h.stoppingMu.Lock()
+	close(h.stopping)
+	h.stoppingMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(h.shutdownGrace):
+		log.Printf("In-flight work still running after %v, cancelling it", h.shutdownGrace)
+	}
+	h.cancel()
+	<-done
+}
@@ -0,0 +1,40 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"ninoai/pkg/memory"
+)
+
+// formatAge says roughly how long ago something happened, e.g. "3 weeks ago".
+func formatAge(age time.Duration) string {
+	const day = 24 * time.Hour
+	switch days := int(age / day); {
+	case days < 1:
+		return "today"
+	case days < 2:
+		return "yesterday"
+	case days < 14:
+		return fmt.Sprintf("%d days ago", days)
+	case days < 60:
+		return fmt.Sprintf("%d weeks ago", days/7)
+	case days < 365:
+		return fmt.Sprintf("%d months ago", days/30)
+	case days < 730:
+		return "a year ago"
+	default:
+		return fmt.Sprintf("%d years ago", days/365)
+	}
+}
+
+// formatMemories lists search results one per line, with how long ago each
+// was learned so that the model can tell old facts from fresh ones.
+func formatMemories(results []memory.SearchResult, now time.Time) string {
+	lines := make([]string, len(results))
+	for i, r := range results {
+		lines[i] = fmt.Sprintf("- %s (%s)", r.Text, formatAge(now.Sub(time.Unix(r.Timestamp, 0))))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,42 @@
+package bot
+
+import (
+	"testing"
+	"time"
+
+	"ninoai/pkg/memory"
+)
+
+func TestFormatAge(t *testing.T) {
+	day := 24 * time.Hour
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{age: time.Hour, want: "today"},
+		{age: 30 * time.Hour, want: "yesterday"},
+		{age: 5 * day, want: "5 days ago"},
+		{age: 21 * day, want: "3 weeks ago"},
+		{age: 100 * day, want: "3 months ago"},
+		{age: 400 * day, want: "a year ago"},
+		{age: 800 * day, want: "2 years ago"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.age); got != tt.want {
+			t.Errorf("formatAge(%v) = %q, want %q", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMemories(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	results := []memory.SearchResult{
+		{Text: "Has a cat named Mochi", Timestamp: now.Add(-21 * 24 * time.Hour).Unix()},
+		{Text: "Lives in Berlin", Timestamp: now.Unix()},
+	}
+
+	want := "- Has a cat named Mochi (3 weeks ago)\n- Lives in Berlin (today)"
+	if got := formatMemories(results, now); got != want {
+		t.Errorf("formatMemories() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,316 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"ninoai/pkg/memory"
+	"ninoai/pkg/usage"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+const (
+	// memoriesPageSize is the number of memories per page of /memories
+	memoriesPageSize = 10
+	// memoryPreviewLength caps how much of each memory /memories shows
+	memoryPreviewLength = 150
+	// maxAutocompleteChoices is the most choices Discord accepts, each with
+	// a name of at most maxChoiceNameLength characters
+	maxAutocompleteChoices = 25
+	maxChoiceNameLength    = 100
+	// memoriesPagePrefix starts the custom ID of the /memories page buttons,
+	// followed by the page they lead to
+	memoriesPagePrefix = "memories:"
+)
+
+// interactionUserID returns who triggered the interaction, in guilds and DMs.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// respondEphemeral answers an interaction with a message only its user sees.
+func respondEphemeral(s *discordgo.Session, i *discordgo.InteractionCreate, content string) {
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+	if err != nil {
+		log.Printf("Error responding to %s command: %v", i.ApplicationCommandData().Name, err)
+	}
+}
+
+// truncateText shortens text to at most n characters, ending with an
+// ellipsis if it was cut.
+func truncateText(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n-1]) + "…"
+}
+
+// handleMemoriesCommand handles the /memories slash command
+func handleMemoriesCommand(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	items, err := h.memoryStore.List(h.ctx, userID)
+	if err != nil {
+		log.Printf("Error listing memories for user %s: %v", userID, err)
+		respondEphemeral(s, i, "Ugh, I can't remember anything right now... Try again later?")
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: memoriesPage(items, 0, time.Now()),
+	})
+	if err != nil {
+		log.Printf("Error responding to memories command: %v", err)
+	}
+}
+
+// handleMemoriesPageButton turns the page of a /memories response
+func handleMemoriesPageButton(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	page, err := strconv.Atoi(strings.TrimPrefix(i.MessageComponentData().CustomID, memoriesPagePrefix))
+	if err != nil {
+		log.Printf("Invalid memories page button: %s", i.MessageComponentData().CustomID)
+		return
+	}
+
+	userID := interactionUserID(i)
+	items, err := h.memoryStore.List(h.ctx, userID)
+	if err != nil {
+		log.Printf("Error listing memories for user %s: %v", userID, err)
+		return
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: memoriesPage(items, page, time.Now()),
+	})
+	if err != nil {
+		log.Printf("Error turning memories page: %v", err)
+	}
+}
+
+// memoriesPage renders one page of a user's memories, with buttons to the
+// previous and next pages. Pages out of range show the nearest one, as
+// memories may have been forgotten since the buttons were sent.
+func memoriesPage(items []memory.MemoryItem, page int, now time.Time) *discordgo.InteractionResponseData {
+	data := &discordgo.InteractionResponseData{
+		Flags: discordgo.MessageFlagsEphemeral,
+		// An empty list removes the buttons of an earlier page
+		Components: []discordgo.MessageComponent{},
+	}
+	if len(items) == 0 {
+		data.Content = "I don't remember anything about you yet. Not that I was trying to, or anything."
+		return data
+	}
+
+	pages := (len(items) + memoriesPageSize - 1) / memoriesPageSize
+	page = max(0, min(page, pages-1))
+	start := page * memoriesPageSize
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**What I remember about you** (%d memories, page %d of %d)\n", len(items), page+1, pages)
+	for n, item := range items[start:min(start+memoriesPageSize, len(items))] {
+		details := formatAge(now.Sub(time.Unix(item.Timestamp, 0)))
+		if item.Category != "" {
+			details = item.Category + ", " + details
+		}
+		fmt.Fprintf(&sb, "%d. %s (%s)\n", start+n+1, truncateText(item.Text, memoryPreviewLength), details)
+	}
+	sb.WriteString("\nUse /forget to make me forget one of them.")
+	data.Content = sb.String()
+
+	if pages > 1 {
+		data.Components = []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{
+					Label:    "Previous",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("%s%d", memoriesPagePrefix, page-1),
+					Disabled: page == 0,
+				},
+				discordgo.Button{
+					Label:    "Next",
+					Style:    discordgo.SecondaryButton,
+					CustomID: fmt.Sprintf("%s%d", memoriesPagePrefix, page+1),
+					Disabled: page == pages-1,
+				},
+			}},
+		}
+	}
+	return data
+}
+
+// handleForgetCommand handles the /forget slash command. Its option holds
+// the ID of a memory picked from the autocomplete choices.
+func handleForgetCommand(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	userID := interactionUserID(i)
+	var id string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "memory" {
+			id = opt.StringValue()
+		}
+	}
+
+	forgotten, err := h.ForgetMemory(h.ctx, userID, id)
+	switch {
+	case errors.Is(err, memory.ErrNotFound):
+		respondEphemeral(s, i, "I don't remember that. Pick one of the memories I suggest, okay?")
+	case err != nil:
+		log.Printf("Error forgetting memory %s of user %s: %v", id, userID, err)
+		respondEphemeral(s, i, "Ugh, something went wrong trying to forget that... Try again later?")
+	default:
+		respondEphemeral(s, i, fmt.Sprintf("Fine, I forgot that: %s", truncateText(forgotten.Text, memoryPreviewLength)))
+	}
+}
+
+// ForgetMemory deletes one of the user's memories and returns it. If it
+// filled a profile field that still holds its value, the field goes too.
+func (h *Handler) ForgetMemory(ctx context.Context, userID, id string) (memory.MemoryItem, error) {
+	items, err := h.memoryStore.List(ctx, userID)
+	if err != nil {
+		return memory.MemoryItem{}, err
+	}
+	var forgotten *memory.MemoryItem
+	for i := range items {
+		if items[i].ID == id {
+			forgotten = &items[i]
+		}
+	}
+	if forgotten == nil {
+		return memory.MemoryItem{}, fmt.Errorf("%w: %s", memory.ErrNotFound, id)
+	}
+
+	if err := h.memoryStore.Delete(ctx, userID, id); err != nil {
+		return memory.MemoryItem{}, err
+	}
+
+	if forgotten.Key != "" {
+		profile, err := h.memoryStore.GetProfile(ctx, userID)
+		if err != nil {
+			log.Printf("Error loading profile: %v", err)
+		} else if profile.Fields[forgotten.Key] == forgotten.Value {
+			if err := h.memoryStore.UpdateProfile(ctx, userID, map[string]string{forgotten.Key: ""}); err != nil {
+				log.Printf("Error updating profile: %v", err)
+			}
+		}
+	}
+	return *forgotten, nil
+}
+
+// handleForgetAutocomplete suggests the user's memories matching what they
+// typed so far in /forget.
+func handleForgetAutocomplete(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	var typed string
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Focused {
+			typed = opt.StringValue()
+		}
+	}
+
+	userID := interactionUserID(i)
+	items, err := h.memoryStore.List(h.ctx, userID)
+	if err != nil {
+		log.Printf("Error listing memories for user %s: %v", userID, err)
+	}
+
+	err = s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: forgetChoices(items, typed)},
+	})
+	if err != nil {
+		log.Printf("Error responding to forget autocomplete: %v", err)
+	}
+}
+
+// forgetChoices returns the memories containing typed, newest first, as
+// autocomplete choices whose values are their IDs.
+func forgetChoices(items []memory.MemoryItem, typed string) []*discordgo.ApplicationCommandOptionChoice {
+	typed = strings.ToLower(strings.TrimSpace(typed))
+	choices := []*discordgo.ApplicationCommandOptionChoice{}
+	for _, item := range items {
+		if len(choices) == maxAutocompleteChoices {
+			break
+		}
+		if !strings.Contains(strings.ToLower(item.Text), typed) {
+			continue
+		}
+		choices = append(choices, &discordgo.ApplicationCommandOptionChoice{
+			Name:  truncateText(item.Text, maxChoiceNameLength),
+			Value: item.ID,
+		})
+	}
+	return choices
+}
+
+// handleRememberCommand handles the /remember slash command. Storing the
+// fact may compare it with a close memory using the LLM, which can take
+// longer than Discord waits for a response, so the response is deferred and
+// the outcome sent as a followup.
+func handleRememberCommand(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	fact := memory.Memory{
+		SourceChannelID: i.ChannelID,
+		Confidence:      1, // The user said so
+	}
+	for _, opt := range i.ApplicationCommandData().Options {
+		switch opt.Name {
+		case "fact":
+			fact.Text = strings.TrimSpace(opt.StringValue())
+		case "category":
+			fact.Category = opt.StringValue()
+		}
+	}
+	if fact.Text == "" {
+		respondEphemeral(s, i, "Remember what, exactly?")
+		return
+	}
+
+	// LLM calls made while storing are billed to the user
+	userID := interactionUserID(i)
+	ctx := usage.WithAttribution(h.ctx, usage.Attribution{UserID: userID, GuildID: i.GuildID})
+	if budgetErr := h.overBudget(ctx); budgetErr != nil {
+		log.Printf("Not remembering fact for user %s: %v", userID, budgetErr)
+		respondEphemeral(s, i, budgetRefusal(budgetErr))
+		return
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: discordgo.MessageFlagsEphemeral},
+	})
+	if err != nil {
+		log.Printf("Error responding to remember command: %v", err)
+		return
+	}
+
+	content := "Hmph. Fine, I'll remember that. It's not like I wanted to know or anything."
+	if err := h.storeMemory(ctx, userID, fact); err != nil {
+		log.Printf("Error remembering fact for user %s: %v", userID, err)
+		content = "Ugh, something went wrong trying to remember that... Try again later?"
+	}
+
+	_, err = s.FollowupMessageCreate(i.Interaction, true, &discordgo.WebhookParams{
+		Content: content,
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		log.Printf("Error sending remember followup: %v", err)
+	}
+}
@@ -0,0 +1,112 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"ninoai/pkg/memory"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestMemoriesPage(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	var items []memory.MemoryItem
+	for n := range 23 {
+		items = append(items, memory.MemoryItem{
+			ID:        fmt.Sprintf("m%d", n),
+			Memory:    memory.Memory{Text: fmt.Sprintf("Fact %d", n+1), Category: memory.CategoryPreference},
+			Timestamp: now.Unix(),
+		})
+	}
+
+	data := memoriesPage(items, 1, now)
+	if data.Flags != discordgo.MessageFlagsEphemeral {
+		t.Error("expected the page to be ephemeral")
+	}
+	if !strings.Contains(data.Content, "page 2 of 3") || !strings.Contains(data.Content, "11. Fact 11 (preference, today)") ||
+		strings.Contains(data.Content, "Fact 10 ") || strings.Contains(data.Content, "Fact 21") {
+		t.Errorf("expected the second page of memories, got %q", data.Content)
+	}
+	buttons := data.Components[0].(discordgo.ActionsRow).Components
+	prev, next := buttons[0].(discordgo.Button), buttons[1].(discordgo.Button)
+	if prev.CustomID != "memories:0" || next.CustomID != "memories:2" || prev.Disabled || next.Disabled {
+		t.Errorf("expected buttons to the first and last pages, got %+v, %+v", prev, next)
+	}
+
+	// Memories forgotten since the buttons were sent leave fewer pages
+	data = memoriesPage(items[:5], 2, now)
+	if !strings.Contains(data.Content, "page 1 of 1") || len(data.Components) != 0 {
+		t.Errorf("expected a single page without buttons, got %q, %v", data.Content, data.Components)
+	}
+
+	data = memoriesPage(nil, 0, now)
+	if !strings.Contains(data.Content, "don't remember anything") {
+		t.Errorf("expected an empty message, got %q", data.Content)
+	}
+}
+
+func TestForgetChoices(t *testing.T) {
+	items := []memory.MemoryItem{
+		{ID: "a", Memory: memory.Memory{Text: "Has a cat named Mochi"}},
+		{ID: "b", Memory: memory.Memory{Text: "Lives in Berlin"}},
+		{ID: "c", Memory: memory.Memory{Text: strings.Repeat("Loves cats ", 20)}},
+	}
+
+	choices := forgetChoices(items, " CAT")
+	if len(choices) != 2 || choices[0].Value != "a" || choices[1].Value != "c" {
+		t.Fatalf("expected the memories mentioning cats, got %+v", choices)
+	}
+	if name := []rune(choices[1].Name); len(name) != maxChoiceNameLength || name[len(name)-1] != '…' {
+		t.Errorf("expected a truncated name, got %q", choices[1].Name)
+	}
+
+	for n := range 30 {
+		items = append(items, memory.MemoryItem{ID: fmt.Sprint(n), Memory: memory.Memory{Text: "Filler"}})
+	}
+	if choices := forgetChoices(items, ""); len(choices) != maxAutocompleteChoices {
+		t.Errorf("expected %d choices, got %d", maxAutocompleteChoices, len(choices))
+	}
+}
+
+func TestHandler_ForgetMemory(t *testing.T) {
+	var deleted string
+	var profileUpdate map[string]string
+	store := &mockMemoryStore{
+		ListFunc: func(userId string) ([]memory.MemoryItem, error) {
+			return []memory.MemoryItem{
+				{ID: "m1", Memory: memory.Memory{Text: "Lives in Berlin", Key: "location", Value: "Berlin"}},
+				{ID: "m2", Memory: memory.Memory{Text: "Likes jazz"}},
+			}, nil
+		},
+		DeleteFunc: func(userId, id string) error {
+			deleted = id
+			return nil
+		},
+		GetProfileFunc: func(userId string) (memory.Profile, error) {
+			return memory.Profile{Fields: map[string]string{"location": "Berlin"}}, nil
+		},
+		UpdateProfileFunc: func(userId string, fields map[string]string) error {
+			profileUpdate = fields
+			return nil
+		},
+	}
+	handler := NewHandler(&mockCerebrasClient{}, &MockClassifier{}, &mockEmbeddingClient{}, store, 0)
+
+	forgotten, err := handler.ForgetMemory(context.Background(), "alice", "m1")
+	if err != nil || forgotten.Text != "Lives in Berlin" || deleted != "m1" {
+		t.Fatalf("expected m1 to be deleted, got %+v, %v (deleted %q)", forgotten, err, deleted)
+	}
+	if v, ok := profileUpdate["location"]; !ok || v != "" {
+		t.Errorf("expected the profile field it filled to be removed, got %v", profileUpdate)
+	}
+
+	deleted = ""
+	if _, err := handler.ForgetMemory(context.Background(), "alice", "nope"); !errors.Is(err, memory.ErrNotFound) || deleted != "" {
+		t.Errorf("expected ErrNotFound without deleting, got %v (deleted %q)", err, deleted)
+	}
+}
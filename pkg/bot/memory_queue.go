@@ -0,0 +1,158 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ninoai/pkg/memory"
+)
+
+// PrimaryEmbedder is implemented by embedding clients that fall back to local
+// embeddings when their API fails. Memories are only ever stored with vectors
+// from the API, as local ones would not match later searches.
+type PrimaryEmbedder interface {
+	EmbedPrimary(ctx context.Context, text string) ([]float32, error)
+}
+
+// SetMemoryQueue keeps facts that cannot be embedded in q instead of dropping
+// them, and retries storing them every retryInterval until Shutdown.
+func (h *Handler) SetMemoryQueue(q *memory.EmbedQueue, retryInterval time.Duration) {
+	h.memoryQueue = q
+	go h.retryQueuedMemories(retryInterval)
+}
+
+// minProfileConfidence is how sure the extractor must be of a keyed fact
+// before it overwrites a profile field.
+const minProfileConfidence = 0.7
+
+// storeMemory embeds fact and adds it to the user's long-term memory, or
+// queues it if the embedding API is unavailable. A confident keyed fact also
+// updates the user's profile right away. It only fails if the fact could
+// neither be stored nor queued.
+func (h *Handler) storeMemory(ctx context.Context, userID string, fact memory.Memory) error {
+	if fact.Key != "" && fact.Confidence >= minProfileConfidence {
+		if err := h.memoryStore.UpdateProfile(ctx, userID, map[string]string{fact.Key: fact.Value}); err != nil {
+			log.Printf("Error updating profile: %v", err)
+		}
+	}
+
+	vector, err := h.embedForStorage(ctx, fact.Text)
+	if err != nil {
+		if h.memoryQueue == nil || ctx.Err() != nil {
+			return fmt.Errorf("failed to embed memory: %w", err)
+		}
+		log.Printf("Error embedding memory, queueing it for later: %v", err)
+		h.memoryQueue.Push(userID, fact)
+		return nil
+	}
+
+	log.Printf("Storing new %s memory for user %s: %s", fact.Category, userID, fact.Text)
+	if err := h.addMemory(ctx, userID, fact, vector); err != nil {
+		return fmt.Errorf("failed to store memory: %w", err)
+	}
+	return nil
+}
+
+// addMemory adds fact to the user's long-term memory. If the store finds it
+// too close to a stored memory, the two are compared instead: a duplicate is
+// dropped, a refinement is merged into the stored memory, and a fact that
+// supersedes or contradicts it replaces it. Replaced versions are kept in
+// the memory's history.
+func (h *Handler) addMemory(ctx context.Context, userID string, fact memory.Memory, vector []float32) error {
+	err := h.memoryStore.Add(ctx, userID, fact, vector)
+	var dup *memory.DuplicateError
+	if !errors.As(err, &dup) {
+		return err
+	}
+
+	relation, merged := h.relateMemories(ctx, dup)
+	switch relation {
+	case memory.RelationDuplicate:
+		log.Printf("Skipping duplicate memory: %v", err)
+		return nil
+	case memory.RelationRefines:
+		existing := dup.Existing
+		fact.Text = merged
+		if fact.Key == "" {
+			fact.Key, fact.Value = existing.Key, existing.Value
+		}
+		if mergedVector, err := h.embedForStorage(ctx, merged); err != nil {
+			log.Printf("Error embedding merged memory, keeping the new fact's vector: %v", err)
+		} else {
+			vector = mergedVector
+		}
+	}
+
+	log.Printf("Revising memory %s for user %s (%s): %q -> %q", dup.ID, userID, relation, dup.Existing.Text, fact.Text)
+	return h.memoryStore.Revise(ctx, userID, dup.ID, fact, vector, relation)
+}
+
+// relateMemories decides how the new fact of dup relates to the stored
+// memory: by their values when both fill the same profile field, or else by
+// asking the LLM. If that fails or the budget is used up, the new fact is
+// treated as a duplicate, so nothing stored is lost.
+func (h *Handler) relateMemories(ctx context.Context, dup *memory.DuplicateError) (relation, merged string) {
+	if relation, ok := memory.KeyedRelation(dup.Existing, dup.New); ok {
+		return relation, ""
+	}
+	if budgetErr := h.overBudget(ctx); budgetErr != nil {
+		log.Printf("Not comparing memories: %v", budgetErr)
+		return memory.RelationDuplicate, ""
+	}
+	relation, merged, err := h.compareMemories(ctx, dup.Existing.Text, dup.New.Text)
+	if err != nil {
+		log.Printf("Error comparing memories: %v", err)
+		return memory.RelationDuplicate, ""
+	}
+	return relation, merged
+}
+
+// revisingStore makes the memory queue resolve duplicates like addMemory
+type revisingStore struct {
+	memory.Store
+	h *Handler
+}
+
+func (s revisingStore) Add(ctx context.Context, userID string, m memory.Memory, vector []float32) error {
+	return s.h.addMemory(ctx, userID, m, vector)
+}
+
+// embedForStorage embeds text without falling back to local embeddings.
+func (h *Handler) embedForStorage(ctx context.Context, text string) ([]float32, error) {
+	if pe, ok := h.embeddingClient.(PrimaryEmbedder); ok {
+		return pe.EmbedPrimary(ctx, text)
+	}
+	return h.embeddingClient.Embed(ctx, text)
+}
+
+// storageEmbedder lets the memory queue embed through embedForStorage
+type storageEmbedder struct {
+	h *Handler
+}
+
+func (e storageEmbedder) Embed(ctx context.Context, text string) ([]float32, error) {
+	return e.h.embedForStorage(ctx, text)
+}
+
+func (h *Handler) retryQueuedMemories(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if h.memoryQueue.Len() == 0 {
+			continue
+		}
+		if n := h.memoryQueue.Flush(h.ctx, storageEmbedder{h}, revisingStore{h.memoryStore, h}); n > 0 {
+			log.Printf("Stored %d queued memories, %d still queued", n, h.memoryQueue.Len())
+		}
+	}
+}
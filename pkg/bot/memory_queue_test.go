@@ -0,0 +1,183 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ninoai/pkg/cerebras"
+	"ninoai/pkg/memory"
+)
+
+// mockFallbackEmbedder serves local vectors through Embed while its API is down
+type mockFallbackEmbedder struct {
+	mockEmbeddingClient
+	apiDown bool
+}
+
+func (m *mockFallbackEmbedder) EmbedPrimary(ctx context.Context, text string) ([]float32, error) {
+	if m.apiDown {
+		return nil, errors.New("api down")
+	}
+	return m.Embed(ctx, text)
+}
+
+func TestHandler_QueuesMemoriesWhileEmbeddingIsDown(t *testing.T) {
+	client := &mockCerebrasClient{
+		ChatCompletionJSONFunc: func(messages []cerebras.Message, schema *cerebras.JSONSchema) (string, error) {
+			return `{"memories": [{"text": "Likes green tea", "category": "preference", "key": "favorite_drink", "value": "green tea", "confidence": 0.9}]}`, nil
+		},
+	}
+	embedder := &mockFallbackEmbedder{apiDown: true}
+	var stored []memory.Memory
+	var profile map[string]string
+	store := &mockMemoryStore{
+		AddFunc: func(userId string, m memory.Memory, vector []float32) error {
+			stored = append(stored, m)
+			return nil
+		},
+		UpdateProfileFunc: func(userId string, fields map[string]string) error {
+			profile = fields
+			return nil
+		},
+	}
+
+	handler := NewHandler(client, &MockClassifier{}, embedder, store, 0)
+	handler.SetBotID("testbot")
+	queue := memory.NewEmbedQueue(filepath.Join(t.TempDir(), "embed_queue.json"))
+	handler.SetMemoryQueue(queue, time.Hour)
+	defer handler.Shutdown()
+
+	handler.HandleMessage(&MockSession{}, mentionMessage("I really like green tea"))
+	handler.WaitForReady()
+
+	if len(stored) != 0 || queue.Len() != 1 {
+		t.Fatalf("expected the memory to be queued, got stored %v and %d queued", stored, queue.Len())
+	}
+	// The profile doesn't need an embedding, so it is updated right away
+	if profile["favorite_drink"] != "green tea" {
+		t.Errorf("expected the profile to be updated, got %v", profile)
+	}
+
+	// Once the API is back the queued fact is stored
+	embedder.apiDown = false
+	if n := queue.Flush(context.Background(), storageEmbedder{handler}, store); n != 1 || len(stored) != 1 {
+		t.Fatalf("Flush() = %d, stored %v", n, stored)
+	}
+	if m := stored[0]; m.Category != memory.CategoryPreference || m.SourceChannelID != "test_channel" {
+		t.Errorf("expected the queued memory to keep its category and source, got %+v", m)
+	}
+
+	// Resetting a user's memory also drops what is still queued
+	queue.Push("user123", memory.Memory{Text: "Has a cat"})
+	handler.ResetMemory(context.Background(), "user123")
+	if queue.Len() != 0 {
+		t.Errorf("expected ResetMemory to clear queued memories, %d left", queue.Len())
+	}
+}
+
+func TestHandler_IncludesProfileInPrompt(t *testing.T) {
+	var prompt []cerebras.Message
+	client := &mockCerebrasClient{
+		ChatCompletionFunc: func(messages []cerebras.Message) (string, error) {
+			prompt = messages
+			return "hmph, i know", nil
+		},
+	}
+	store := &mockMemoryStore{
+		GetProfileFunc: func(userId string) (memory.Profile, error) {
+			return memory.Profile{Fields: map[string]string{"name": "Alex", "favorite_drink": "green tea"}}, nil
+		},
+	}
+
+	handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, store, 0)
+	handler.SetBotID("testbot")
+	handler.HandleMessage(&MockSession{}, mentionMessage("what's my name?"))
+	handler.WaitForReady()
+
+	if len(prompt) < 2 {
+		t.Fatalf("expected a reply prompt, got %v", prompt)
+	}
+	want := "What you know about testuser:\n- favorite drink: green tea\n- name: Alex"
+	if prompt[1].Role != "system" || prompt[1].Content != want {
+		t.Errorf("expected the profile after the system prompt, got %+v", prompt[1])
+	}
+}
+
+func TestHandler_RevisesCloseMemories(t *testing.T) {
+	stored := memory.Memory{Text: "Has a cat", Category: memory.CategoryRelationship}
+	tests := []struct {
+		name         string
+		fact         memory.Memory
+		existing     memory.Memory
+		comparison   string
+		wantRelation string // Empty if the memory isn't revised
+		wantText     string
+	}{
+		{
+			name:         "Refines",
+			fact:         memory.Memory{Text: "Has a cat named Mochi", Category: memory.CategoryRelationship},
+			existing:     stored,
+			comparison:   `{"relation": "refines", "merged": "Has a cat named Mochi"}`,
+			wantRelation: memory.RelationRefines,
+			wantText:     "Has a cat named Mochi",
+		},
+		{
+			name:       "Duplicate",
+			fact:       memory.Memory{Text: "Owns a cat"},
+			existing:   stored,
+			comparison: `{"relation": "duplicate", "merged": ""}`,
+		},
+		{
+			name:       "Comparison fails",
+			fact:       memory.Memory{Text: "Has no cat"},
+			existing:   stored,
+			comparison: `not json`,
+		},
+		{
+			// Facts for the same profile field are related without the LLM
+			name:         "Same key",
+			fact:         memory.Memory{Text: "Lives in Berlin", Key: "city", Value: "Berlin"},
+			existing:     memory.Memory{Text: "Lives in Paris", Key: "city", Value: "Paris"},
+			wantRelation: memory.RelationSupersedes,
+			wantText:     "Lives in Berlin",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compared := false
+			client := &mockCerebrasClient{
+				ChatCompletionJSONFunc: func(messages []cerebras.Message, schema *cerebras.JSONSchema) (string, error) {
+					compared = true
+					return tt.comparison, nil
+				},
+			}
+			var relation, text string
+			store := &mockMemoryStore{
+				AddFunc: func(userId string, m memory.Memory, vector []float32) error {
+					return &memory.DuplicateError{ID: "m1", Existing: tt.existing, New: m, Similarity: 0.9}
+				},
+				ReviseFunc: func(userId, id string, m memory.Memory, vector []float32, rel string) error {
+					if id != "m1" {
+						t.Errorf("revised memory %q, want m1", id)
+					}
+					relation, text = rel, m.Text
+					return nil
+				},
+			}
+
+			handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, store, 0)
+			if err := handler.addMemory(context.Background(), "user123", tt.fact, []float32{1, 0, 0}); err != nil {
+				t.Fatalf("addMemory() error = %v", err)
+			}
+			if relation != tt.wantRelation || text != tt.wantText {
+				t.Errorf("revised with %q to %q, want %q to %q", relation, text, tt.wantRelation, tt.wantText)
+			}
+			if compared != (tt.comparison != "") {
+				t.Errorf("expected the LLM to be asked only without a shared key, asked: %v", compared)
+			}
+		})
+	}
+}
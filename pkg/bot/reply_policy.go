@@ -0,0 +1,129 @@
+package bot
+
+import (
+	"ninoai/pkg/classifier"
+	"ninoai/pkg/config"
+)
+
+// ReplyTrigger is a label untagged messages are classified with, and the
+// score at which it makes Nino reply.
+type ReplyTrigger struct {
+	Label     string
+	Threshold float64 // 0 never triggers a reply
+}
+
+// ReplyPolicy decides whether Nino answers a guild message that doesn't
+// mention her. Unset fields are inherited; see ReplyPolicies.
+type ReplyPolicy struct {
+	Mode        string   // config.ReplyModeClassify or config.ReplyModeMentionsOnly
+	Probability *float64 // Chance of replying once a trigger fires
+	Triggers    []ReplyTrigger
+}
+
+// ReplyPolicies holds the default policy and its overrides. A message's
+// policy is its channel's override, falling back field by field to its
+// guild's, then to Default, then to the built-in defaults.
+type ReplyPolicies struct {
+	Default  ReplyPolicy
+	Guilds   map[string]ReplyPolicy // Guild ID -> override
+	Channels map[string]ReplyPolicy // Channel ID -> override
+}
+
+// defaultReplyTriggers match Nino's personality. The casual label gives
+// ordinary chatter somewhere to go, so that it doesn't inflate the other
+// scores.
+var defaultReplyTriggers = []ReplyTrigger{
+	{Label: "message directly addressing Nino or Nakano", Threshold: 0.5},
+	{Label: "discussion about cooking or food", Threshold: 0.6},
+	{Label: "discussion about fashion or appearance", Threshold: 0.6},
+	{Label: "discussion about romance or relationships", Threshold: 0.6},
+	{Label: "someone being pathetic", Threshold: 0.7},
+	{Label: "someone not taking care of themselves (health, sleep, eating)", Threshold: 0.6},
+	{Label: "casual conversation or blank message without mention of nino", Threshold: 0},
+}
+
+// Resolve returns the complete policy for a message in channelID of guildID.
+func (p ReplyPolicies) Resolve(guildID, channelID string) ReplyPolicy {
+	always := 1.0
+	policy := ReplyPolicy{
+		Mode:        config.ReplyModeClassify,
+		Probability: &always,
+		Triggers:    defaultReplyTriggers,
+	}
+	policy = policy.override(p.Default)
+	if guild, ok := p.Guilds[guildID]; ok {
+		policy = policy.override(guild)
+	}
+	if channel, ok := p.Channels[channelID]; ok {
+		policy = policy.override(channel)
+	}
+	return policy
+}
+
+// override returns p with the fields set in o replaced.
+func (p ReplyPolicy) override(o ReplyPolicy) ReplyPolicy {
+	if o.Mode != "" {
+		p.Mode = o.Mode
+	}
+	if o.Probability != nil {
+		p.Probability = o.Probability
+	}
+	if len(o.Triggers) > 0 {
+		p.Triggers = o.Triggers
+	}
+	return p
+}
+
+// Classifies reports whether the policy needs the message classified at all.
+func (p ReplyPolicy) Classifies() bool {
+	return p.Mode != config.ReplyModeMentionsOnly
+}
+
+// Labels returns the labels to classify messages with.
+func (p ReplyPolicy) Labels() []string {
+	labels := make([]string, len(p.Triggers))
+	for i, t := range p.Triggers {
+		labels[i] = t.Label
+	}
+	return labels
+}
+
+// Trigger returns the highest scoring label at or above its threshold, if
+// any. Each label is judged on its own, so this works with multi-label
+// scores as well as with a distribution.
+func (p ReplyPolicy) Trigger(results []classifier.ClassificationResult) (classifier.ClassificationResult, bool) {
+	thresholds := make(map[string]float64, len(p.Triggers))
+	for _, t := range p.Triggers {
+		thresholds[t.Label] = t.Threshold
+	}
+
+	for _, r := range results {
+		// Results are sorted, so the first match scores highest
+		if threshold := thresholds[r.Label]; threshold > 0 && r.Score >= threshold {
+			return r, true
+		}
+	}
+	return classifier.ClassificationResult{}, false
+}
+
+// Decide reports whether to reply given the classification of a message:
+// a trigger must fire, and then the dice must agree. roll returns a number
+// in [0, 1), like rand.Float64.
+func (p ReplyPolicy) Decide(results []classifier.ClassificationResult, roll func() float64) (bool, classifier.ClassificationResult) {
+	if !p.Classifies() {
+		return false, classifier.ClassificationResult{}
+	}
+	trigger, ok := p.Trigger(results)
+	if !ok {
+		return false, trigger
+	}
+	if p.Probability != nil && roll() >= *p.Probability {
+		return false, trigger
+	}
+	return true, trigger
+}
+
+// SetReplyPolicies replaces the built-in reply policy.
+func (h *Handler) SetReplyPolicies(p ReplyPolicies) {
+	h.replyPolicies = p
+}
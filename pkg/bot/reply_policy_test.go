@@ -0,0 +1,175 @@
+package bot
+
+import (
+	"context"
+	"testing"
+
+	"ninoai/pkg/classifier"
+	"ninoai/pkg/config"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func result(label string, score float64) classifier.ClassificationResult {
+	return classifier.ClassificationResult{Label: label, Score: score}
+}
+
+func probability(p float64) *float64 {
+	return &p
+}
+
+func TestReplyPolicyTrigger(t *testing.T) {
+	const (
+		food   = "discussion about cooking or food"
+		casual = "casual conversation or blank message without mention of nino"
+		sad    = "someone being pathetic"
+	)
+	policy := ReplyPolicies{}.Resolve("guild", "channel")
+
+	tests := []struct {
+		name      string
+		results   []classifier.ClassificationResult
+		want      bool
+		wantLabel string
+	}{
+		{
+			name:      "Trigger over its threshold",
+			results:   []classifier.ClassificationResult{result(food, 0.65), result(casual, 0.3)},
+			want:      true,
+			wantLabel: food,
+		},
+		{
+			name:    "Trigger under its threshold",
+			results: []classifier.ClassificationResult{result(sad, 0.65), result(casual, 0.3)},
+		},
+		{
+			name:    "Casual conversation never triggers",
+			results: []classifier.ClassificationResult{result(casual, 0.95), result(food, 0.05)},
+		},
+		{
+			name:      "Lower scoring label over its threshold",
+			results:   []classifier.ClassificationResult{result(casual, 0.9), result(food, 0.7)},
+			want:      true,
+			wantLabel: food,
+		},
+		{
+			name:    "Unknown label",
+			results: []classifier.ClassificationResult{result("something else", 0.99)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trigger, got := policy.Trigger(tt.results)
+			if got != tt.want || trigger.Label != tt.wantLabel {
+				t.Errorf("Trigger() = %q, %v; want %q, %v", trigger.Label, got, tt.wantLabel, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplyPoliciesResolve(t *testing.T) {
+	triggers := []ReplyTrigger{{Label: "cats", Threshold: 0.5}, {Label: "other", Threshold: 0}}
+	policies := ReplyPolicies{
+		Default: ReplyPolicy{Probability: probability(0.5)},
+		Guilds: map[string]ReplyPolicy{
+			"quiet": {Mode: config.ReplyModeMentionsOnly},
+			"cats":  {Triggers: triggers},
+		},
+		Channels: map[string]ReplyPolicy{
+			"loud": {Mode: config.ReplyModeClassify, Probability: probability(1)},
+		},
+	}
+
+	p := policies.Resolve("other", "general")
+	if p.Mode != config.ReplyModeClassify || *p.Probability != 0.5 || len(p.Triggers) != len(defaultReplyTriggers) {
+		t.Errorf("expected the default policy with built-in triggers, got %+v", p)
+	}
+
+	p = policies.Resolve("quiet", "general")
+	if p.Classifies() || *p.Probability != 0.5 {
+		t.Errorf("expected the guild to only reply to mentions, got %+v", p)
+	}
+
+	p = policies.Resolve("quiet", "loud")
+	if !p.Classifies() || *p.Probability != 1 {
+		t.Errorf("expected the channel override to win over its guild, got %+v", p)
+	}
+
+	p = policies.Resolve("cats", "general")
+	if labels := p.Labels(); len(labels) != 2 || labels[0] != "cats" || *p.Probability != 0.5 {
+		t.Errorf("expected the guild's triggers and the default probability, got %+v", p)
+	}
+}
+
+func TestReplyPolicyDecide(t *testing.T) {
+	results := []classifier.ClassificationResult{result("cats", 0.8), result("other", 0.2)}
+	triggers := []ReplyTrigger{{Label: "cats", Threshold: 0.5}, {Label: "other", Threshold: 0}}
+	roll := func(n float64) func() float64 { return func() float64 { return n } }
+
+	tests := []struct {
+		name   string
+		policy ReplyPolicy
+		roll   float64
+		want   bool
+	}{
+		{name: "Always", policy: ReplyPolicy{Probability: probability(1), Triggers: triggers}, roll: 0.99, want: true},
+		{name: "Lucky roll", policy: ReplyPolicy{Probability: probability(0.3), Triggers: triggers}, roll: 0.2, want: true},
+		{name: "Unlucky roll", policy: ReplyPolicy{Probability: probability(0.3), Triggers: triggers}, roll: 0.3},
+		{name: "Never", policy: ReplyPolicy{Probability: probability(0), Triggers: triggers}, roll: 0},
+		{name: "Mentions only", policy: ReplyPolicy{Mode: config.ReplyModeMentionsOnly, Probability: probability(1), Triggers: triggers}},
+		{name: "Nothing triggered", policy: ReplyPolicy{Probability: probability(1), Triggers: []ReplyTrigger{{Label: "cats", Threshold: 0.9}}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, _ := tt.policy.Decide(results, roll(tt.roll)); got != tt.want {
+				t.Errorf("Decide() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// countingClassifier scores the first label highly and counts its calls
+type countingClassifier struct {
+	MockClassifier
+	calls int
+}
+
+func (c *countingClassifier) ClassifyAll(ctx context.Context, text string, labels []string) ([]classifier.ClassificationResult, error) {
+	c.calls++
+	return c.MockClassifier.ClassifyAll(ctx, text, labels)
+}
+
+func TestHandler_ReplyPolicy(t *testing.T) {
+	untagged := func(guildID string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID:        "msg_" + guildID,
+			GuildID:   guildID,
+			ChannelID: "test_channel",
+			Author:    &discordgo.User{ID: "user123", Username: "testuser"},
+			Content:   "Nino, what should I cook?",
+		}}
+	}
+
+	cl := &countingClassifier{}
+	handler := NewHandler(&mockCerebrasClient{}, cl, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	handler.SetBotID("testbot")
+	handler.SetReplyPolicies(ReplyPolicies{Guilds: map[string]ReplyPolicy{
+		"quiet": {Mode: config.ReplyModeMentionsOnly},
+	}})
+
+	session := &MockSession{}
+	handler.HandleMessage(session, untagged("quiet"))
+	handler.WaitForReady()
+	if cl.calls != 0 || len(session.SentMessages) != 0 {
+		t.Errorf("expected no classification or reply in a mentions-only guild, got %d calls and %v", cl.calls, session.SentMessages)
+	}
+
+	handler.HandleMessage(session, untagged("chatty"))
+	handler.WaitForReady()
+	// The reply decision and the task check share one classification
+	if cl.calls != 1 || len(session.SentMessages) != 1 {
+		t.Errorf("expected a reply classified once elsewhere, got %d calls and %v", cl.calls, session.SentMessages)
+	}
+}
@@ -1,39 +1,125 @@
 package bot
 
 import (
+	"fmt"
 	"log"
+	"strings"
+
+	"ninoai/pkg/memory"
+	"ninoai/pkg/usage"
 
 	"github.com/bwmarrin/discordgo"
 )
 
+// adminPermissions restricts a command to server administrators by default
+var adminPermissions int64 = discordgo.PermissionAdministrator
+
+// guildOnly keeps a command out of DMs, where member permissions don't apply
+var guildOnly = []discordgo.InteractionContextType{discordgo.InteractionContextGuild}
+
+// minUsageDays is the smallest range /usage accepts
+var minUsageDays float64 = 1
+
+// maxRememberLength caps the length of a fact given to /remember
+var maxRememberLength = 300
+
+// usageReportRows is the number of entries listed per breakdown in /usage
+const usageReportRows = 5
+
 // SlashCommands defines all available slash commands
 var SlashCommands = []*discordgo.ApplicationCommand{
 	{
 		Name:        "reset",
 		Description: "Reset your conversation memory with Nino",
 	},
+	{
+		Name:        "memories",
+		Description: "List what Nino remembers about you",
+	},
+	{
+		Name:        "forget",
+		Description: "Make Nino forget something she remembers about you",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:         discordgo.ApplicationCommandOptionString,
+				Name:         "memory",
+				Description:  "The memory to forget",
+				Required:     true,
+				Autocomplete: true,
+			},
+		},
+	},
+	{
+		Name:        "remember",
+		Description: "Tell Nino something to remember about you",
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "fact",
+				Description: "What to remember, e.g. \"Has a cat named Mochi\"",
+				Required:    true,
+				MaxLength:   maxRememberLength,
+			},
+			{
+				Type:        discordgo.ApplicationCommandOptionString,
+				Name:        "category",
+				Description: "What kind of fact it is",
+				Choices:     categoryChoices(),
+			},
+		},
+	},
+	{
+		Name:                     "usage",
+		Description:              "Show this server's LLM token usage by user and model (admin only)",
+		DefaultMemberPermissions: &adminPermissions,
+		Contexts:                 &guildOnly,
+		Options: []*discordgo.ApplicationCommandOption{
+			{
+				Type:        discordgo.ApplicationCommandOptionInteger,
+				Name:        "days",
+				Description: "Number of days to include, counting today (default 1)",
+				MinValue:    &minUsageDays,
+				MaxValue:    90,
+			},
+		},
+	},
 }
 
 // SlashCommandHandlers maps command names to their handler functions
 var SlashCommandHandlers = map[string]func(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate){
-	"reset": handleResetCommand,
+	"reset":    handleResetCommand,
+	"memories": handleMemoriesCommand,
+	"forget":   handleForgetCommand,
+	"remember": handleRememberCommand,
+	"usage":    handleUsageCommand,
+}
+
+// AutocompleteHandlers maps command names to the handlers suggesting values
+// for their options
+var AutocompleteHandlers = map[string]func(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate){
+	"forget": handleForgetAutocomplete,
+}
+
+// categoryChoices offers the memory categories as option choices
+func categoryChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(memory.Categories))
+	for i, category := range memory.Categories {
+		choices[i] = &discordgo.ApplicationCommandOptionChoice{Name: category, Value: category}
+	}
+	return choices
 }
 
 // handleResetCommand handles the /reset slash command
 func handleResetCommand(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
 	// Get user ID (works for both guild and DM contexts)
-	var userID string
-	if i.Member != nil {
-		userID = i.Member.User.ID
-	} else if i.User != nil {
-		userID = i.User.ID
-	} else {
+	userID := interactionUserID(i)
+	if userID == "" {
 		log.Printf("Error: Could not determine user ID for reset command")
 		return
 	}
 
 	// Reset the user's memory
-	err := h.ResetMemory(userID)
+	err := h.ResetMemory(h.ctx, userID)
 
 	responseContent := "Memory reset! Starting fresh. 💭✨"
 	if err != nil {
@@ -55,20 +141,113 @@ func handleResetCommand(h *Handler, s *discordgo.Session, i *discordgo.Interacti
 	}
 }
 
-// InteractionCreate handles all slash command interactions
-func (h *Handler) InteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
-	// Only handle application commands (slash commands)
-	if i.Type != discordgo.InteractionApplicationCommand {
+// handleUsageCommand handles the /usage slash command. It only reports the
+// usage of the server it is run in, so that admins of one server don't see
+// what users of another spend.
+func handleUsageCommand(h *Handler, s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if i.GuildID == "" {
+		respondEphemeral(s, i, "Usage can only be shown in a server.")
 		return
 	}
 
-	commandName := i.ApplicationCommandData().Name
+	days := 1
+	for _, opt := range i.ApplicationCommandData().Options {
+		if opt.Name == "days" {
+			days = int(opt.IntValue())
+		}
+	}
 
-	// Find and execute the appropriate handler
-	if handler, ok := SlashCommandHandlers[commandName]; ok {
-		handler(h, s, i)
+	responseContent := "Usage tracking is disabled."
+	if h.usageTracker != nil {
+		responseContent = formatUsageReport(h.usageTracker.GuildSummary(days, i.GuildID), days)
+	}
+
+	err := s.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: responseContent,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+
+	if err != nil {
+		log.Printf("Error responding to usage command: %v", err)
+	}
+}
+
+// formatUsageReport renders a server's usage summary as a Discord message
+func formatUsageReport(summary usage.Summary, days int) string {
+	var sb strings.Builder
+	if days == 1 {
+		sb.WriteString("**Token usage in this server today (UTC)**\n")
 	} else {
-		log.Printf("Unknown slash command: %s", commandName)
+		fmt.Fprintf(&sb, "**Token usage in this server over the last %d days (UTC)**\n", days)
+	}
+	fmt.Fprintf(&sb, "%d tokens in %d calls (%d prompt, %d completion)\n",
+		summary.Total.Tokens(), summary.Total.Calls, summary.Total.PromptTokens, summary.Total.CompletionTokens)
+	if summary.Total.Calls == 0 {
+		return sb.String()
+	}
+
+	writeRanked(&sb, "Top users", summary.ByUser, func(key string) string {
+		if key == "" {
+			return "unattributed"
+		}
+		return "<@" + key + ">"
+	})
+	writeRanked(&sb, "Models", summary.ByModel, func(key string) string { return key })
+	writeRanked(&sb, "Call kinds", summary.ByKind, func(key string) string {
+		if key == "" {
+			return "other"
+		}
+		return key
+	})
+	return sb.String()
+}
+
+func writeRanked(sb *strings.Builder, title string, ranked []usage.Ranked, label func(string) string) {
+	fmt.Fprintf(sb, "\n**%s**\n", title)
+	for i, r := range ranked {
+		if i == usageReportRows {
+			fmt.Fprintf(sb, "…and %d more\n", len(ranked)-usageReportRows)
+			break
+		}
+		fmt.Fprintf(sb, "%d. %s: %d tokens (%d calls)\n", i+1, label(r.Key), r.Tokens(), r.Calls)
+	}
+}
+
+// InteractionCreate handles slash commands, their autocompletion and the
+// buttons of their responses
+func (h *Handler) InteractionCreate(s *discordgo.Session, i *discordgo.InteractionCreate) {
+	if !h.begin() {
+		return
+	}
+	defer h.wg.Done()
+
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		commandName := i.ApplicationCommandData().Name
+
+		// Find and execute the appropriate handler
+		if handler, ok := SlashCommandHandlers[commandName]; ok {
+			handler(h, s, i)
+		} else {
+			log.Printf("Unknown slash command: %s", commandName)
+		}
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		commandName := i.ApplicationCommandData().Name
+		if handler, ok := AutocompleteHandlers[commandName]; ok {
+			handler(h, s, i)
+		} else {
+			log.Printf("Unknown autocomplete command: %s", commandName)
+		}
+	case discordgo.InteractionMessageComponent:
+		customID := i.MessageComponentData().CustomID
+		if strings.HasPrefix(customID, memoriesPagePrefix) {
+			handleMemoriesPageButton(h, s, i)
+		} else {
+			log.Printf("Unknown component: %s", customID)
+		}
 	}
 }
 
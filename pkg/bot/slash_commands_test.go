@@ -0,0 +1,29 @@
+package bot
+
+import (
+	"strings"
+	"testing"
+
+	"ninoai/pkg/usage"
+)
+
+func TestFormatUsageReport(t *testing.T) {
+	summary := usage.Summary{
+		Total: usage.Totals{Calls: 3, PromptTokens: 700, CompletionTokens: 70},
+		ByUser: []usage.Ranked{
+			{Key: "123", Totals: usage.Totals{Calls: 1, PromptTokens: 500, CompletionTokens: 50}},
+			{Key: "", Totals: usage.Totals{Calls: 2, PromptTokens: 200, CompletionTokens: 20}},
+		},
+	}
+
+	report := formatUsageReport(summary, 7)
+	for _, want := range []string{"last 7 days", "770 tokens in 3 calls", "1. <@123>: 550 tokens", "2. unattributed"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report is missing %q:\n%s", want, report)
+		}
+	}
+
+	if empty := formatUsageReport(usage.Summary{}, 1); strings.Contains(empty, "Top users") {
+		t.Errorf("expected no breakdowns without usage:\n%s", empty)
+	}
+}
@@ -0,0 +1,128 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"ninoai/pkg/cerebras"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// StreamingClient is implemented by LLM clients that can deliver a reply
+// while it is being generated.
+type StreamingClient interface {
+	ChatCompletionStream(ctx context.Context, messages []cerebras.Message) (<-chan cerebras.StreamChunk, error)
+}
+
+// EnableStreaming makes the handler post replies as soon as the first tokens
+// arrive and edit the message as the rest streams in. Edits are throttled to at
+// most one per editInterval to stay clear of Discord's rate limits.
+func (h *Handler) EnableStreaming(editInterval time.Duration) {
+	h.streamEditInterval = editInterval
+	h.streaming = true
+}
+
+// streamReply posts the reply streamed on chunks as it comes in, split into
+// messages the way sendSplitMessage splits a complete reply: a paragraph is
+// posted as soon as it starts and edited while it grows. It returns the full
+// cleaned reply together with any tool calls the model made. An error is only
+// returned if nothing could be shown to the user and no tools were called.
+// chunks is always drained, even when streamReply gives up early.
+func (h *Handler) streamReply(s Session, channelID string, chunks <-chan cerebras.StreamChunk, reference *discordgo.MessageReference) (string, []cerebras.ToolCall, error) {
+	var raw strings.Builder
+	var calls []cerebras.ToolCall
+	var sent []*discordgo.Message
+	var shown []string
+	var lastEdit time.Time
+	var streamErr, sendErr error
+
+	// show brings the posted messages up to date with parts. New parts are
+	// posted right away and finished parts edited into their final text, but
+	// the part still growing is only edited once per streamEditInterval
+	// unless final is set.
+	show := func(parts []string, final bool) error {
+		for k, part := range parts {
+			if k >= len(sent) {
+				msg, err := sendPart(s, channelID, part, reference, k == 0)
+				if err != nil {
+					return err
+				}
+				sent = append(sent, msg)
+				shown = append(shown, part)
+				lastEdit = time.Now()
+				continue
+			}
+			if part == shown[k] {
+				continue
+			}
+			if k == len(parts)-1 && !final && time.Since(lastEdit) < h.streamEditInterval {
+				continue
+			}
+			if _, err := s.ChannelMessageEdit(channelID, sent[k].ID, part); err != nil {
+				log.Printf("Error editing streamed reply: %v", err)
+			}
+			shown[k] = part
+			lastEdit = time.Now()
+		}
+		return nil
+	}
+
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+			continue
+		}
+		calls = append(calls, chunk.ToolCalls...)
+		raw.WriteString(chunk.Content)
+
+		if sendErr != nil {
+			// Keep reading so that the reply is complete and chunks drained
+			continue
+		}
+		if sendErr = show(splitMessage(raw.String()), false); sendErr != nil {
+			if len(sent) == 0 {
+				go drain(chunks)
+				return "", nil, fmt.Errorf("failed to send streamed reply: %w", sendErr)
+			}
+			log.Printf("Error sending streamed reply: %v", sendErr)
+		}
+	}
+
+	reply := cerebras.CleanResponse(raw.String())
+	if streamErr != nil {
+		log.Printf("Stream ended with error: %v", streamErr)
+		if len(sent) == 0 {
+			return "", nil, streamErr
+		}
+	}
+
+	if len(sent) == 0 {
+		if reply == "" {
+			if len(calls) > 0 {
+				return "", calls, nil
+			}
+			return "", nil, fmt.Errorf("empty streamed reply")
+		}
+		h.sendSplitMessage(s, channelID, reply, reference)
+		return reply, calls, nil
+	}
+
+	// Final edits so the messages match the cleaned, complete reply
+	if sendErr == nil {
+		if err := show(splitMessage(reply), true); err != nil {
+			log.Printf("Error sending streamed reply: %v", err)
+		}
+	}
+
+	return reply, calls, nil
+}
+
+// drain discards what is left on chunks so that the stream can finish.
+func drain(chunks <-chan cerebras.StreamChunk) {
+	for range chunks {
+	}
+}
@@ -0,0 +1,214 @@
+package bot
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"ninoai/pkg/cerebras"
+	"ninoai/pkg/memory"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mockStreamingClient streams the configured pieces one chunk at a time
+type mockStreamingClient struct {
+	mockCerebrasClient
+	Pieces []string
+}
+
+func (m *mockStreamingClient) ChatCompletionStream(_ context.Context, messages []cerebras.Message) (<-chan cerebras.StreamChunk, error) {
+	chunks := make(chan cerebras.StreamChunk)
+	go func() {
+		defer close(chunks)
+		for _, p := range m.Pieces {
+			chunks <- cerebras.StreamChunk{Content: p}
+		}
+	}()
+	return chunks, nil
+}
+
+// gatedStreamingClient streams a first piece, then waits for release before
+// streaming the second, unless the request is cancelled first
+type gatedStreamingClient struct {
+	mockCerebrasClient
+	started, release chan struct{}
+}
+
+func (m *gatedStreamingClient) ChatCompletionStream(ctx context.Context, messages []cerebras.Message) (<-chan cerebras.StreamChunk, error) {
+	chunks := make(chan cerebras.StreamChunk)
+	go func() {
+		defer close(chunks)
+		chunks <- cerebras.StreamChunk{Content: "ugh, "}
+		close(m.started)
+		select {
+		case <-m.release:
+			chunks <- cerebras.StreamChunk{Content: "fine."}
+		case <-ctx.Done():
+			chunks <- cerebras.StreamChunk{Err: ctx.Err()}
+		}
+	}()
+	return chunks, nil
+}
+
+func TestHandler_StreamingReply(t *testing.T) {
+	client := &mockStreamingClient{
+		Pieces: []string{"ugh, ", "fine. you ", "like tea?"},
+	}
+	client.ChatCompletionJSONFunc = func(messages []cerebras.Message, schema *cerebras.JSONSchema) (string, error) {
+		if schema.Name != "memory_extraction" {
+			return `{"emojis": []}`, nil
+		}
+		return `{"memories": [{"text": "Likes green tea", "category": "preference", "key": "", "value": "", "confidence": 0.8}]}`, nil
+	}
+
+	var storedMemory string
+	store := &mockMemoryStore{
+		AddFunc: func(userId string, m memory.Memory, vector []float32) error {
+			storedMemory = m.Text
+			return nil
+		},
+	}
+
+	handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, store, 0)
+	handler.SetBotID("testbot")
+	handler.EnableStreaming(0)
+
+	session := &MockSession{}
+	handler.HandleMessage(session, &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ChannelID: "test_channel",
+			Author:    &discordgo.User{ID: "user123", Username: "testuser"},
+			Content:   "I really like green tea",
+			Mentions:  []*discordgo.User{{ID: "testbot"}},
+		},
+	})
+	handler.WaitForReady()
+
+	if len(session.SentMessages) != 1 {
+		t.Fatalf("expected a single posted message, got %d: %v", len(session.SentMessages), session.SentMessages)
+	}
+	if len(session.EditedMessages) == 0 {
+		t.Fatal("expected the posted message to be edited as the reply streamed in")
+	}
+
+	final := session.EditedMessages[len(session.EditedMessages)-1]
+	if final != "ugh, fine. you like tea?" {
+		t.Errorf("final message = %q, want %q", final, "ugh, fine. you like tea?")
+	}
+	if storedMemory != "Likes green tea" {
+		t.Errorf("stored memory = %q, want %q", storedMemory, "Likes green tea")
+	}
+}
+
+func TestStreamReply_SplitsParagraphs(t *testing.T) {
+	chunks := make(chan cerebras.StreamChunk, 4)
+	chunks <- cerebras.StreamChunk{Content: "hmph.\n"}
+	chunks <- cerebras.StreamChunk{Content: "\nfine, "}
+	chunks <- cerebras.StreamChunk{Content: "i'll help. " + strings.Repeat("a", maxMessageLength)}
+	close(chunks)
+
+	handler := NewHandler(&mockCerebrasClient{}, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	session := &MockSession{}
+	reply, _, err := handler.streamReply(session, "test_channel", chunks, &discordgo.MessageReference{MessageID: "m"})
+	if err != nil {
+		t.Fatalf("streamReply() error = %v", err)
+	}
+
+	want := splitMessage(reply)
+	if len(want) != 3 || len(session.SentMessages) != 3 {
+		t.Fatalf("expected a message per part, got %d for %d parts", len(session.SentMessages), len(want))
+	}
+	if session.SentMessages[0] != "hmph." || want[1] != "fine, i'll help." {
+		t.Errorf("expected the reply split into paragraphs, got %q", session.SentMessages)
+	}
+	for _, msg := range append(session.SentMessages, session.EditedMessages...) {
+		if utf8.RuneCountInString(msg) > maxMessageLength {
+			t.Errorf("message of %d characters exceeds Discord's limit", utf8.RuneCountInString(msg))
+		}
+	}
+}
+
+// failingSession fails to post any message
+type failingSession struct {
+	MockSession
+}
+
+func (f *failingSession) ChannelMessageSendReply(channelID string, content string, reference *discordgo.MessageReference, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return nil, errors.New("missing permissions")
+}
+
+func TestStreamReply_DrainsOnSendError(t *testing.T) {
+	chunks := make(chan cerebras.StreamChunk)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(chunks)
+		for range 3 {
+			chunks <- cerebras.StreamChunk{Content: "ugh "}
+		}
+	}()
+
+	handler := NewHandler(&mockCerebrasClient{}, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	if _, _, err := handler.streamReply(&failingSession{}, "test_channel", chunks, &discordgo.MessageReference{MessageID: "m"}); err == nil {
+		t.Fatal("expected an error when the reply cannot be posted")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the stream to be drained after giving up")
+	}
+}
+
+func TestHandler_ShutdownWaitsForStreamedReply(t *testing.T) {
+	client := &gatedStreamingClient{started: make(chan struct{}), release: make(chan struct{})}
+	handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	handler.SetBotID("testbot")
+	handler.EnableStreaming(0)
+	handler.shutdownGrace = 5 * time.Second
+
+	message := func(id string) *discordgo.MessageCreate {
+		return &discordgo.MessageCreate{
+			Message: &discordgo.Message{
+				ID:        id,
+				ChannelID: "test_channel",
+				Author:    &discordgo.User{ID: "user123", Username: "testuser"},
+				Content:   "hi",
+				Mentions:  []*discordgo.User{{ID: "testbot"}},
+			},
+		}
+	}
+
+	session := &MockSession{}
+	go handler.HandleMessage(session, message("m1"))
+	<-client.started
+
+	shutdown := make(chan struct{})
+	go func() {
+		handler.Shutdown()
+		close(shutdown)
+	}()
+
+	select {
+	case <-shutdown:
+		t.Fatal("expected Shutdown to wait for the reply being streamed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(client.release)
+	<-shutdown
+
+	final := session.EditedMessages[len(session.EditedMessages)-1]
+	if final != "ugh, fine." {
+		t.Errorf("final message = %q, want the whole reply", final)
+	}
+
+	sent := len(session.SentMessages)
+	handler.HandleMessage(session, message("m2"))
+	if len(session.SentMessages) != sent {
+		t.Errorf("expected messages after Shutdown to be ignored, got %v", session.SentMessages[sent:])
+	}
+}
@@ -0,0 +1,196 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	"ninoai/pkg/cerebras"
+	"ninoai/pkg/memory"
+	"ninoai/pkg/usage"
+)
+
+// emojiSelection is the emoji filter's answer
+type emojiSelection struct {
+	Emojis []string `json:"emojis"`
+}
+
+var emojiSelectionSchema = &cerebras.JSONSchema{
+	Name:   "emoji_selection",
+	Strict: true,
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {"emojis": {"type": "array", "items": {"type": "string"}}},
+		"required": ["emojis"],
+		"additionalProperties": false
+	}`),
+}
+
+// memoryExtraction is the memory extractor's answer
+type memoryExtraction struct {
+	Memories []extractedMemory `json:"memories"`
+}
+
+// extractedMemory is one fact. Key and Value are empty for facts that don't
+// fill a profile field.
+type extractedMemory struct {
+	Text       string  `json:"text"`
+	Category   string  `json:"category"`
+	Key        string  `json:"key"`
+	Value      string  `json:"value"`
+	Confidence float64 `json:"confidence"`
+}
+
+var memoryExtractionSchema = &cerebras.JSONSchema{
+	Name:   "memory_extraction",
+	Strict: true,
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"memories": {
+				"type": "array",
+				"items": {
+					"type": "object",
+					"properties": {
+						"text": {"type": "string"},
+						"category": {"type": "string", "enum": ["identity", "preference", "relationship", "event", "goal"]},
+						"key": {"type": "string"},
+						"value": {"type": "string"},
+						"confidence": {"type": "number"}
+					},
+					"required": ["text", "category", "key", "value", "confidence"],
+					"additionalProperties": false
+				}
+			}
+		},
+		"required": ["memories"],
+		"additionalProperties": false
+	}`),
+}
+
+// maxMemoriesPerMessage caps how many facts a single exchange can produce
+const maxMemoriesPerMessage = 3
+
+func (e memoryExtraction) Validate() error {
+	if len(e.Memories) > maxMemoriesPerMessage {
+		return fmt.Errorf("at most %d memories per message, got %d", maxMemoriesPerMessage, len(e.Memories))
+	}
+	for _, m := range e.Memories {
+		if strings.TrimSpace(m.Text) == "" {
+			return errors.New("memories must not be empty strings")
+		}
+		if !memory.ValidCategory(m.Category) {
+			return fmt.Errorf("unknown memory category %q", m.Category)
+		}
+		if (strings.TrimSpace(m.Key) == "") != (strings.TrimSpace(m.Value) == "") {
+			return errors.New("key and value must be given together")
+		}
+		if m.Confidence < 0 || m.Confidence > 1 {
+			return fmt.Errorf("confidence must be between 0 and 1, got %v", m.Confidence)
+		}
+	}
+	return nil
+}
+
+// extractMemories asks the LLM for the permanent facts about the user learned
+// from one exchange. Most exchanges have none.
+func (h *Handler) extractMemories(ctx context.Context, displayName, userMessage, reply string) ([]memory.Memory, error) {
+	messages := []cerebras.Message{
+		{Role: "system", Content: fmt.Sprintf(MemoryExtractionPrompt, displayName)},
+		{Role: "user", Content: fmt.Sprintf("%s: %s\nNino: %s", displayName, userMessage, reply)},
+	}
+
+	var extraction memoryExtraction
+	ctx = usage.WithKind(ctx, usage.KindMemoryExtraction)
+	if err := h.cerebrasClient.ChatCompletionJSON(ctx, messages, memoryExtractionSchema, &extraction); err != nil {
+		return nil, err
+	}
+
+	var memories []memory.Memory
+	for _, m := range extraction.Memories {
+		fact := memory.Memory{
+			Text:       strings.TrimSpace(m.Text),
+			Category:   m.Category,
+			Key:        memory.NormalizeKey(m.Key),
+			Confidence: m.Confidence,
+		}
+		if fact.Key != "" {
+			fact.Value = strings.TrimSpace(m.Value)
+		}
+		memories = append(memories, fact)
+	}
+	return memories, nil
+}
+
+// selfReferences are the words users share something about themselves with
+var selfReferences = []string{"i", "i'm", "im", "i've", "ive", "i'd", "i'll", "my", "me", "mine", "myself"}
+
+// mayShareFacts reports whether a message could tell something worth
+// remembering about its author: a statement, not a question, that speaks in
+// the first person. It keeps extractMemories from being called for every
+// reply when most messages teach nothing.
+func mayShareFacts(content string) bool {
+	content = strings.TrimSpace(strings.ReplaceAll(content, "’", "'"))
+	if strings.HasSuffix(content, "?") {
+		return false
+	}
+	words := strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	return slices.ContainsFunc(words, func(word string) bool {
+		return slices.Contains(selfReferences, word)
+	})
+}
+
+// memoryComparison is the answer to how a new fact relates to a stored one
+type memoryComparison struct {
+	Relation string `json:"relation"`
+	Merged   string `json:"merged"` // Both facts in one, when the new one refines the old
+}
+
+var memoryComparisonSchema = &cerebras.JSONSchema{
+	Name:   "memory_comparison",
+	Strict: true,
+	Schema: json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"relation": {"type": "string", "enum": ["duplicate", "refines", "supersedes", "contradicts"]},
+			"merged": {"type": "string"}
+		},
+		"required": ["relation", "merged"],
+		"additionalProperties": false
+	}`),
+}
+
+func (c memoryComparison) Validate() error {
+	if !memory.ValidRelation(c.Relation) {
+		return fmt.Errorf("unknown relation %q", c.Relation)
+	}
+	if c.Relation == memory.RelationRefines && strings.TrimSpace(c.Merged) == "" {
+		return errors.New("a refinement needs the merged fact")
+	}
+	return nil
+}
+
+// compareMemories asks the LLM how the new fact relates to a stored one that
+// sounds alike. The merged fact is only set for refinements.
+func (h *Handler) compareMemories(ctx context.Context, existing, fact string) (relation, merged string, err error) {
+	messages := []cerebras.Message{
+		{Role: "system", Content: MemoryComparisonPrompt},
+		{Role: "user", Content: fmt.Sprintf("Stored: %s\nNew: %s", existing, fact)},
+	}
+
+	var comparison memoryComparison
+	ctx = usage.WithKind(ctx, usage.KindMemoryComparison)
+	if err := h.cerebrasClient.ChatCompletionJSON(ctx, messages, memoryComparisonSchema, &comparison); err != nil {
+		return "", "", err
+	}
+	if comparison.Relation == memory.RelationRefines {
+		merged = strings.TrimSpace(comparison.Merged)
+	}
+	return comparison.Relation, merged, nil
+}
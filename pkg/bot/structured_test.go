@@ -0,0 +1,23 @@
+package bot
+
+import "testing"
+
+func TestMayShareFacts(t *testing.T) {
+	tests := []struct {
+		content string
+		want    bool
+	}{
+		{"I have a cat named Mochi", true},
+		{"my sister’s getting married next week", true},
+		{"ugh, I'm so tired after work", true},
+		{"what should I cook tonight?", false},
+		{"Nino, you're so mean", false},
+		{"lol", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := mayShareFacts(tt.content); got != tt.want {
+			t.Errorf("mayShareFacts(%q) = %v, want %v", tt.content, got, tt.want)
+		}
+	}
+}
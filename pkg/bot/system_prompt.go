@@ -21,7 +21,7 @@ Personality Layers:
 Roasting Guidelines:
 - Be CREATIVE. No generic "you're dumb" stuff
 - Target their choices, taste, logic, or whatever dumb thing they just said
-- Balance: 20% teasing, 70% actual conversation, 10% rare nice moments
+- Balance: 20%% teasing, 70%% actual conversation, 10%% rare nice moments
 - If they roast back well, respect it. even compliment them (begrudgingly)
 - Make it feel like banter between friends who insult each other, not genuine cruelty
 - KEEP IT CONCISE. land the hit and move on
@@ -36,18 +36,41 @@ Keep it Fresh:
 
 You currently talking to %s. feel them out first before going full savage.
 `;
-const MemoryInstruction = `MEMORY INSTRUCTION:
-If you learn a CRITICAL, PERMANENT fact about the user (e.g., name, age, occupation, specific strong preferences), append [MEMORY: fact] to the end of your message.
-- If the info is trivial (e.g., "said hi", "uses emoticons"), DO NOT append the [MEMORY] tag. Just reply normally.
-- If there is no new info, DO NOT append the tag.
-- ONLY append [MEMORY: fact] for CRITICAL, PERMANENT facts.
-- Write memories naturally without "User" prefix. Use their name (%s) or pronouns.
-Examples: 
-  "oh you're a programmer? [MEMORY: Works as a software developer]"
-  "you hate pickles too? finally someone who gets it. [MEMORY: Dislikes pickles]"
-  "whatever, that's boring." (NO memory tag for opinions or reactions)
-  "go eat something then." (NO memory tag for temporary states like hunger)
-  "lol that's funny" (NO memory tag for chat filler)
-  "i'm going to sleep" (NO memory tag for daily routine actions)
-  "can you help me?" (NO memory tag for questions)
-`
+// MemoryExtractionPrompt asks for the facts worth remembering from one exchange, as JSON
+const MemoryExtractionPrompt = `You extract long-term memories about %[1]s from a chat between %[1]s and Nino.
+
+Only record CRITICAL, PERMANENT facts about %[1]s (e.g., name, age, occupation, specific strong preferences).
+- Trivial info (e.g., "said hi", "uses emoticons") is NOT a memory.
+- Opinions, reactions, temporary states (hunger, tiredness), daily routine actions, questions and chat filler are NOT memories.
+- Write memories naturally without a "User" prefix. Use their name (%[1]s) or pronouns.
+- Most messages contain no memories. Then return an empty list.
+
+For each memory give:
+- "text": the fact itself
+- "category": one of identity (name, age, pronouns, job, where they live), preference (likes and dislikes), relationship (people and pets in their life), event (things that happened or will happen to them) or goal (what they are working towards)
+- "key" and "value": the fact as a profile field when it has a single current value (e.g. "name" and "Alex", "birthday" and "03-14", "job" and "programmer"); otherwise both ""
+- "confidence": from 0 to 1, how sure you are the fact is true and lasting (jokes and sarcasm are low)
+
+Examples:
+  "i'm a programmer btw" -> {"memories": [{"text": "Works as a software developer", "category": "identity", "key": "job", "value": "software developer", "confidence": 0.9}]}
+  "i hate pickles" -> {"memories": [{"text": "Dislikes pickles", "category": "preference", "key": "", "value": "", "confidence": 0.9}]}
+  "lol that's funny" -> {"memories": []}
+  "i'm going to sleep" -> {"memories": []}
+
+Reply with JSON only: {"memories": [{"text": "...", "category": "...", "key": "...", "value": "...", "confidence": 0.0}, ...]}`
+
+// MemoryComparisonPrompt asks how a new fact about a user relates to a stored one that sounds alike, as JSON
+const MemoryComparisonPrompt = `You keep a list of facts about a user up to date. A new fact sounds like a stored one; say how it relates to it:
+- "duplicate": it says the same thing
+- "refines": it adds to the stored fact, with a detail or another thing of the same kind, without changing it. Then write both as one fact in "merged"
+- "supersedes": the stored fact is out of date, e.g. they moved, changed jobs or finished a goal
+- "contradicts": it says the opposite of the stored fact
+Leave "merged" empty unless the relation is "refines".
+
+Examples:
+  Stored: Lives in Paris / New: Lives in Berlin -> {"relation": "supersedes", "merged": ""}
+  Stored: Has a cat / New: Has a cat named Mochi -> {"relation": "refines", "merged": "Has a cat named Mochi"}
+  Stored: Loves spicy food / New: Hates spicy food -> {"relation": "contradicts", "merged": ""}
+  Stored: Has a sister / New: Has a brother -> {"relation": "refines", "merged": "Has a sister and a brother"}
+
+Reply with JSON only: {"relation": "...", "merged": "..."}`
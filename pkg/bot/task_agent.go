@@ -1,56 +1,73 @@
 package bot
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"slices"
 	"strings"
 
 	"ninoai/pkg/cerebras"
+	"ninoai/pkg/usage"
 )
 
+// TaskThreshold is the score a task label needs before a message is refused
+// as a task; below it Nino gives the benefit of the doubt.
+const TaskThreshold = 0.51
+
+// taskLabels are the kinds of work Nino refuses to do. They are scored as a
+// group with chatLabel, apart from the reply triggers; see splitByGroup.
+var taskLabels = []string{
+	"request for long writing task",
+	"request for code generation",
+	"request for homework help",
+	"request for essay",
+}
+
+// chatLabel stands for every message that isn't a task in the task group.
+// TaskThreshold was tuned with it, so a task label must beat it to count.
+const chatLabel = "chat message"
+
+// TaskLabels returns the labels that make a message a task.
+func TaskLabels() []string {
+	return append([]string(nil), taskLabels...)
+}
+
+// IsTask reports whether label, with score, makes a message a task at the
+// given threshold.
+func IsTask(label string, score, threshold float64) bool {
+	return slices.Contains(taskLabels, label) && score >= threshold
+}
+
 type TaskAgent struct {
-	cerebrasClient   CerebrasClient
-	classifierClient Classifier
+	cerebrasClient CerebrasClient
+	analyzer       *Analyzer
 }
 
-func NewTaskAgent(c CerebrasClient, cl Classifier) *TaskAgent {
+func NewTaskAgent(c CerebrasClient, a *Analyzer) *TaskAgent {
 	return &TaskAgent{
-		cerebrasClient:   c,
-		classifierClient: cl,
+		cerebrasClient: c,
+		analyzer:       a,
 	}
 }
 
 // CheckTask analyzes if the message is a long writing task.
 // If it is, it returns true and a refusal message in character.
 // If not, it returns false and an empty string.
-func (ta *TaskAgent) CheckTask(userMsg string) (bool, string) {
+// The analysis is shared with the reply decision for the same message.
+func (ta *TaskAgent) CheckTask(ctx context.Context, messageID, userMsg string, policy ReplyPolicy) (bool, string) {
 	// 1. Classify the message
-	labels := []string{
-		"chat message",
-		"request for long writing task",
-		"request for code generation",
-		"request for homework help",
-		"request for essay",
-	}
-
-	label, score, err := ta.classifierClient.Classify(userMsg, labels)
+	analysis, err := ta.analyzer.Analyze(ctx, messageID, userMsg, policy)
 	if err != nil {
 		log.Printf("Error classifying task: %v", err)
 		// Fallback to assuming it's safe if classifier fails
 		return false, ""
 	}
 
-	log.Printf("Task Classification: '%s' (score: %.2f)", label, score)
-
-	// If it's just a chat message, we're good
-	if label == "chat message" {
-		return false, ""
-	}
+	log.Printf("Task Classification: '%s' (score: %.2f)", analysis.Task.Label, analysis.Task.Score)
 
-	// If confidence is low, give benefit of doubt
-	// Only treat as a task if we're confident (score >= 0.6)
-	if score < 0.51 {
-		log.Printf("Score too low, assuming chat message")
+	// Only treat as a task if we're confident
+	if !analysis.IsTask() {
 		return false, ""
 	}
 
@@ -79,7 +96,7 @@ Chat Style (Discord Mode):
 		{Role: "user", Content: prompt},
 	}
 
-	resp, err := ta.cerebrasClient.ChatCompletion(messages)
+	resp, err := ta.cerebrasClient.ChatCompletion(usage.WithKind(ctx, usage.KindTaskRefusal), messages)
 	if err != nil {
 		log.Printf("Error generating refusal: %v", err)
 		return true, "Hah? Do it yourself. I'm busy." // Fallback refusal
@@ -0,0 +1,216 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"ninoai/pkg/cerebras"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultMaxToolIterations bounds how many rounds of tool calls a single reply
+// may take before the model has to answer with what it has.
+const defaultMaxToolIterations = 3
+
+// ToolCallingClient is implemented by LLM clients that let the model call
+// tools while it replies.
+type ToolCallingClient interface {
+	ChatCompletionTools(ctx context.Context, messages []cerebras.Message, tools []cerebras.Tool) (cerebras.Message, error)
+	ChatCompletionStreamTools(ctx context.Context, messages []cerebras.Message, tools []cerebras.Tool) (<-chan cerebras.StreamChunk, error)
+}
+
+// Tool is an in-process function the model can call during a reply.
+type Tool struct {
+	Name        string
+	Description string
+	// Parameters is the JSON schema of the arguments object
+	Parameters json.RawMessage
+	// Run executes the call. Its result, or its error, is shown to the model.
+	Run func(ctx context.Context, call ToolInvocation) (string, error)
+}
+
+// ToolInvocation is a single call of a tool, made while replying to Message.
+type ToolInvocation struct {
+	Session   Session
+	Message   *discordgo.MessageCreate
+	Arguments json.RawMessage
+}
+
+// ToolRegistry holds the tools offered to the model, in registration order.
+type ToolRegistry struct {
+	tools map[string]Tool
+	order []string
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: make(map[string]Tool)}
+}
+
+// Register adds t to the registry. Names must be unique.
+func (r *ToolRegistry) Register(t Tool) error {
+	if t.Name == "" || t.Run == nil {
+		return errors.New("tool needs a name and a Run function")
+	}
+	if _, exists := r.tools[t.Name]; exists {
+		return fmt.Errorf("tool %s is already registered", t.Name)
+	}
+	r.tools[t.Name] = t
+	r.order = append(r.order, t.Name)
+	return nil
+}
+
+// Len returns the number of registered tools.
+func (r *ToolRegistry) Len() int {
+	return len(r.order)
+}
+
+// definitions returns the tools in the form sent to the LLM.
+func (r *ToolRegistry) definitions() []cerebras.Tool {
+	defs := make([]cerebras.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		t := r.tools[name]
+		params := t.Parameters
+		if params == nil {
+			params = json.RawMessage(`{"type": "object", "properties": {}}`)
+		}
+		defs = append(defs, cerebras.Tool{
+			Type:     cerebras.ToolTypeFunction,
+			Function: cerebras.ToolFunction{Name: t.Name, Description: t.Description, Parameters: params},
+		})
+	}
+	return defs
+}
+
+// run executes call and returns the content of its "tool" message. Failures
+// are reported to the model rather than aborting the reply, so it can recover.
+func (r *ToolRegistry) run(ctx context.Context, s Session, m *discordgo.MessageCreate, call cerebras.ToolCall) string {
+	t, ok := r.tools[call.Function.Name]
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %q", call.Function.Name)
+	}
+
+	args := json.RawMessage(call.Function.Arguments)
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	if !json.Valid(args) {
+		return "error: arguments are not valid JSON"
+	}
+
+	log.Printf("Running tool %s for user %s with %s", t.Name, m.Author.ID, args)
+	result, err := t.Run(ctx, ToolInvocation{Session: s, Message: m, Arguments: args})
+	if err != nil {
+		log.Printf("Tool %s failed: %v", t.Name, err)
+		return "error: " + err.Error()
+	}
+	return result
+}
+
+// EnableTools offers the built-in tools to the model. maxIterations bounds
+// the rounds of tool calls per reply; 0 keeps the default. It only takes
+// effect if the LLM client implements ToolCallingClient.
+func (h *Handler) EnableTools(maxIterations int) {
+	if maxIterations > 0 {
+		h.maxToolIterations = maxIterations
+	}
+	for _, t := range h.builtinTools() {
+		if err := h.tools.Register(t); err != nil {
+			log.Printf("Error registering tool: %v", err)
+		}
+	}
+}
+
+// RegisterTool offers an additional tool to the model.
+func (h *Handler) RegisterTool(t Tool) error {
+	return h.tools.Register(t)
+}
+
+// generateReply gets the reply to m from the LLM and sends it, streaming it if
+// enabled. When tools are registered the model may call them first.
+func (h *Handler) generateReply(ctx context.Context, s Session, m *discordgo.MessageCreate, messages []cerebras.Message) (string, error) {
+	if tc, ok := h.cerebrasClient.(ToolCallingClient); ok && h.tools.Len() > 0 {
+		return h.replyWithTools(ctx, s, m, tc, messages)
+	}
+
+	if sc, ok := h.cerebrasClient.(StreamingClient); ok && h.streaming {
+		// Cancelling stops the stream if streamReply gives up on it early
+		streamCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		chunks, err := sc.ChatCompletionStream(streamCtx, messages)
+		if err != nil {
+			return "", err
+		}
+		reply, _, err := h.streamReply(s, m.ChannelID, chunks, m.Reference())
+		return reply, err
+	}
+
+	reply, err := h.cerebrasClient.ChatCompletion(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	h.sendSplitMessage(s, m.ChannelID, reply, m.Reference())
+	return reply, nil
+}
+
+// replyWithTools runs the tool loop: while the model calls tools, their
+// results are appended to the conversation and the model is asked again. After
+// maxToolIterations rounds the tools are withdrawn so that it has to answer.
+// Text the model sends along with tool calls is shown as it arrives when
+// streaming and dropped otherwise.
+func (h *Handler) replyWithTools(ctx context.Context, s Session, m *discordgo.MessageCreate, tc ToolCallingClient, messages []cerebras.Message) (string, error) {
+	// Copy so that the caller's slice is never appended to
+	messages = messages[:len(messages):len(messages)]
+	defs := h.tools.definitions()
+
+	for round := 0; ; round++ {
+		if round == h.maxToolIterations {
+			log.Printf("Tool call limit of %d rounds reached for user %s", h.maxToolIterations, m.Author.ID)
+			defs = nil
+		}
+
+		var msg cerebras.Message
+		if h.streaming {
+			streamCtx, cancel := context.WithCancel(ctx)
+			chunks, err := tc.ChatCompletionStreamTools(streamCtx, messages, defs)
+			if err != nil {
+				cancel()
+				return "", err
+			}
+			content, calls, err := h.streamReply(s, m.ChannelID, chunks, m.Reference())
+			cancel()
+			if err != nil {
+				return "", err
+			}
+			msg = cerebras.Message{Role: "assistant", Content: content, ToolCalls: calls}
+		} else {
+			var err error
+			msg, err = tc.ChatCompletionTools(ctx, messages, defs)
+			if err != nil {
+				return "", err
+			}
+			if len(msg.ToolCalls) == 0 {
+				h.sendSplitMessage(s, m.ChannelID, msg.Content, m.Reference())
+			}
+		}
+
+		if len(msg.ToolCalls) == 0 {
+			return msg.Content, nil
+		}
+		if defs == nil {
+			return "", fmt.Errorf("model kept calling tools after %d rounds", h.maxToolIterations)
+		}
+
+		messages = append(messages, cerebras.Message{Role: "assistant", Content: msg.Content, ToolCalls: msg.ToolCalls})
+		for _, call := range msg.ToolCalls {
+			messages = append(messages, cerebras.Message{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    h.tools.run(ctx, s, m, call),
+			})
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"ninoai/pkg/cerebras"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mockToolClient answers each tool completion with the next scripted message
+// and records what it was sent.
+type mockToolClient struct {
+	mockCerebrasClient
+	Script   []cerebras.Message
+	Requests [][]cerebras.Message
+	Tools    [][]cerebras.Tool
+}
+
+func (m *mockToolClient) ChatCompletionTools(_ context.Context, messages []cerebras.Message, tools []cerebras.Tool) (cerebras.Message, error) {
+	m.Requests = append(m.Requests, messages)
+	m.Tools = append(m.Tools, tools)
+	if tools == nil {
+		return cerebras.Message{Role: "assistant", Content: "fine, whatever."}, nil
+	}
+	return m.Script[(len(m.Requests)-1)%len(m.Script)], nil
+}
+
+func (m *mockToolClient) ChatCompletionStreamTools(ctx context.Context, messages []cerebras.Message, tools []cerebras.Tool) (<-chan cerebras.StreamChunk, error) {
+	msg, err := m.ChatCompletionTools(ctx, messages, tools)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make(chan cerebras.StreamChunk, 2)
+	chunks <- cerebras.StreamChunk{Content: msg.Content}
+	if msg.ToolCalls != nil {
+		chunks <- cerebras.StreamChunk{ToolCalls: msg.ToolCalls}
+	}
+	close(chunks)
+	return chunks, nil
+}
+
+func toolCallMessage(name, args string) cerebras.Message {
+	return cerebras.Message{Role: "assistant", ToolCalls: []cerebras.ToolCall{{
+		ID:       "call_1",
+		Type:     cerebras.ToolTypeFunction,
+		Function: cerebras.ToolCallFunction{Name: name, Arguments: args},
+	}}}
+}
+
+func mentionMessage(content string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{
+		Message: &discordgo.Message{
+			ChannelID: "test_channel",
+			Author:    &discordgo.User{ID: "user123", Username: "testuser"},
+			Content:   content,
+			Mentions:  []*discordgo.User{{ID: "testbot"}},
+		},
+	}
+}
+
+func TestHandler_ToolLoop(t *testing.T) {
+	for _, streaming := range []bool{false, true} {
+		name := "Non-streamed"
+		if streaming {
+			name = "Streamed"
+		}
+		t.Run(name, func(t *testing.T) {
+			client := &mockToolClient{Script: []cerebras.Message{
+				toolCallMessage("lookup", `{"topic":"tea"}`),
+				{Role: "assistant", Content: "you like green tea, obviously."},
+			}}
+
+			handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+			handler.SetBotID("testbot")
+			if streaming {
+				handler.EnableStreaming(0)
+			}
+
+			var gotArgs string
+			err := handler.RegisterTool(Tool{
+				Name: "lookup",
+				Run: func(ctx context.Context, call ToolInvocation) (string, error) {
+					gotArgs = string(call.Arguments)
+					if call.Message.Author.ID != "user123" {
+						t.Errorf("tool called for %s, want user123", call.Message.Author.ID)
+					}
+					return "Likes green tea", nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("RegisterTool() error = %v", err)
+			}
+
+			session := &MockSession{}
+			handler.HandleMessage(session, mentionMessage("what tea do I like?"))
+			handler.WaitForReady()
+
+			if len(client.Requests) != 2 {
+				t.Fatalf("expected 2 tool completions, got %d", len(client.Requests))
+			}
+			if len(client.Tools[0]) != 1 || client.Tools[0][0].Function.Name != "lookup" {
+				t.Errorf("expected the registered tool to be offered, got %+v", client.Tools[0])
+			}
+			if gotArgs != `{"topic":"tea"}` {
+				t.Errorf("tool arguments = %s", gotArgs)
+			}
+
+			second := client.Requests[1]
+			result := second[len(second)-1]
+			if result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != "Likes green tea" {
+				t.Errorf("expected the tool result to be sent back, got %+v", result)
+			}
+			if len(session.SentMessages) != 1 || session.SentMessages[0] != "you like green tea, obviously." {
+				t.Errorf("unexpected messages: %v", session.SentMessages)
+			}
+		})
+	}
+}
+
+func TestHandler_ToolLoopLimit(t *testing.T) {
+	// The model never stops calling tools on its own
+	client := &mockToolClient{Script: []cerebras.Message{toolCallMessage("lookup", "{}")}}
+
+	handler := NewHandler(client, &MockClassifier{}, &mockEmbeddingClient{}, &mockMemoryStore{}, 0)
+	handler.SetBotID("testbot")
+	handler.maxToolIterations = 2
+	runs := 0
+	handler.RegisterTool(Tool{Name: "lookup", Run: func(ctx context.Context, call ToolInvocation) (string, error) {
+		runs++
+		return "nothing", nil
+	}})
+
+	session := &MockSession{}
+	handler.HandleMessage(session, mentionMessage("hi"))
+	handler.WaitForReady()
+
+	if runs != 2 || len(client.Requests) != 3 {
+		t.Fatalf("expected 2 tool runs and 3 completions, got %d and %d", runs, len(client.Requests))
+	}
+	if client.Tools[2] != nil {
+		t.Errorf("expected the tools to be withdrawn after the limit, got %+v", client.Tools[2])
+	}
+	if len(session.SentMessages) != 1 || session.SentMessages[0] != "fine, whatever." {
+		t.Errorf("unexpected messages: %v", session.SentMessages)
+	}
+}
+
+func TestToolRegistry(t *testing.T) {
+	registry := NewToolRegistry()
+	failing := Tool{Name: "failing", Run: func(ctx context.Context, call ToolInvocation) (string, error) {
+		return "", errors.New("out of order")
+	}}
+	if err := registry.Register(failing); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := registry.Register(failing); err == nil {
+		t.Error("expected duplicate names to be rejected")
+	}
+	if err := registry.Register(Tool{Name: "no_run"}); err == nil {
+		t.Error("expected tools without Run to be rejected")
+	}
+
+	tests := []struct {
+		name string
+		call cerebras.ToolCall
+		want string
+	}{
+		{"Unknown tool", cerebras.ToolCall{Function: cerebras.ToolCallFunction{Name: "mystery"}}, `error: unknown tool "mystery"`},
+		{"Invalid arguments", cerebras.ToolCall{Function: cerebras.ToolCallFunction{Name: "failing", Arguments: "{oops"}}, "error: arguments are not valid JSON"},
+		{"Tool error", cerebras.ToolCall{Function: cerebras.ToolCallFunction{Name: "failing"}}, "error: out of order"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registry.run(context.Background(), &MockSession{}, mentionMessage("hi"), tt.call); got != tt.want {
+				t.Errorf("run() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	var params map[string]interface{}
+	if err := json.Unmarshal(registry.definitions()[0].Function.Parameters, &params); err != nil || params["type"] != "object" {
+		t.Errorf("expected an empty object schema for tools without parameters, got %v (%v)", params, err)
+	}
+}
+
+func TestCurrentTimeTool(t *testing.T) {
+	got, err := currentTimeTool(context.Background(), ToolInvocation{Arguments: json.RawMessage(`{"timezone":"Asia/Tokyo"}`)})
+	if err != nil || !strings.HasSuffix(got, "JST") {
+		t.Errorf("currentTimeTool() = %q, %v", got, err)
+	}
+	if _, err := currentTimeTool(context.Background(), ToolInvocation{Arguments: json.RawMessage(`{"timezone":"Nowhere/Land"}`)}); err == nil {
+		t.Error("expected an unknown timezone to fail")
+	}
+}
@@ -1,165 +1,232 @@
 package cerebras
 
 import (
-	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"regexp"
 	"strings"
-)
-
-const (
-	apiURL = "https://api.cerebras.ai/v1/chat/completions"
+	"time"
 )
 
 // thinkRegex matches <think>...</think> content, including newlines.
 // (?s) enables the dot (.) to match new lines.
 var thinkRegex = regexp.MustCompile(`(?s)<think>.*?</think>`)
 
-// ModelConfig defines the ID and context limits for the prioritized list.
-type ModelConfig struct {
-	ID     string
-	MaxCtx int
+// Model is one entry of the fallback chain: a model served by a provider,
+// together with its limits.
+type Model struct {
+	ID          string
+	MaxCtx      int
+	MaxTokens   int      // Completion budget; 0 leaves it to the provider
+	Temperature *float64 // Overrides the client's temperature when set
+	Provider    Provider
 }
 
-var PrioritizedModels = []ModelConfig{
-	{ID: "qwen-3-235b-a22b-instruct-2507", MaxCtx: 65536},
-	{ID: "llama-3.3-70b", MaxCtx: 65536},
-	{ID: "llama3.1-8b", MaxCtx: 8192},
-	{ID: "qwen-3-32b", MaxCtx: 65536},
-	{ID: "zai-glm-4.6", MaxCtx: 64000},
-	{ID: "gpt-oss-120b", MaxCtx: 65536},
+// name identifies the model across providers, e.g. "cerebras/llama-3.3-70b".
+func (m Model) name() string {
+	return m.Provider.Name() + "/" + m.ID
 }
 
+// Client runs chat completions against an ordered fallback chain of models,
+// which may be spread over several providers.
 type Client struct {
-	apiKey      string
-	client      *http.Client
+	models      []Model
 	temperature float64
 	topP        float64
+	timeout     time.Duration // Deadline of a single model attempt; 0 means none
+	health      *healthTracker
+	recorder    UsageRecorder
 }
 
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// ToolCalls are the calls requested by an assistant message
+	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
+	// ToolCallID links a "tool" message to the call it answers
+	ToolCallID string `json:"tool_call_id,omitempty"`
+	// Trim marks the block as optional when the prompt must be shrunk to fit a
+	// model's context window. It is never sent to the API.
+	Trim int `json:"-"`
+	// Entries are the entries of a TrimRollingContext block, which follow its
+	// one-line header. An entry may span several lines, so the block is
+	// trimmed by whole entries when they are known. Never sent to the API.
+	Entries []string `json:"-"`
 }
 
 type Request struct {
-	Model       string    `json:"model"`
-	Stream      bool      `json:"stream"`
-	MaxTokens   int       `json:"max_tokens"`
-	Temperature float64   `json:"temperature"`
-	TopP        float64   `json:"top_p"`
-	Messages    []Message `json:"messages"`
+	Model         string         `json:"model"`
+	Stream        bool           `json:"stream"`
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
+	MaxTokens     int            `json:"max_tokens,omitempty"`
+	Temperature   float64        `json:"temperature"`
+	TopP          float64        `json:"top_p"`
+	Messages      []Message      `json:"messages"`
+	// ResponseFormat requests JSON output; see ChatCompletionJSON
+	ResponseFormat *ResponseFormat `json:"response_format,omitempty"`
+	// Tools are offered to the model; see ChatCompletionTools
+	Tools []Tool `json:"tools,omitempty"`
 }
 
-type Response struct {
-	Choices []struct {
-		Message struct {
-			Content string `json:"content"`
-		} `json:"message"`
-	} `json:"choices"`
+// StreamOptions asks OpenAI-compatible APIs to report usage at the end of a stream.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 // APIError captures non-200 responses to allow inspection of the status code.
 type APIError struct {
 	StatusCode int
 	Body       string
+	RetryAfter time.Duration // Parsed from the Retry-After header, if present
 }
 
 func (e *APIError) Error() string {
 	return fmt.Sprintf("api status %d: %s", e.StatusCode, e.Body)
 }
 
-func NewClient(apiKey string, temperature, topP float64) *Client {
+// NewClient creates a client for the given fallback chain. timeout bounds each
+// model attempt, so a hanging model costs at most timeout before the next one
+// is tried; 0 disables it.
+func NewClient(models []Model, temperature, topP float64, timeout time.Duration) *Client {
 	return &Client{
-		apiKey:      apiKey,
-		client:      &http.Client{},
+		models:      models,
 		temperature: temperature,
 		topP:        topP,
+		timeout:     timeout,
+		health:      newHealthTracker(),
 	}
 }
 
 // ChatCompletion attempts to get a response.
 // If the API returns ANY non-2xx status (429, 500, 400, etc.), it cycles to the next model.
-func (c *Client) ChatCompletion(messages []Message) (string, error) {
+// Models whose circuit breaker is open are skipped until their cooldown ends.
+// Once ctx is done no further models are tried.
+func (c *Client) ChatCompletion(ctx context.Context, messages []Message) (string, error) {
+	msg, err := c.complete(ctx, messages, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	return CleanResponse(msg.Content), nil
+}
+
+// complete runs a non-streamed completion through the fallback chain and
+// returns the raw assistant message of the first model that answers.
+func (c *Client) complete(ctx context.Context, messages []Message, format *ResponseFormat, tools []Tool) (Message, error) {
 	var lastErr error
 
-	for _, modelConf := range PrioritizedModels {
-		log.Printf("Attempting to use model: %s", modelConf.ID)
-		reqBody := Request{
-			Model:       modelConf.ID,
-			Stream:      false,
-			MaxTokens:   2000,
-			Temperature: c.temperature,
-			TopP:        c.topP,
-			Messages:    messages,
+	for _, model := range c.models {
+		if err := ctx.Err(); err != nil {
+			return Message{}, err
 		}
 
-		content, err := c.makeRequest(reqBody)
+		reqBody, err := c.prepare(model, messages, false, format, tools)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		log.Printf("Attempting to use model: %s", model.name())
+		attemptCtx, cancel := c.attemptContext(ctx)
+		msg, usage, err := model.Provider.Complete(attemptCtx, reqBody)
+		cancel()
 
 		if err == nil {
 			// Success: Received a 200 OK and valid content
-			return content, nil
+			c.health.success(model.name())
+			c.recordUsage(ctx, model, usage)
+			return msg, nil
 		}
-
-		// Capture the error and cycle to the next model
-		if apiErr, ok := err.(*APIError); ok {
-			lastErr = fmt.Errorf("model %s failed with status %d: %w", modelConf.ID, apiErr.StatusCode, apiErr)
-		} else {
-			lastErr = fmt.Errorf("model %s network error: %w", modelConf.ID, err)
+		if ctx.Err() != nil {
+			// The caller gave up, which says nothing about the model
+			c.health.release(model.name())
+			return Message{}, ctx.Err()
 		}
+		c.health.failure(model.name(), err)
 
-		// Continue to the next model in the loop
+		// Capture the error and cycle to the next model
+		lastErr = describeFailure(model, err)
 	}
 
 	// If we reach here, all models failed
-	return "", fmt.Errorf("all models exhausted. Last error: %w", lastErr)
+	return Message{}, fmt.Errorf("all models exhausted. Last error: %w", lastErr)
 }
 
-func (c *Client) makeRequest(reqBody Request) (string, error) {
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+// prepare builds the request for model, or returns why the model has to be
+// skipped: either the prompt cannot fit its context window or it is cooling down.
+func (c *Client) prepare(model Model, messages []Message, stream bool, format *ResponseFormat, tools []Tool) (Request, error) {
+	fitted, ok := fitModel(model, messages, estimateRequestTokens(format, tools))
+	if !ok {
+		return Request{}, fmt.Errorf("model %s: prompt does not fit context window of %d tokens", model.name(), model.MaxCtx)
 	}
-
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	if !c.health.allow(model.name()) {
+		return Request{}, fmt.Errorf("model %s is cooling down", model.name())
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	temperature := c.temperature
+	if model.Temperature != nil {
+		temperature = *model.Temperature
+	}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("failed to perform request: %w", err)
+	req := Request{
+		Model:          model.ID,
+		Stream:         stream,
+		MaxTokens:      model.MaxTokens,
+		Temperature:    temperature,
+		TopP:           c.topP,
+		Messages:       fitted,
+		ResponseFormat: format,
+		Tools:          tools,
 	}
-	defer resp.Body.Close()
-
-	// If status code is not 2xx (e.g., 200, 201), return an APIError.
-	// This triggers the loop in ChatCompletion to try the next model.
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", &APIError{
-			StatusCode: resp.StatusCode,
-			Body:       string(bodyBytes),
-		}
+	if stream {
+		req.StreamOptions = &StreamOptions{IncludeUsage: true}
 	}
+	return req, nil
+}
 
-	var apiResp Response
-	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+// attemptContext derives the context of a single model attempt from ctx.
+func (c *Client) attemptContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, c.timeout)
+}
 
-	if len(apiResp.Choices) == 0 {
-		return "", fmt.Errorf("no choices in response")
+func describeFailure(model Model, err error) error {
+	if apiErr, ok := err.(*APIError); ok {
+		return fmt.Errorf("model %s failed with status %d: %w", model.name(), apiErr.StatusCode, apiErr)
 	}
+	return fmt.Errorf("model %s network error: %w", model.name(), err)
+}
 
-	content := apiResp.Choices[0].Message.Content
+// fitModel returns messages trimmed so that the prompt, the overhead tokens
+// of the request's schemas and tools, and the completion budget fit the
+// model's context window. Models without a MaxTokens budget still get room
+// for defaultCompletionReserve tokens of reply, or a quarter of the window if
+// that is smaller. Models without a known MaxCtx get the prompt unchanged.
+func fitModel(model Model, messages []Message, overhead int) ([]Message, bool) {
+	if model.MaxCtx <= 0 {
+		return messages, true
+	}
+
+	reserve := model.MaxTokens
+	if reserve <= 0 {
+		reserve = min(defaultCompletionReserve, model.MaxCtx/4)
+	}
+	fitted, ok := fitToContext(messages, model.MaxCtx-reserve-overhead)
+	if !ok {
+		log.Printf("Skipping model %s: estimated prompt of %d tokens exceeds its context window", model.name(), EstimateTokens(messages)+overhead)
+		return nil, false
+	}
+	if EstimateTokens(fitted) < EstimateTokens(messages) {
+		log.Printf("Trimmed prompt for model %s from %d to %d estimated tokens", model.name(), EstimateTokens(messages)+overhead, EstimateTokens(fitted)+overhead)
+	}
+	return fitted, true
+}
 
+// CleanResponse removes <think> blocks, surrounding whitespace and wrapping
+// quotes from a completion.
+func CleanResponse(content string) string {
 	// Remove <think> tags and their content from the response
 	content = thinkRegex.ReplaceAllString(content, "")
 
@@ -172,5 +239,5 @@ func (c *Client) makeRequest(reqBody Request) (string, error) {
 		content = strings.TrimSpace(content)
 	}
 
-	return content, nil
+	return content
 }
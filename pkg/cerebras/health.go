@@ -0,0 +1,215 @@
+package cerebras
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// BreakerState is the circuit breaker state of a single model.
+type BreakerState string
+
+const (
+	// BreakerClosed models receive traffic normally
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen models are cooling down and are skipped
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen models have finished cooling down and get a single probe
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+const (
+	// failureThreshold is the number of consecutive failures that opens a breaker
+	failureThreshold = 3
+	// baseCooldown is how long a breaker stays open the first time it trips.
+	// Each failed half-open probe doubles it, up to maxCooldown.
+	baseCooldown = 30 * time.Second
+	maxCooldown  = 10 * time.Minute
+)
+
+// ModelStatus is a point-in-time view of a model's health, for logging and metrics.
+type ModelStatus struct {
+	ID                  string
+	Provider            string
+	State               BreakerState
+	ConsecutiveFailures int
+	CooldownUntil       time.Time
+	LastError           string
+}
+
+type modelHealth struct {
+	state     BreakerState
+	failures  int
+	trips     int // consecutive times the breaker opened without a success in between
+	openUntil time.Time
+	probing   bool
+	lastErr   string
+}
+
+// healthTracker keeps per-model circuit breakers so that failing or
+// rate-limited models are skipped instead of costing every request a round trip.
+type healthTracker struct {
+	mu     sync.Mutex
+	models map[string]*modelHealth
+	now    func() time.Time
+}
+
+func newHealthTracker() *healthTracker {
+	return &healthTracker{
+		models: make(map[string]*modelHealth),
+		now:    time.Now,
+	}
+}
+
+func (t *healthTracker) get(id string) *modelHealth {
+	h, ok := t.models[id]
+	if !ok {
+		h = &modelHealth{state: BreakerClosed}
+		t.models[id] = h
+	}
+	return h
+}
+
+// allow reports whether a request may be sent to the model. Once a cooldown
+// has passed the breaker turns half-open and lets exactly one probe through.
+func (t *healthTracker) allow(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(id)
+	switch h.state {
+	case BreakerOpen:
+		if t.now().Before(h.openUntil) {
+			return false
+		}
+		log.Printf("Model %s cooldown finished, probing", id)
+		h.state = BreakerHalfOpen
+		h.probing = true
+		return true
+	case BreakerHalfOpen:
+		if h.probing {
+			return false
+		}
+		h.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// success closes the model's breaker.
+func (t *healthTracker) success(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(id)
+	if h.state != BreakerClosed {
+		log.Printf("Model %s recovered", id)
+	}
+	*h = modelHealth{state: BreakerClosed}
+}
+
+// failure records a failed request and opens the breaker when the model has
+// failed too often, asked us to back off, or failed its half-open probe.
+func (t *healthTracker) failure(id string, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(id)
+	h.probing = false
+	if !isModelFailure(err) {
+		return
+	}
+	h.failures++
+	h.lastErr = err.Error()
+
+	var retryAfter time.Duration
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		retryAfter = apiErr.RetryAfter
+	}
+
+	if h.state != BreakerHalfOpen && retryAfter == 0 && h.failures < failureThreshold {
+		return
+	}
+
+	cooldown := baseCooldown << h.trips
+	if cooldown > maxCooldown || cooldown <= 0 {
+		cooldown = maxCooldown
+	}
+	if retryAfter > 0 {
+		cooldown = retryAfter
+	}
+
+	h.trips++
+	h.state = BreakerOpen
+	h.openUntil = t.now().Add(cooldown)
+	log.Printf("Model %s breaker open for %s after %d consecutive failures: %v", id, cooldown, h.failures, err)
+}
+
+// release gives up a probe granted by allow without judging the model, for
+// requests the caller cancelled.
+func (t *healthTracker) release(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.get(id).probing = false
+}
+
+func (t *healthTracker) status(id string) ModelStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h := t.get(id)
+	status := ModelStatus{
+		ID:                  id,
+		State:               h.state,
+		ConsecutiveFailures: h.failures,
+		LastError:           h.lastErr,
+	}
+	if h.state == BreakerOpen {
+		status.CooldownUntil = h.openUntil
+	}
+	return status
+}
+
+// ModelHealth returns the breaker state of every model in the fallback chain, in priority order.
+func (c *Client) ModelHealth() []ModelStatus {
+	statuses := make([]ModelStatus, 0, len(c.models))
+	for _, model := range c.models {
+		status := c.health.status(model.name())
+		status.ID = model.ID
+		status.Provider = model.Provider.Name()
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// isModelFailure reports whether err says something about the model's health,
+// as opposed to a problem with our request that every model would reject.
+func isModelFailure(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return true // Network errors
+	}
+	return apiErr.StatusCode == http.StatusTooManyRequests ||
+		apiErr.StatusCode == http.StatusRequestTimeout ||
+		apiErr.StatusCode >= 500
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,197 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newModelServer returns a stand-in API whose response depends on the requested model
+func newModelServer(t *testing.T, calls map[string]int, respond func(model string, w http.ResponseWriter)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		calls[req.Model]++
+		respond(req.Model, w)
+	}))
+}
+
+func TestChatCompletion_RespectsRetryAfter(t *testing.T) {
+	calls := map[string]int{}
+	limited := true
+	server := newModelServer(t, calls, func(model string, w http.ResponseWriter) {
+		if model == "primary" && limited {
+			w.Header().Set("Retry-After", "120")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"from ` + model + `"}}]}`))
+	})
+	defer server.Close()
+
+	now := time.Now()
+	client := newTestClient(server.URL, Model{ID: "primary"}, Model{ID: "backup"})
+	client.health.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		got, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+		if err != nil || got != "from backup" {
+			t.Fatalf("call %d: got %q, %v", i, got, err)
+		}
+	}
+	if calls["primary"] != 1 {
+		t.Errorf("expected the rate-limited model to be called once, got %d", calls["primary"])
+	}
+
+	status := client.ModelHealth()[0]
+	if status.State != BreakerOpen || !status.CooldownUntil.Equal(now.Add(120*time.Second)) {
+		t.Errorf("unexpected primary status: %+v", status)
+	}
+
+	// After the cooldown the model is probed again and closes on success
+	limited = false
+	now = now.Add(121 * time.Second)
+	got, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "from primary" {
+		t.Fatalf("probe: got %q, %v", got, err)
+	}
+	if state := client.ModelHealth()[0].State; state != BreakerClosed {
+		t.Errorf("expected breaker to close after a successful probe, got %s", state)
+	}
+}
+
+func TestHealthTracker_OpensAfterConsecutiveFailures(t *testing.T) {
+	now := time.Now()
+	tracker := newHealthTracker()
+	tracker.now = func() time.Time { return now }
+	serverErr := &APIError{StatusCode: http.StatusInternalServerError}
+
+	for i := 0; i < failureThreshold; i++ {
+		if !tracker.allow("m") {
+			t.Fatalf("breaker opened after only %d failures", i)
+		}
+		tracker.failure("m", serverErr)
+	}
+	if tracker.allow("m") {
+		t.Fatal("expected breaker to be open")
+	}
+
+	// Half-open: exactly one probe is let through
+	now = now.Add(baseCooldown)
+	if !tracker.allow("m") {
+		t.Fatal("expected a probe after the cooldown")
+	}
+	if tracker.allow("m") {
+		t.Error("expected only one concurrent probe")
+	}
+
+	// A failed probe reopens the breaker with a longer cooldown
+	tracker.failure("m", serverErr)
+	status := tracker.status("m")
+	if status.State != BreakerOpen || !status.CooldownUntil.Equal(now.Add(2*baseCooldown)) {
+		t.Errorf("unexpected status after failed probe: %+v", status)
+	}
+}
+
+func TestHealthTracker_IgnoresRequestErrors(t *testing.T) {
+	tracker := newHealthTracker()
+	for i := 0; i < failureThreshold*2; i++ {
+		tracker.failure("m", &APIError{StatusCode: http.StatusBadRequest})
+	}
+	if !tracker.allow("m") {
+		t.Error("client errors should not open the breaker")
+	}
+
+	tracker.failure("m", errors.New("connection reset"))
+	if status := tracker.status("m"); status.ConsecutiveFailures != 1 {
+		t.Errorf("expected network errors to count, got %+v", status)
+	}
+}
+
+func TestOllamaProvider_RetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewOllamaProvider("local", server.URL).do(context.Background(), Request{Model: "llama3.1:8b"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("expected the Retry-After header to be parsed, got %v", err)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"30", 30 * time.Second},
+		{"garbage", 0},
+		{now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestChatCompletion_TimesOutHangingModel(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Model == "primary" {
+			<-release
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"from ` + req.Model + `"}}]}`))
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := newTestClient(server.URL, Model{ID: "primary"}, Model{ID: "backup"})
+	client.timeout = 50 * time.Millisecond
+
+	got, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "from backup" {
+		t.Fatalf("ChatCompletion() = %q, %v", got, err)
+	}
+	if health := client.ModelHealth(); health[0].ConsecutiveFailures != 1 {
+		t.Errorf("expected the timeout to count against the model, got %+v", health[0])
+	}
+}
+
+func TestChatCompletion_StopsWhenCancelled(t *testing.T) {
+	calls := map[string]int{}
+	ctx, cancel := context.WithCancel(context.Background())
+	server := newModelServer(t, calls, func(model string, w http.ResponseWriter) {
+		cancel()
+		<-ctx.Done()
+		w.Write([]byte(`{"choices":[{"message":{"content":"too late"}}]}`))
+	})
+	defer server.Close()
+
+	client := newTestClient(server.URL, Model{ID: "primary"}, Model{ID: "backup"})
+
+	_, err := client.ChatCompletion(ctx, []Message{{Role: "user", Content: "hi"}})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChatCompletion() error = %v, want context.Canceled", err)
+	}
+	if calls["backup"] != 0 {
+		t.Error("expected no further models to be tried after cancellation")
+	}
+	if health := client.ModelHealth(); health[0].ConsecutiveFailures != 0 {
+		t.Errorf("expected cancellation not to count against the model, got %+v", health[0])
+	}
+}
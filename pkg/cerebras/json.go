@@ -0,0 +1,115 @@
+package cerebras
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+)
+
+// maxRepairs is how many times a malformed JSON reply is sent back to the
+// model for correction before ChatCompletionJSON gives up.
+const maxRepairs = 2
+
+// Response format types
+const (
+	FormatJSONObject = "json_object"
+	FormatJSONSchema = "json_schema"
+)
+
+// repairPrompt asks the model to fix its previous reply
+const repairPrompt = `Your previous reply could not be used: %v
+Reply again with ONLY the corrected JSON, no explanations or code fences.`
+
+// ResponseFormat constrains a completion to JSON, optionally following a schema.
+type ResponseFormat struct {
+	Type       string      `json:"type"`
+	JSONSchema *JSONSchema `json:"json_schema,omitempty"`
+}
+
+// JSONSchema is a named JSON schema the completion must follow.
+type JSONSchema struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema"`
+	Strict bool            `json:"strict"`
+}
+
+// Validator is implemented by ChatCompletionJSON targets that check their own
+// content once decoded. A validation error triggers a repair like malformed
+// JSON does.
+type Validator interface {
+	Validate() error
+}
+
+// ChatCompletionJSON runs a completion in JSON mode and decodes the reply into
+// out, which must be a pointer. With a schema the output is constrained to it,
+// otherwise any JSON object is accepted. Unknown fields are rejected, and if
+// out implements Validator it is validated too. Replies that fail any of these
+// checks are sent back to the model for repair up to maxRepairs times; out is
+// only written on success.
+//
+// The prompt should mention JSON, as some OpenAI-compatible APIs require it.
+func (c *Client) ChatCompletionJSON(ctx context.Context, messages []Message, schema *JSONSchema, out interface{}) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		return errors.New("ChatCompletionJSON: out must be a non-nil pointer")
+	}
+
+	format := &ResponseFormat{Type: FormatJSONObject}
+	if schema != nil {
+		format = &ResponseFormat{Type: FormatJSONSchema, JSONSchema: schema}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= maxRepairs; attempt++ {
+		msg, err := c.complete(ctx, messages, format, nil)
+		if err != nil {
+			return err
+		}
+		content := msg.Content
+
+		value := reflect.New(target.Elem().Type())
+		if lastErr = decodeJSON(content, value.Interface()); lastErr == nil {
+			target.Elem().Set(value.Elem())
+			return nil
+		}
+		log.Printf("Invalid JSON completion (attempt %d): %v", attempt+1, lastErr)
+
+		// Copy so that the caller's slice is never appended to
+		messages = append(messages[:len(messages):len(messages)],
+			Message{Role: "assistant", Content: content},
+			Message{Role: "user", Content: fmt.Sprintf(repairPrompt, lastErr)},
+		)
+	}
+
+	return fmt.Errorf("invalid JSON after %d repairs: %w", maxRepairs, lastErr)
+}
+
+// decodeJSON strictly decodes the JSON in content into v and validates it.
+// <think> blocks and markdown code fences around the JSON are tolerated.
+func decodeJSON(content string, v interface{}) error {
+	content = strings.TrimSpace(thinkRegex.ReplaceAllString(content, ""))
+	content = strings.TrimPrefix(content, "```json")
+	content = strings.TrimPrefix(content, "```")
+	content = strings.TrimSuffix(content, "```")
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("invalid JSON: %w", err)
+	}
+	if decoder.More() {
+		return errors.New("invalid JSON: unexpected data after the JSON value")
+	}
+
+	if validator, ok := v.(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return fmt.Errorf("invalid content: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testAnswer struct {
+	Names []string `json:"names"`
+}
+
+func (a testAnswer) Validate() error {
+	if len(a.Names) == 0 {
+		return errors.New("names must not be empty")
+	}
+	return nil
+}
+
+// newJSONServer answers each request with the next reply and records the requests.
+func newJSONServer(t *testing.T, replies []string, requests *[]Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		*requests = append(*requests, req)
+
+		reply := replies[len(*requests)-1]
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": map[string]string{"content": reply}}},
+		})
+	}))
+}
+
+func TestChatCompletionJSON(t *testing.T) {
+	schema := &JSONSchema{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`), Strict: true}
+
+	tests := []struct {
+		name         string
+		schema       *JSONSchema
+		replies      []string
+		wantNames    []string
+		wantRequests int
+		wantErr      bool
+	}{
+		{
+			name:         "Valid",
+			schema:       schema,
+			replies:      []string{`{"names": ["a", "b"]}`},
+			wantNames:    []string{"a", "b"},
+			wantRequests: 1,
+		},
+		{
+			name:         "Code fence and think block",
+			replies:      []string{"<think>hmm</think>```json\n{\"names\": [\"a\"]}\n```"},
+			wantNames:    []string{"a"},
+			wantRequests: 1,
+		},
+		{
+			name:         "Repaired after malformed JSON",
+			replies:      []string{`{"names": ["a"`, `{"names": ["a"]}`},
+			wantNames:    []string{"a"},
+			wantRequests: 2,
+		},
+		{
+			name:         "Repaired after failed validation",
+			replies:      []string{`{"names": []}`, `{"names": ["b"]}`},
+			wantNames:    []string{"b"},
+			wantRequests: 2,
+		},
+		{
+			name:         "Unknown fields rejected",
+			replies:      []string{`{"names": ["a"], "extra": 1}`, `{"names": ["a"]}`},
+			wantNames:    []string{"a"},
+			wantRequests: 2,
+		},
+		{
+			name:         "Gives up",
+			replies:      []string{"nope", "still no", "sorry"},
+			wantRequests: maxRepairs + 1,
+			wantErr:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests []Request
+			server := newJSONServer(t, tt.replies, &requests)
+			defer server.Close()
+			client := newTestClient(server.URL, Model{ID: "m"})
+
+			messages := []Message{{Role: "user", Content: "Reply in JSON"}}
+			answer := testAnswer{Names: []string{"untouched"}}
+			err := client.ChatCompletionJSON(context.Background(), messages, tt.schema, &answer)
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ChatCompletionJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(requests) != tt.wantRequests {
+				t.Errorf("expected %d requests, got %d", tt.wantRequests, len(requests))
+			}
+			if tt.wantErr {
+				if answer.Names[0] != "untouched" {
+					t.Errorf("out must not be written on failure, got %+v", answer)
+				}
+				return
+			}
+			if strings.Join(answer.Names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("names = %v, want %v", answer.Names, tt.wantNames)
+			}
+
+			first := requests[0].ResponseFormat
+			if tt.schema != nil && (first == nil || first.Type != FormatJSONSchema || first.JSONSchema.Name != "answer") {
+				t.Errorf("expected the schema in response_format, got %+v", first)
+			}
+			if tt.schema == nil && (first == nil || first.Type != FormatJSONObject) {
+				t.Errorf("expected json_object response_format, got %+v", first)
+			}
+
+			// Repairs show the model its previous reply and what was wrong with it
+			if len(requests) > 1 {
+				repair := requests[1].Messages
+				if len(repair) != 3 || repair[1].Role != "assistant" || repair[1].Content != tt.replies[0] ||
+					!strings.Contains(repair[2].Content, "could not be used") {
+					t.Errorf("unexpected repair messages: %+v", repair)
+				}
+			}
+			if len(messages) != 1 {
+				t.Errorf("the caller's messages must not be modified, got %+v", messages)
+			}
+		})
+	}
+}
+
+func TestOllamaProvider_JSONFormat(t *testing.T) {
+	var formats []json.RawMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Format json.RawMessage `json:"format"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		formats = append(formats, req.Format)
+		w.Write([]byte(`{"message":{"role":"assistant","content":"{\"names\":[\"a\"]}"},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient([]Model{{ID: "llama3.1:8b", Provider: NewOllamaProvider("local", server.URL)}}, 1, 1, 0)
+	var answer testAnswer
+	if err := client.ChatCompletionJSON(context.Background(), []Message{{Role: "user", Content: "JSON"}}, nil, &answer); err != nil {
+		t.Fatalf("ChatCompletionJSON() error = %v", err)
+	}
+	schema := &JSONSchema{Name: "answer", Schema: json.RawMessage(`{"type":"object"}`)}
+	if err := client.ChatCompletionJSON(context.Background(), []Message{{Role: "user", Content: "JSON"}}, schema, &answer); err != nil {
+		t.Fatalf("ChatCompletionJSON() error = %v", err)
+	}
+
+	if string(formats[0]) != `"json"` || string(formats[1]) != `{"type":"object"}` {
+		t.Errorf("unexpected Ollama formats: %s", formats)
+	}
+}
@@ -0,0 +1,241 @@
+package cerebras
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OllamaProvider talks to the native chat API of an Ollama server.
+type OllamaProvider struct {
+	name   string
+	apiURL string
+	client *http.Client
+}
+
+type ollamaRequest struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Stream   bool            `json:"stream"`
+	Format   interface{}     `json:"format,omitempty"` // "json" or a JSON schema
+	Tools    []Tool          `json:"tools,omitempty"`
+	Options  ollamaOptions   `json:"options"`
+}
+
+// ollamaMessage differs from Message in its tool calls, whose arguments are a
+// JSON object rather than a string and which have no IDs.
+type ollamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	ToolCalls []ollamaToolCall `json:"tool_calls,omitempty"`
+}
+
+type ollamaToolCall struct {
+	Function struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	} `json:"function"`
+}
+
+type ollamaOptions struct {
+	Temperature float64 `json:"temperature"`
+	TopP        float64 `json:"top_p"`
+	NumPredict  int     `json:"num_predict,omitempty"`
+}
+
+// ollamaResponse is both the full non-streamed response and a single line of
+// a streamed one.
+type ollamaResponse struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+	Error   string        `json:"error,omitempty"`
+	// Token counts, only set once done
+	PromptEvalCount int `json:"prompt_eval_count,omitempty"`
+	EvalCount       int `json:"eval_count,omitempty"`
+}
+
+func (r ollamaResponse) usage() Usage {
+	return Usage{
+		PromptTokens:     r.PromptEvalCount,
+		CompletionTokens: r.EvalCount,
+		TotalTokens:      r.PromptEvalCount + r.EvalCount,
+	}
+}
+
+// NewOllamaProvider creates a provider for the Ollama server at baseURL, e.g.
+// "http://localhost:11434".
+func NewOllamaProvider(name, baseURL string) *OllamaProvider {
+	return &OllamaProvider{
+		name:   name,
+		apiURL: strings.TrimRight(baseURL, "/") + "/api/chat",
+		client: &http.Client{},
+	}
+}
+
+func (p *OllamaProvider) Name() string {
+	return p.name
+}
+
+func (p *OllamaProvider) Complete(ctx context.Context, reqBody Request) (Message, Usage, error) {
+	resp, err := p.do(ctx, reqBody)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp ollamaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return Message{}, Usage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResp.Error != "" {
+		return Message{}, Usage{}, fmt.Errorf("ollama error: %s", apiResp.Error)
+	}
+
+	return Message{
+		Role:      "assistant",
+		Content:   apiResp.Message.Content,
+		ToolCalls: fromOllamaToolCalls(apiResp.Message.ToolCalls, 0),
+	}, apiResp.usage(), nil
+}
+
+func (p *OllamaProvider) Stream(ctx context.Context, reqBody Request) (<-chan StreamChunk, error) {
+	resp, err := p.do(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk)
+	go readNDJSON(resp.Body, chunks)
+	return chunks, nil
+}
+
+func (p *OllamaProvider) do(ctx context.Context, reqBody Request) (*http.Response, error) {
+	ollamaReq := ollamaRequest{
+		Model:    reqBody.Model,
+		Messages: toOllamaMessages(reqBody.Messages),
+		Stream:   reqBody.Stream,
+		Tools:    reqBody.Tools,
+		Options: ollamaOptions{
+			Temperature: reqBody.Temperature,
+			TopP:        reqBody.TopP,
+			NumPredict:  reqBody.MaxTokens,
+		},
+	}
+	if f := reqBody.ResponseFormat; f != nil {
+		if f.JSONSchema != nil {
+			ollamaReq.Format = f.JSONSchema.Schema
+		} else {
+			ollamaReq.Format = "json"
+		}
+	}
+
+	jsonBody, err := json.Marshal(ollamaReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return resp, nil
+}
+
+// readNDJSON forwards the content of each line of an Ollama stream to chunks.
+func readNDJSON(body io.ReadCloser, chunks chan<- StreamChunk) {
+	defer close(chunks)
+	defer body.Close()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	// Tool calls may arrive over several lines and are numbered across all of them
+	numbered := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var event ollamaResponse
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			chunks <- StreamChunk{Err: fmt.Errorf("failed to decode stream event: %w", err)}
+			return
+		}
+		if event.Error != "" {
+			chunks <- StreamChunk{Err: fmt.Errorf("ollama error: %s", event.Error)}
+			return
+		}
+		if event.Message.Content != "" {
+			chunks <- StreamChunk{Content: event.Message.Content}
+		}
+		if calls := fromOllamaToolCalls(event.Message.ToolCalls, numbered); calls != nil {
+			numbered += len(calls)
+			chunks <- StreamChunk{ToolCalls: calls}
+		}
+		if event.Done {
+			usage := event.usage()
+			chunks <- StreamChunk{Usage: &usage}
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		chunks <- StreamChunk{Err: fmt.Errorf("failed to read stream: %w", err)}
+	}
+}
+
+// toOllamaMessages converts messages to Ollama's format. Tool call arguments
+// that are not valid JSON are sent as an empty object.
+func toOllamaMessages(messages []Message) []ollamaMessage {
+	converted := make([]ollamaMessage, len(messages))
+	for i, msg := range messages {
+		converted[i] = ollamaMessage{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			var oc ollamaToolCall
+			oc.Function.Name = call.Function.Name
+			oc.Function.Arguments = json.RawMessage(call.Function.Arguments)
+			if !json.Valid(oc.Function.Arguments) {
+				oc.Function.Arguments = json.RawMessage("{}")
+			}
+			converted[i].ToolCalls = append(converted[i].ToolCalls, oc)
+		}
+	}
+	return converted
+}
+
+// fromOllamaToolCalls converts Ollama tool calls, numbering them from first as
+// Ollama does not assign IDs.
+func fromOllamaToolCalls(calls []ollamaToolCall, first int) []ToolCall {
+	var converted []ToolCall
+	for i, oc := range calls {
+		call := ToolCall{ID: fmt.Sprintf("call_%d", first+i), Type: ToolTypeFunction}
+		call.Function.Name = oc.Function.Name
+		call.Function.Arguments = string(oc.Function.Arguments)
+		converted = append(converted, call)
+	}
+	return converted
+}
@@ -0,0 +1,207 @@
+package cerebras
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OpenAIProvider talks to any server implementing the OpenAI chat completions
+// API, including Cerebras.
+type OpenAIProvider struct {
+	name   string
+	apiURL string
+	apiKey string
+	client *http.Client
+}
+
+type Response struct {
+	Choices []struct {
+		Message Message `json:"message"`
+	} `json:"choices"`
+	Usage Usage `json:"usage"`
+}
+
+type streamResponse struct {
+	Choices []struct {
+		Delta struct {
+			Content   string          `json:"content"`
+			ToolCalls []toolCallDelta `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	// Usage is only set on the last event of the stream
+	Usage *Usage `json:"usage,omitempty"`
+}
+
+// toolCallDelta is a piece of a streamed tool call. The first piece of each
+// call carries its ID and name; the arguments arrive in fragments.
+type toolCallDelta struct {
+	Index    int    `json:"index"`
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+// NewOpenAIProvider creates a provider for the API rooted at baseURL, e.g.
+// "https://api.cerebras.ai/v1".
+func NewOpenAIProvider(name, baseURL, apiKey string) *OpenAIProvider {
+	return &OpenAIProvider{
+		name:   name,
+		apiURL: strings.TrimRight(baseURL, "/") + "/chat/completions",
+		apiKey: apiKey,
+		client: &http.Client{},
+	}
+}
+
+func (p *OpenAIProvider) Name() string {
+	return p.name
+}
+
+func (p *OpenAIProvider) Complete(ctx context.Context, reqBody Request) (Message, Usage, error) {
+	resp, err := p.do(ctx, reqBody)
+	if err != nil {
+		return Message{}, Usage{}, err
+	}
+	defer resp.Body.Close()
+
+	var apiResp Response
+	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
+		return Message{}, Usage{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(apiResp.Choices) == 0 {
+		return Message{}, Usage{}, fmt.Errorf("no choices in response")
+	}
+
+	return apiResp.Choices[0].Message, apiResp.Usage, nil
+}
+
+func (p *OpenAIProvider) Stream(ctx context.Context, reqBody Request) (<-chan StreamChunk, error) {
+	resp, err := p.do(ctx, reqBody)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := make(chan StreamChunk)
+	go readSSE(resp.Body, chunks)
+	return chunks, nil
+}
+
+// do sends the request and returns the raw response for a 2xx status.
+// Any other status is returned as an *APIError.
+func (p *OpenAIProvider) do(ctx context.Context, reqBody Request) (*http.Response, error) {
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiURL, bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform request: %w", err)
+	}
+
+	// If status code is not 2xx (e.g., 200, 201), return an APIError.
+	// This triggers the loop in ChatCompletion to try the next model.
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, &APIError{
+			StatusCode: resp.StatusCode,
+			Body:       string(bodyBytes),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
+	}
+
+	return resp, nil
+}
+
+// readSSE parses server-sent events from body and forwards the content deltas
+// to chunks, closing both when the stream ends. Tool call deltas are assembled
+// and forwarded in one chunk at the end.
+func readSSE(body io.ReadCloser, chunks chan<- StreamChunk) {
+	defer close(chunks)
+	defer body.Close()
+
+	var calls []ToolCall
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			// Blank separators, comments and other SSE fields carry no content
+			continue
+		}
+
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+
+		var event streamResponse
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			chunks <- StreamChunk{Err: fmt.Errorf("failed to decode stream event: %w", err)}
+			return
+		}
+		if event.Usage != nil {
+			chunks <- StreamChunk{Usage: event.Usage}
+		}
+		if len(event.Choices) == 0 {
+			continue
+		}
+		for _, delta := range event.Choices[0].Delta.ToolCalls {
+			calls = mergeToolCallDelta(calls, delta)
+		}
+		if event.Choices[0].Delta.Content == "" {
+			continue
+		}
+
+		chunks <- StreamChunk{Content: event.Choices[0].Delta.Content}
+	}
+
+	if err := scanner.Err(); err != nil {
+		chunks <- StreamChunk{Err: fmt.Errorf("failed to read stream: %w", err)}
+		return
+	}
+	if len(calls) > 0 {
+		chunks <- StreamChunk{ToolCalls: calls}
+	}
+}
+
+// mergeToolCallDelta adds a streamed piece of a tool call to calls.
+func mergeToolCallDelta(calls []ToolCall, delta toolCallDelta) []ToolCall {
+	for len(calls) <= delta.Index {
+		calls = append(calls, ToolCall{Type: ToolTypeFunction})
+	}
+
+	call := &calls[delta.Index]
+	if delta.ID != "" {
+		call.ID = delta.ID
+	}
+	if delta.Type != "" {
+		call.Type = delta.Type
+	}
+	call.Function.Name += delta.Function.Name
+	call.Function.Arguments += delta.Function.Arguments
+	return calls
+}
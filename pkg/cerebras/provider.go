@@ -0,0 +1,48 @@
+package cerebras
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider types accepted by NewProvider.
+const (
+	ProviderCerebras = "cerebras"
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+)
+
+// Provider sends chat requests to a single LLM backend. Non-2xx responses are
+// returned as *APIError so the fallback chain can judge the model's health.
+type Provider interface {
+	// Name identifies the provider in logs and model health reports
+	Name() string
+	// Complete returns the raw assistant message and token usage of a
+	// non-streamed completion
+	Complete(ctx context.Context, req Request) (Message, Usage, error)
+	// Stream returns the raw content deltas of a streamed completion, followed
+	// by a chunk carrying the complete ToolCalls if the model made any and a
+	// chunk carrying Usage if the backend reports it. The channel is closed
+	// when the completion ends; ctx must stay alive until then.
+	Stream(ctx context.Context, req Request) (<-chan StreamChunk, error)
+}
+
+// NewProvider creates a provider of the given type for the API at baseURL.
+// Cerebras and any other OpenAI-compatible API share the same adapter.
+func NewProvider(kind, name, baseURL, apiKey string) (Provider, error) {
+	if name == "" {
+		name = kind
+	}
+	if baseURL == "" {
+		return nil, fmt.Errorf("provider %s: base URL is required", name)
+	}
+
+	switch kind {
+	case ProviderCerebras, ProviderOpenAI:
+		return NewOpenAIProvider(name, baseURL, apiKey), nil
+	case ProviderOllama:
+		return NewOllamaProvider(name, baseURL), nil
+	default:
+		return nil, fmt.Errorf("provider %s: unknown type %q", name, kind)
+	}
+}
@@ -0,0 +1,137 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testMaxTokens is the completion budget of models built by newTestClient
+const testMaxTokens = 2000
+
+// newTestClient builds a client whose models are all served by the
+// OpenAI-compatible stand-in at url.
+func newTestClient(url string, models ...Model) *Client {
+	provider := NewOpenAIProvider("test", url, "test-key")
+	for i := range models {
+		models[i].Provider = provider
+		models[i].MaxTokens = testMaxTokens
+	}
+	return NewClient(models, 1, 1, 0)
+}
+
+// newOllamaServer returns a stand-in for Ollama's /api/chat endpoint
+func newOllamaServer(t *testing.T, pieces []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		var req ollamaRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if req.Options.NumPredict != testMaxTokens {
+			t.Errorf("num_predict = %d, want %d", req.Options.NumPredict, testMaxTokens)
+		}
+
+		if !req.Stream {
+			full := ""
+			for _, p := range pieces {
+				full += p
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"message":           map[string]string{"role": "assistant", "content": full},
+				"done":              true,
+				"prompt_eval_count": 11,
+				"eval_count":        len(pieces),
+			})
+			return
+		}
+
+		for _, p := range pieces {
+			line, _ := json.Marshal(map[string]interface{}{
+				"message": map[string]string{"role": "assistant", "content": p},
+				"done":    false,
+			})
+			fmt.Fprintf(w, "%s\n", line)
+		}
+		fmt.Fprintf(w, `{"message":{"role":"assistant","content":""},"done":true,"prompt_eval_count":11,"eval_count":%d}`+"\n", len(pieces))
+	}))
+}
+
+func TestOllamaProvider(t *testing.T) {
+	server := newOllamaServer(t, []string{"<think>hm</think>", "hello ", "there"})
+	defer server.Close()
+
+	provider, err := NewProvider(ProviderOllama, "local", server.URL, "")
+	if err != nil {
+		t.Fatalf("NewProvider() error = %v", err)
+	}
+	client := NewClient([]Model{{ID: "llama3.1:8b", MaxTokens: testMaxTokens, Provider: provider}}, 1, 1, 0)
+
+	got, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "hello there" {
+		t.Errorf("ChatCompletion() = %q, %v", got, err)
+	}
+
+	chunks, err := client.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	if got, _ := collect(t, chunks); got != "hello there" {
+		t.Errorf("streamed content = %q, want %q", got, "hello there")
+	}
+}
+
+func TestClient_FallsBackAcrossProviders(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	local := newOllamaServer(t, []string{"still here"})
+	defer local.Close()
+
+	primary := NewOpenAIProvider("cerebras", down.URL, "key")
+	backup := NewOllamaProvider("local", local.URL)
+	client := NewClient([]Model{
+		{ID: "llama-3.3-70b", MaxTokens: testMaxTokens, Provider: primary},
+		{ID: "llama-3.3-70b", MaxTokens: testMaxTokens, Provider: backup},
+	}, 1, 1, 0)
+
+	got, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil || got != "still here" {
+		t.Fatalf("ChatCompletion() = %q, %v", got, err)
+	}
+
+	// The same model ID on different providers has separate health
+	health := client.ModelHealth()
+	if health[0].Provider != "cerebras" || health[0].ConsecutiveFailures != 1 {
+		t.Errorf("unexpected primary health: %+v", health[0])
+	}
+	if health[1].Provider != "local" || health[1].ConsecutiveFailures != 0 {
+		t.Errorf("unexpected backup health: %+v", health[1])
+	}
+}
+
+func TestNewProvider(t *testing.T) {
+	tests := []struct {
+		kind    string
+		baseURL string
+		wantErr bool
+	}{
+		{ProviderCerebras, "https://api.cerebras.ai/v1", false},
+		{ProviderOllama, "http://localhost:11434", false},
+		{ProviderOpenAI, "https://api.example.com/v1", false},
+		{ProviderOpenAI, "", true},
+		{"mystery", "http://localhost", true},
+	}
+	for _, tt := range tests {
+		_, err := NewProvider(tt.kind, "", tt.baseURL, "key")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewProvider(%q, %q) error = %v, wantErr %v", tt.kind, tt.baseURL, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package cerebras
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+const (
+	thinkOpenTag  = "<think>"
+	thinkCloseTag = "</think>"
+)
+
+// StreamChunk is a piece of a streamed completion.
+// If the stream fails after it has started, the last chunk carries Err.
+// When the model calls tools, a chunk carrying the complete ToolCalls follows
+// the content. Providers report token usage in a final chunk carrying Usage;
+// the client records it and does not pass it on.
+type StreamChunk struct {
+	Content   string
+	ToolCalls []ToolCall
+	Usage     *Usage
+	Err       error
+}
+
+// ChatCompletionStream works like ChatCompletion but returns the reply as it is
+// generated. Models are only cycled while opening the stream; once a model has
+// answered with a 2xx status its deltas are delivered on the returned channel,
+// which is closed when the completion ends. <think> blocks are removed even when
+// a tag is split across deltas. Callers must drain the channel.
+//
+// The client's timeout covers the whole stream. If ctx is cancelled mid-stream
+// the last chunk carries the resulting read error.
+func (c *Client) ChatCompletionStream(ctx context.Context, messages []Message) (<-chan StreamChunk, error) {
+	return c.ChatCompletionStreamTools(ctx, messages, nil)
+}
+
+// ChatCompletionStreamTools works like ChatCompletionStream but offers tools to
+// the model, as ChatCompletionTools does. Tool calls are delivered in a single
+// chunk once they are complete.
+func (c *Client) ChatCompletionStreamTools(ctx context.Context, messages []Message, tools []Tool) (<-chan StreamChunk, error) {
+	var lastErr error
+
+	for _, model := range c.models {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		reqBody, err := c.prepare(model, messages, true, nil, tools)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		log.Printf("Attempting to stream from model: %s", model.name())
+		attemptCtx, cancel := c.attemptContext(ctx)
+		raw, err := model.Provider.Stream(attemptCtx, reqBody)
+		if err == nil {
+			c.health.success(model.name())
+			chunks := make(chan StreamChunk)
+			go func() {
+				defer cancel()
+				filterThink(raw, chunks, func(usage Usage) {
+					c.recordUsage(ctx, model, usage)
+				})
+			}()
+			return chunks, nil
+		}
+		cancel()
+		if ctx.Err() != nil {
+			c.health.release(model.name())
+			return nil, ctx.Err()
+		}
+		c.health.failure(model.name(), err)
+
+		lastErr = describeFailure(model, err)
+	}
+
+	return nil, fmt.Errorf("all models exhausted. Last error: %w", lastErr)
+}
+
+// filterThink forwards raw chunks to out with <think> blocks removed, closing
+// out once raw is exhausted. Usage chunks are handed to onUsage instead.
+func filterThink(raw <-chan StreamChunk, out chan<- StreamChunk, onUsage func(Usage)) {
+	defer close(out)
+
+	filter := &thinkFilter{}
+	for chunk := range raw {
+		if chunk.Usage != nil {
+			onUsage(*chunk.Usage)
+			continue
+		}
+		if chunk.ToolCalls != nil {
+			// Tool calls end the content, so release anything held back first
+			if content := filter.Flush(); content != "" {
+				out <- StreamChunk{Content: content}
+			}
+			out <- chunk
+			continue
+		}
+		if chunk.Err != nil {
+			out <- chunk
+			continue
+		}
+		if content := filter.Write(chunk.Content); content != "" {
+			out <- StreamChunk{Content: content}
+		}
+	}
+
+	if content := filter.Flush(); content != "" {
+		out <- StreamChunk{Content: content}
+	}
+}
+
+// thinkFilter strips <think>...</think> blocks from text that arrives in
+// arbitrary pieces. Anything that could still turn out to be the start of a
+// tag is held back until the next write resolves it.
+type thinkFilter struct {
+	pending string
+	inThink bool
+}
+
+func (f *thinkFilter) Write(s string) string {
+	f.pending += s
+	var out strings.Builder
+
+	for {
+		if f.inThink {
+			idx := strings.Index(f.pending, thinkCloseTag)
+			if idx == -1 {
+				// Drop the hidden text but keep a possible partial closing tag
+				f.pending = f.pending[len(f.pending)-partialSuffix(f.pending, thinkCloseTag):]
+				return out.String()
+			}
+			f.pending = f.pending[idx+len(thinkCloseTag):]
+			f.inThink = false
+			continue
+		}
+
+		idx := strings.Index(f.pending, thinkOpenTag)
+		if idx == -1 {
+			keep := partialSuffix(f.pending, thinkOpenTag)
+			out.WriteString(f.pending[:len(f.pending)-keep])
+			f.pending = f.pending[len(f.pending)-keep:]
+			return out.String()
+		}
+		out.WriteString(f.pending[:idx])
+		f.pending = f.pending[idx+len(thinkOpenTag):]
+		f.inThink = true
+	}
+}
+
+// Flush returns any held back text once the stream has ended. An unterminated
+// <think> block is discarded.
+func (f *thinkFilter) Flush() string {
+	out := ""
+	if !f.inThink {
+		out = f.pending
+	}
+	f.pending = ""
+	return out
+}
+
+// partialSuffix returns the length of the longest suffix of s that is a proper
+// prefix of tag.
+func partialSuffix(s, tag string) int {
+	for n := len(tag) - 1; n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,132 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newSSEServer returns a stand-in for the chat completions endpoint that
+// streams each delta as its own server-sent event.
+func newSSEServer(t *testing.T, deltas []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		if !req.Stream {
+			t.Errorf("expected stream=true in request")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for _, d := range deltas {
+			payload, _ := json.Marshal(map[string]interface{}{
+				"choices": []map[string]interface{}{
+					{"delta": map[string]string{"content": d}},
+				},
+			})
+			fmt.Fprintf(w, "data: %s\n\n", payload)
+			flusher.Flush()
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func collect(t *testing.T, chunks <-chan StreamChunk) (string, int) {
+	var sb strings.Builder
+	count := 0
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		sb.WriteString(chunk.Content)
+		count++
+	}
+	return sb.String(), count
+}
+
+func TestChatCompletionStream_StripsSplitThinkTags(t *testing.T) {
+	server := newSSEServer(t, []string{
+		"<thi", "nk>plotting ", "something</th", "ink>", "hmph, ", "fine. <", "3",
+	})
+	defer server.Close()
+
+	client := newTestClient(server.URL, Model{ID: "test"})
+
+	chunks, err := client.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+
+	got, count := collect(t, chunks)
+	if got != "hmph, fine. <3" {
+		t.Errorf("stream content = %q, want %q", got, "hmph, fine. <3")
+	}
+	if count < 2 {
+		t.Errorf("expected content to arrive in several chunks, got %d", count)
+	}
+}
+
+func TestChatCompletionStream_FallsBackOnErrorStatus(t *testing.T) {
+	good := newSSEServer(t, []string{"ok"})
+	defer good.Close()
+
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		good.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL, Model{ID: "first"}, Model{ID: "second"})
+
+	chunks, err := client.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	if got, _ := collect(t, chunks); got != "ok" {
+		t.Errorf("stream content = %q, want %q", got, "ok")
+	}
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestThinkFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		pieces []string
+		want   string
+	}{
+		{"No tags", []string{"hello ", "there"}, "hello there"},
+		{"Whole block", []string{"<think>x</think>hi"}, "hi"},
+		{"Split open tag", []string{"a<", "thin", "k>x</think>b"}, "ab"},
+		{"Split close tag", []string{"<think>x</", "think", ">b"}, "b"},
+		{"Lookalike", []string{"a <", "b"}, "a <b"},
+		{"Unterminated", []string{"a<think>never closed"}, "a"},
+		{"Trailing partial", []string{"a <thi"}, "a <thi"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &thinkFilter{}
+			var sb strings.Builder
+			for _, p := range tt.pieces {
+				sb.WriteString(f.Write(p))
+			}
+			sb.WriteString(f.Flush())
+			if sb.String() != tt.want {
+				t.Errorf("filtered = %q, want %q", sb.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package cerebras
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Trim priorities mark system blocks that may be shortened or dropped when a
+// prompt does not fit a model's context window. Blocks with a higher value are
+// trimmed first; TrimNever blocks are always sent as-is.
+const (
+	TrimNever = iota
+	TrimMemories
+	TrimRollingContext
+	TrimEmojis
+)
+
+const (
+	// charsPerToken is a deliberately pessimistic average for English chat text
+	charsPerToken = 3
+	// messageOverhead covers the role and formatting tokens added per message
+	messageOverhead = 4
+	// defaultCompletionReserve is the room left for the reply of a model
+	// without a MaxTokens budget
+	defaultCompletionReserve = 1024
+)
+
+// RollingContext builds a TrimRollingContext block listing entries, oldest
+// first, under header.
+func RollingContext(header string, entries []string) Message {
+	return Message{
+		Role:    "system",
+		Content: header + "\n" + strings.Join(entries, "\n"),
+		Trim:    TrimRollingContext,
+		Entries: entries,
+	}
+}
+
+// EstimateTokens returns a rough, conservative token count for messages.
+// It does not need to match any tokenizer exactly; it only has to keep us from
+// sending prompts that a model's context window cannot hold.
+func EstimateTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		chars := len(msg.Content)
+		for _, call := range msg.ToolCalls {
+			chars += len(call.Function.Name) + len(call.Function.Arguments)
+		}
+		total += messageOverhead + (chars+charsPerToken-1)/charsPerToken
+	}
+	return total
+}
+
+// estimateRequestTokens returns a conservative token count for the parts of a
+// request besides its messages that count against the context window: the
+// response format's JSON schema and the tool definitions.
+func estimateRequestTokens(format *ResponseFormat, tools []Tool) int {
+	chars := 0
+	if format != nil {
+		if data, err := json.Marshal(format); err == nil {
+			chars += len(data)
+		}
+	}
+	if len(tools) > 0 {
+		if data, err := json.Marshal(tools); err == nil {
+			chars += len(data)
+		}
+	}
+	return (chars + charsPerToken - 1) / charsPerToken
+}
+
+// fitToContext trims messages until their estimated size is within budget.
+// Blocks are trimmed in descending Trim priority. Rolling context loses its
+// oldest entries one at a time before being dropped, or its oldest lines if
+// its entries are unknown; other blocks are dropped whole. It reports false
+// if the prompt cannot be made to fit.
+func fitToContext(messages []Message, budget int) ([]Message, bool) {
+	if EstimateTokens(messages) <= budget {
+		return messages, true
+	}
+
+	fitted := make([]Message, len(messages))
+	copy(fitted, messages)
+
+	for EstimateTokens(fitted) > budget {
+		idx := -1
+		for i, msg := range fitted {
+			if msg.Trim != TrimNever && (idx == -1 || msg.Trim > fitted[idx].Trim) {
+				idx = i
+			}
+		}
+		if idx == -1 {
+			return nil, false
+		}
+
+		if block := fitted[idx]; block.Trim == TrimRollingContext && len(block.Entries) > 1 {
+			header, _, _ := strings.Cut(block.Content, "\n")
+			fitted[idx] = RollingContext(header, block.Entries[1:])
+			continue
+		}
+		if fitted[idx].Trim == TrimRollingContext && fitted[idx].Entries == nil {
+			// The first line is the block header; drop the oldest line after it
+			lines := strings.Split(fitted[idx].Content, "\n")
+			if len(lines) > 2 {
+				fitted[idx].Content = strings.Join(append(lines[:1], lines[2:]...), "\n")
+				continue
+			}
+		}
+
+		fitted = append(fitted[:idx], fitted[idx+1:]...)
+	}
+
+	return fitted, true
+}
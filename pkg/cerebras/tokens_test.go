@@ -0,0 +1,205 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if got := EstimateTokens(nil); got != 0 {
+		t.Errorf("EstimateTokens(nil) = %d, want 0", got)
+	}
+
+	short := EstimateTokens([]Message{{Role: "user", Content: "hi"}})
+	long := EstimateTokens([]Message{{Role: "user", Content: strings.Repeat("word ", 400)}})
+	if short <= 0 || long <= short {
+		t.Errorf("expected estimates to grow with content, got short=%d long=%d", short, long)
+	}
+	if long < 2000/charsPerToken {
+		t.Errorf("estimate %d is not conservative for 2000 characters", long)
+	}
+}
+
+func TestFitToContext(t *testing.T) {
+	base := []Message{
+		{Role: "system", Content: strings.Repeat("p", 300)},
+		{Role: "system", Content: "Relevant past memories:\n- likes tea", Trim: TrimMemories},
+		{Role: "system", Content: "Recent conversation:\nold line\nmiddle line\nnew line", Trim: TrimRollingContext},
+		{Role: "system", Content: "Available custom emojis:\n" + strings.Repeat("e", 60), Trim: TrimEmojis},
+		{Role: "user", Content: "hello"},
+	}
+	full := EstimateTokens(base)
+
+	t.Run("Fits untouched", func(t *testing.T) {
+		got, ok := fitToContext(base, full)
+		if !ok || len(got) != len(base) {
+			t.Fatalf("expected prompt to be unchanged, got %d messages (ok=%v)", len(got), ok)
+		}
+	})
+
+	t.Run("Drops emojis first", func(t *testing.T) {
+		got, ok := fitToContext(base, full-1)
+		if !ok {
+			t.Fatal("expected prompt to fit")
+		}
+		for _, msg := range got {
+			if msg.Trim == TrimEmojis {
+				t.Error("expected emoji block to be dropped")
+			}
+		}
+		if len(got) != len(base)-1 {
+			t.Errorf("expected only the emoji block to be dropped, got %d messages", len(got))
+		}
+	})
+
+	t.Run("Then trims oldest rolling context", func(t *testing.T) {
+		withoutEmojis := EstimateTokens(base) - EstimateTokens(base[3:4])
+		got, ok := fitToContext(base, withoutEmojis-1)
+		if !ok {
+			t.Fatal("expected prompt to fit")
+		}
+		var rolling string
+		for _, msg := range got {
+			if msg.Trim == TrimRollingContext {
+				rolling = msg.Content
+			}
+			if msg.Trim == TrimMemories && msg.Content == "" {
+				t.Error("memories should survive while rolling context remains")
+			}
+		}
+		if strings.Contains(rolling, "old line") {
+			t.Errorf("expected oldest line to be trimmed, got %q", rolling)
+		}
+		if !strings.Contains(rolling, "new line") {
+			t.Errorf("expected newest line to be kept, got %q", rolling)
+		}
+	})
+
+	t.Run("Trims rolling context by whole entries", func(t *testing.T) {
+		prompt := []Message{
+			{Role: "system", Content: "prompt"},
+			RollingContext("Recent conversation:", []string{"Alex: first\nstill first", "Nino: second", "Alex: third"}),
+		}
+		got, ok := fitToContext(prompt, EstimateTokens(prompt)-1)
+		if !ok {
+			t.Fatal("expected prompt to fit")
+		}
+		want := "Recent conversation:\nNino: second\nAlex: third"
+		if got[1].Content != want || len(got[1].Entries) != 2 {
+			t.Errorf("expected the oldest entry to be dropped whole, got %q", got[1].Content)
+		}
+		if len(prompt[1].Entries) != 3 {
+			t.Error("fitToContext must not modify its input")
+		}
+	})
+
+	t.Run("Never drops required blocks", func(t *testing.T) {
+		if _, ok := fitToContext(base, 10); ok {
+			t.Error("expected prompt not to fit")
+		}
+		if got := EstimateTokens(base); got != full {
+			t.Error("fitToContext must not modify its input")
+		}
+	})
+}
+
+func TestChatCompletion_SkipsModelsThatCannotFit(t *testing.T) {
+	var models []string
+	var lastMessages []Message
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string    `json:"model"`
+			Messages []Message `json:"messages"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		models = append(models, req.Model)
+		lastMessages = req.Messages
+		w.Write([]byte(`{"choices":[{"message":{"content":"ok"}}]}`))
+	}))
+	defer server.Close()
+
+	prompt := []Message{
+		{Role: "system", Content: strings.Repeat("x", 9000)},
+		{Role: "system", Content: strings.Repeat("e", 3000), Trim: TrimEmojis},
+		{Role: "user", Content: "hi"},
+	}
+
+	t.Run("Skips too small model", func(t *testing.T) {
+		models = nil
+		client := newTestClient(server.URL, Model{ID: "tiny", MaxCtx: 2500}, Model{ID: "big", MaxCtx: 65536})
+		if _, err := client.ChatCompletion(context.Background(), prompt); err != nil {
+			t.Fatalf("ChatCompletion() error = %v", err)
+		}
+		if len(models) != 1 || models[0] != "big" {
+			t.Errorf("expected only the big model to be called, got %v", models)
+		}
+		if len(lastMessages) != len(prompt) {
+			t.Errorf("expected the big model to receive the full prompt, got %d messages", len(lastMessages))
+		}
+	})
+
+	t.Run("Reserves room for the reply without MaxTokens", func(t *testing.T) {
+		models = nil
+		// The prompt fits the window exactly but leaves no room to answer
+		client := newTestClient(server.URL, Model{ID: "exact", MaxCtx: EstimateTokens(prompt[:1]) + EstimateTokens(prompt[2:])})
+		if _, err := client.ChatCompletion(context.Background(), prompt); err == nil {
+			t.Error("expected the model to be skipped")
+		}
+		if len(models) != 0 {
+			t.Errorf("expected no model to be called, got %v", models)
+		}
+	})
+
+	t.Run("Trims to fit smaller model", func(t *testing.T) {
+		models = nil
+		client := newTestClient(server.URL, Model{ID: "small", MaxCtx: 5200})
+		if _, err := client.ChatCompletion(context.Background(), prompt); err != nil {
+			t.Fatalf("ChatCompletion() error = %v", err)
+		}
+		if len(models) != 1 || models[0] != "small" {
+			t.Errorf("expected the small model to be called, got %v", models)
+		}
+		if len(lastMessages) != 2 {
+			t.Errorf("expected the emoji block to be trimmed, got %d messages", len(lastMessages))
+		}
+	})
+
+	t.Run("Errors when nothing fits", func(t *testing.T) {
+		models = nil
+		client := newTestClient(server.URL, Model{ID: "tiny", MaxCtx: 2500})
+		if _, err := client.ChatCompletion(context.Background(), prompt); err == nil {
+			t.Error("expected an error when no model can hold the prompt")
+		}
+		if len(models) != 0 {
+			t.Errorf("expected no requests, got %v", models)
+		}
+	})
+}
+
+func TestFitModel_CountsSchemasAndTools(t *testing.T) {
+	prompt := []Message{
+		{Role: "system", Content: strings.Repeat("x", 3000)},
+		{Role: "user", Content: "hi"},
+	}
+	tools := []Tool{{Type: "function", Function: ToolFunction{
+		Name:       "lookup",
+		Parameters: json.RawMessage(`{"type": "object", "description": "` + strings.Repeat("p", 3000) + `"}`),
+	}}}
+	format := &ResponseFormat{Type: FormatJSONSchema, JSONSchema: &JSONSchema{Name: "answer", Schema: tools[0].Function.Parameters}}
+
+	// The prompt alone fits the window exactly
+	model := Model{ID: "m", MaxCtx: EstimateTokens(prompt) + 100, MaxTokens: 100, Provider: NewOpenAIProvider("test", "", "")}
+	if _, ok := fitModel(model, prompt, estimateRequestTokens(nil, nil)); !ok {
+		t.Fatal("expected the prompt alone to fit")
+	}
+	if _, ok := fitModel(model, prompt, estimateRequestTokens(nil, tools)); ok {
+		t.Error("expected the tool definitions to count against the window")
+	}
+	if _, ok := fitModel(model, prompt, estimateRequestTokens(format, nil)); ok {
+		t.Error("expected the response schema to count against the window")
+	}
+}
@@ -0,0 +1,50 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ToolTypeFunction is the only tool type supported by the chat APIs
+const ToolTypeFunction = "function"
+
+// Tool describes a function the model may call instead of answering directly.
+type Tool struct {
+	Type     string       `json:"type"`
+	Function ToolFunction `json:"function"`
+}
+
+// ToolFunction is the name, purpose and JSON schema of a tool's arguments.
+type ToolFunction struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+// ToolCall is a call the model made in an assistant message. Its result is
+// sent back in a "tool" message with the same ToolCallID.
+type ToolCall struct {
+	ID       string           `json:"id"`
+	Type     string           `json:"type"`
+	Function ToolCallFunction `json:"function"`
+}
+
+// ToolCallFunction names the called tool. Arguments is a JSON object encoded
+// as a string, as generated by the model; it may be malformed.
+type ToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// ChatCompletionTools works like ChatCompletion but offers tools to the model.
+// The returned assistant message either answers directly or carries ToolCalls,
+// in which case the caller runs them, appends the message and one "tool"
+// message per call, and asks again.
+func (c *Client) ChatCompletionTools(ctx context.Context, messages []Message, tools []Tool) (Message, error) {
+	msg, err := c.complete(ctx, messages, nil, tools)
+	if err != nil {
+		return Message{}, err
+	}
+	msg.Content = CleanResponse(msg.Content)
+	return msg, nil
+}
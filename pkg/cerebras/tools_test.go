@@ -0,0 +1,175 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var testTools = []Tool{{
+	Type: ToolTypeFunction,
+	Function: ToolFunction{
+		Name:        "current_time",
+		Description: "Returns the current time",
+		Parameters:  json.RawMessage(`{"type":"object","properties":{"timezone":{"type":"string"}}}`),
+	},
+}}
+
+func TestChatCompletionTools(t *testing.T) {
+	var requests []Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		requests = append(requests, req)
+
+		message := map[string]interface{}{"role": "assistant", "content": "it's noon, idiot"}
+		if len(requests) == 1 {
+			message = map[string]interface{}{
+				"role":    "assistant",
+				"content": nil,
+				"tool_calls": []map[string]interface{}{{
+					"id":       "call_abc",
+					"type":     "function",
+					"function": map[string]string{"name": "current_time", "arguments": `{"timezone":"Asia/Tokyo"}`},
+				}},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"choices": []map[string]interface{}{{"message": message}},
+		})
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL, Model{ID: "m"})
+
+	messages := []Message{{Role: "user", Content: "what time is it?"}}
+	msg, err := client.ChatCompletionTools(context.Background(), messages, testTools)
+	if err != nil {
+		t.Fatalf("ChatCompletionTools() error = %v", err)
+	}
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Function.Name != "current_time" {
+		t.Errorf("expected the tools in the request, got %+v", requests[0].Tools)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_abc" || msg.ToolCalls[0].Function.Arguments != `{"timezone":"Asia/Tokyo"}` {
+		t.Fatalf("unexpected tool calls: %+v", msg.ToolCalls)
+	}
+
+	messages = append(messages, msg, Message{Role: "tool", ToolCallID: "call_abc", Content: "12:00"})
+	msg, err = client.ChatCompletionTools(context.Background(), messages, testTools)
+	if err != nil || msg.Content != "it's noon, idiot" || msg.ToolCalls != nil {
+		t.Fatalf("ChatCompletionTools() = %+v, %v", msg, err)
+	}
+
+	sent := requests[1].Messages
+	if len(sent) != 3 || sent[1].ToolCalls[0].ID != "call_abc" || sent[2].Role != "tool" || sent[2].ToolCallID != "call_abc" {
+		t.Errorf("tool round trip not sent back: %+v", sent)
+	}
+}
+
+func TestChatCompletionStreamTools_AssemblesDeltas(t *testing.T) {
+	events := []string{
+		`{"choices":[{"delta":{"content":"hold on"}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"current_time","arguments":""}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"timezone\":"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"UTC\"}"}}]}}]}`,
+		`{"choices":[{"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"current_time","arguments":"{}"}}]}}]}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if len(req.Tools) != 1 {
+			t.Errorf("expected the tools in the request, got %+v", req.Tools)
+		}
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\n\n", e)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+	client := newTestClient(server.URL, Model{ID: "m"})
+
+	chunks, err := client.ChatCompletionStreamTools(context.Background(), []Message{{Role: "user", Content: "time?"}}, testTools)
+	if err != nil {
+		t.Fatalf("ChatCompletionStreamTools() error = %v", err)
+	}
+
+	var content string
+	var calls []ToolCall
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatalf("unexpected stream error: %v", chunk.Err)
+		}
+		content += chunk.Content
+		calls = append(calls, chunk.ToolCalls...)
+	}
+
+	if content != "hold on" {
+		t.Errorf("content = %q, want %q", content, "hold on")
+	}
+	if len(calls) != 2 || calls[0].ID != "call_1" || calls[0].Function.Arguments != `{"timezone":"UTC"}` || calls[1].ID != "call_2" {
+		t.Errorf("unexpected tool calls: %+v", calls)
+	}
+}
+
+func TestOllamaProvider_ToolCalls(t *testing.T) {
+	var sent []ollamaMessage
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req ollamaRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sent = req.Messages
+		if len(req.Tools) != 1 {
+			t.Errorf("expected the tools in the request, got %+v", req.Tools)
+		}
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"current_time","arguments":{"timezone":"UTC"}}}]},"done":true}`))
+	}))
+	defer server.Close()
+
+	client := NewClient([]Model{{ID: "llama3.1:8b", Provider: NewOllamaProvider("local", server.URL)}}, 1, 1, 0)
+	messages := []Message{
+		{Role: "user", Content: "time?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_0", Type: ToolTypeFunction, Function: ToolCallFunction{Name: "current_time", Arguments: `{"timezone":"UTC"}`}}}},
+		{Role: "tool", ToolCallID: "call_0", Content: "12:00"},
+	}
+	msg, err := client.ChatCompletionTools(context.Background(), messages, testTools)
+	if err != nil {
+		t.Fatalf("ChatCompletionTools() error = %v", err)
+	}
+
+	// Arguments are objects on the wire and strings in Message
+	if args := string(sent[1].ToolCalls[0].Function.Arguments); args != `{"timezone":"UTC"}` {
+		t.Errorf("sent arguments = %s", args)
+	}
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].ID != "call_0" || msg.ToolCalls[0].Function.Arguments != `{"timezone":"UTC"}` {
+		t.Errorf("unexpected tool calls: %+v", msg.ToolCalls)
+	}
+}
+
+func TestOllamaProvider_StreamedToolCallIDs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Ollama may send each tool call in its own line
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"current_time","arguments":{}}}]},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":"","tool_calls":[{"function":{"name":"search_memories","arguments":{}}}]},"done":false}` + "\n"))
+		w.Write([]byte(`{"message":{"role":"assistant","content":""},"done":true}` + "\n"))
+	}))
+	defer server.Close()
+
+	client := NewClient([]Model{{ID: "llama3.1:8b", Provider: NewOllamaProvider("local", server.URL)}}, 1, 1, 0)
+	chunks, err := client.ChatCompletionStreamTools(context.Background(), []Message{{Role: "user", Content: "time?"}}, testTools)
+	if err != nil {
+		t.Fatalf("ChatCompletionStreamTools() error = %v", err)
+	}
+
+	var ids []string
+	for chunk := range chunks {
+		for _, call := range chunk.ToolCalls {
+			ids = append(ids, call.ID)
+		}
+	}
+	if len(ids) != 2 || ids[0] != "call_0" || ids[1] != "call_1" {
+		t.Errorf("expected tool call IDs to be unique across the stream, got %v", ids)
+	}
+}
@@ -0,0 +1,36 @@
+package cerebras
+
+import (
+	"context"
+)
+
+// Usage is the token count reported by a provider for a single completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// UsageRecorder receives the token usage of every successful completion. ctx
+// is the context the completion was requested with, so recorders can read any
+// attribution the caller attached to it.
+type UsageRecorder interface {
+	RecordUsage(ctx context.Context, model string, usage Usage)
+}
+
+// SetUsageRecorder makes the client report token usage to r. It must be called
+// before the client is used.
+func (c *Client) SetUsageRecorder(r UsageRecorder) {
+	c.recorder = r
+}
+
+// recordUsage reports usage for model, filling in a missing total.
+func (c *Client) recordUsage(ctx context.Context, model Model, usage Usage) {
+	if c.recorder == nil {
+		return
+	}
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	c.recorder.RecordUsage(ctx, model.name(), usage)
+}
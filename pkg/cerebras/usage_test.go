@@ -0,0 +1,118 @@
+package cerebras
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type recordedUsage struct {
+	model string
+	usage Usage
+	kind  interface{}
+}
+
+type usageKey struct{}
+
+// fakeRecorder collects recorded usage along with the value stored under
+// usageKey in the request context.
+type fakeRecorder struct {
+	mu      sync.Mutex
+	records []recordedUsage
+}
+
+func (r *fakeRecorder) RecordUsage(ctx context.Context, model string, usage Usage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, recordedUsage{model: model, usage: usage, kind: ctx.Value(usageKey{})})
+}
+
+func TestChatCompletion_RecordsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"choices":[{"message":{"content":"hi"}}],"usage":{"prompt_tokens":120,"completion_tokens":8,"total_tokens":128}}`))
+	}))
+	defer server.Close()
+
+	recorder := &fakeRecorder{}
+	client := newTestClient(server.URL, Model{ID: "m"})
+	client.SetUsageRecorder(recorder)
+
+	ctx := context.WithValue(context.Background(), usageKey{}, "reply")
+	if _, err := client.ChatCompletion(ctx, []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+
+	if len(recorder.records) != 1 {
+		t.Fatalf("expected 1 usage record, got %d", len(recorder.records))
+	}
+	got := recorder.records[0]
+	if got.model != "test/m" || got.usage != (Usage{PromptTokens: 120, CompletionTokens: 8, TotalTokens: 128}) || got.kind != "reply" {
+		t.Errorf("unexpected usage record: %+v", got)
+	}
+}
+
+func TestChatCompletionStream_RecordsUsage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("expected stream_options.include_usage in request")
+		}
+
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"hello\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[],\"usage\":{\"prompt_tokens\":50,\"completion_tokens\":1}}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	recorder := &fakeRecorder{}
+	client := newTestClient(server.URL, Model{ID: "m"})
+	client.SetUsageRecorder(recorder)
+
+	chunks, err := client.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	if got, _ := collect(t, chunks); got != "hello" {
+		t.Errorf("streamed content = %q, want %q", got, "hello")
+	}
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	if len(recorder.records) != 1 {
+		t.Fatalf("expected 1 usage record, got %d", len(recorder.records))
+	}
+	// A missing total is derived from its parts
+	if got := recorder.records[0].usage; got != (Usage{PromptTokens: 50, CompletionTokens: 1, TotalTokens: 51}) {
+		t.Errorf("unexpected usage: %+v", got)
+	}
+}
+
+func TestOllamaProvider_RecordsUsage(t *testing.T) {
+	server := newOllamaServer(t, []string{"a", "b", "c"})
+	defer server.Close()
+
+	recorder := &fakeRecorder{}
+	client := NewClient([]Model{{ID: "llama3.1:8b", MaxTokens: testMaxTokens, Provider: NewOllamaProvider("local", server.URL)}}, 1, 1, 0)
+	client.SetUsageRecorder(recorder)
+
+	if _, err := client.ChatCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}}); err != nil {
+		t.Fatalf("ChatCompletion() error = %v", err)
+	}
+	chunks, err := client.ChatCompletionStream(context.Background(), []Message{{Role: "user", Content: "hi"}})
+	if err != nil {
+		t.Fatalf("ChatCompletionStream() error = %v", err)
+	}
+	collect(t, chunks)
+
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	want := Usage{PromptTokens: 11, CompletionTokens: 3, TotalTokens: 14}
+	if len(recorder.records) != 2 || recorder.records[0].usage != want || recorder.records[1].usage != want {
+		t.Errorf("unexpected usage records: %+v", recorder.records)
+	}
+}
@@ -0,0 +1,102 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// chainCooldown is how long a classifier that failed is skipped, so that an
+// outage doesn't add a timeout to every message.
+const chainCooldown = time.Minute
+
+// Classifier scores how well labels fit a text.
+type Classifier interface {
+	// Classify returns the best label and its score.
+	Classify(ctx context.Context, text string, labels []string) (string, float64, error)
+	// ClassifyAll returns the score of every label, highest first.
+	ClassifyAll(ctx context.Context, text string, labels []string) ([]ClassificationResult, error)
+}
+
+// Named gives a classifier a name for logs.
+type Named struct {
+	Name string
+	Classifier
+}
+
+// Chain asks its classifiers in order and returns the first answer, so that
+// later ones take over while earlier ones fail.
+type Chain struct {
+	classifiers []Named
+	mu          sync.Mutex
+	downUntil   map[string]time.Time // Classifiers cooling down after a failure
+}
+
+func NewChain(classifiers ...Named) *Chain {
+	return &Chain{
+		classifiers: classifiers,
+		downUntil:   make(map[string]time.Time),
+	}
+}
+
+func (c *Chain) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := c.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (c *Chain) ClassifyAll(ctx context.Context, text string, labels []string) ([]ClassificationResult, error) {
+	var errs []error
+	for i, cl := range c.classifiers {
+		last := i == len(c.classifiers)-1
+		if !last && c.coolingDown(cl.Name) {
+			continue
+		}
+
+		results, err := cl.ClassifyAll(ctx, text, labels)
+		if ctx.Err() != nil {
+			// Cancellation says nothing about the classifier's health
+			return nil, ctx.Err()
+		}
+		c.report(cl.Name, err)
+		if err == nil {
+			return results, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", cl.Name, err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no classifier available")
+	}
+	return nil, errors.Join(errs...)
+}
+
+func (c *Chain) coolingDown(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return time.Now().Before(c.downUntil[name])
+}
+
+// report records the outcome of a call, logging when a classifier goes down
+// or recovers.
+func (c *Chain) report(name string, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, wasDown := c.downUntil[name]
+	if err != nil {
+		if !wasDown {
+			log.Printf("Classifier %s failed, skipping it for %s: %v", name, chainCooldown, err)
+		}
+		c.downUntil[name] = time.Now().Add(chainCooldown)
+		return
+	}
+	if wasDown {
+		log.Printf("Classifier %s recovered", name)
+		delete(c.downUntil, name)
+	}
+}
@@ -0,0 +1,63 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubClassifier struct {
+	label string
+	err   error
+	calls int
+}
+
+func (s *stubClassifier) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := s.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (s *stubClassifier) ClassifyAll(context.Context, string, []string) ([]ClassificationResult, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []ClassificationResult{{Label: s.label, Score: 0.9}}, nil
+}
+
+func TestChain(t *testing.T) {
+	primary := &stubClassifier{label: "primary"}
+	fallback := &stubClassifier{label: "fallback"}
+	chain := NewChain(Named{"primary", primary}, Named{"fallback", fallback})
+	ctx := context.Background()
+	labels := []string{"primary", "fallback"}
+
+	if label, _, err := chain.Classify(ctx, "hi", labels); err != nil || label != "primary" {
+		t.Fatalf("expected the primary's answer, got %q, %v", label, err)
+	}
+
+	primary.err = errors.New("api down")
+	if label, _, err := chain.Classify(ctx, "hi", labels); err != nil || label != "fallback" {
+		t.Fatalf("expected the fallback's answer while the primary fails, got %q, %v", label, err)
+	}
+	// The failed primary cools down instead of being retried on every message
+	chain.Classify(ctx, "hi", labels)
+	if primary.calls != 2 {
+		t.Errorf("expected the primary to be skipped while cooling down, got %d calls", primary.calls)
+	}
+
+	fallback.err = errors.New("also down")
+	if _, _, err := chain.Classify(ctx, "hi", labels); err == nil {
+		t.Error("expected an error when every classifier fails")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	single := NewChain(Named{"only", &stubClassifier{err: context.Canceled}})
+	if _, _, err := single.Classify(cancelled, "hi", labels); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation to be returned, got %v", err)
+	}
+}
@@ -2,23 +2,30 @@ package classifier
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"time"
 )
 
 const apiURL = "https://router.huggingface.co/hf-inference/models/facebook/bart-large-mnli"
 
 type Client struct {
-	apiKey string
-	client *http.Client
+	apiKey     string
+	url        string
+	client     *http.Client
+	multiLabel bool
 }
 
-func NewClient(apiKey string) *Client {
+// NewClient creates a classifier client. timeout bounds each request; 0 means none.
+func NewClient(apiKey string, timeout time.Duration) *Client {
 	return &Client{
 		apiKey: apiKey,
-		client: &http.Client{},
+		url:    apiURL,
+		client: &http.Client{Timeout: timeout},
 	}
 }
 
@@ -32,28 +39,45 @@ type Parameters struct {
 	MultiLabel      bool     `json:"multi_label,omitempty"`
 }
 
+// ClassificationResult is the score of one label.
 type ClassificationResult struct {
 	Label string  `json:"label"`
 	Score float64 `json:"score"`
 }
 
+// SetMultiLabel scores each label on its own instead of splitting one unit
+// of probability between them, for labels that are not mutually exclusive.
+func (c *Client) SetMultiLabel(multiLabel bool) {
+	c.multiLabel = multiLabel
+}
+
 // Classify returns the top label and its score for the given text
-func (c *Client) Classify(text string, labels []string) (string, float64, error) {
+func (c *Client) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := c.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+// ClassifyAll returns the score of every label, highest first.
+func (c *Client) ClassifyAll(ctx context.Context, text string, labels []string) ([]ClassificationResult, error) {
 	reqBody := Request{
 		Inputs: text,
 		Parameters: Parameters{
 			CandidateLabels: labels,
+			MultiLabel:      c.multiLabel,
 		},
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewBuffer(jsonBody))
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
@@ -61,24 +85,31 @@ func (c *Client) Classify(text string, labels []string) (string, float64, error)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return "", 0, fmt.Errorf("failed to perform request: %w", err)
+		return nil, fmt.Errorf("failed to perform request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", 0, fmt.Errorf("api status %d: %s", resp.StatusCode, string(bodyBytes))
+		return nil, fmt.Errorf("api status %d: %s", resp.StatusCode, string(bodyBytes))
 	}
 
 	var apiResp []ClassificationResult
 	if err := json.NewDecoder(resp.Body).Decode(&apiResp); err != nil {
-		return "", 0, fmt.Errorf("failed to decode response: %w", err)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
 	if len(apiResp) == 0 {
-		return "", 0, fmt.Errorf("empty response from classifier")
+		return nil, fmt.Errorf("empty response from classifier")
 	}
 
-	// Return the top label and its score (first element has highest score)
-	return apiResp[0].Label, apiResp[0].Score, nil
+	sortResults(apiResp)
+	return apiResp, nil
+}
+
+// sortResults orders results by score, highest first.
+func sortResults(results []ClassificationResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
 }
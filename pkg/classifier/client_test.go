@@ -0,0 +1,53 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientClassifyAll(t *testing.T) {
+	var got Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Write([]byte(`[{"label": "food", "score": 0.2}, {"label": "fashion", "score": 0.7}, {"label": "chat", "score": 0.1}]`))
+	}))
+	defer server.Close()
+
+	c := NewClient("key", 0)
+	c.url = server.URL
+	c.SetMultiLabel(true)
+
+	results, err := c.ClassifyAll(context.Background(), "nice dress", []string{"food", "fashion", "chat"})
+	if err != nil {
+		t.Fatalf("ClassifyAll failed: %v", err)
+	}
+	if !got.Parameters.MultiLabel || len(got.Parameters.CandidateLabels) != 3 {
+		t.Errorf("unexpected request parameters: %+v", got.Parameters)
+	}
+	if len(results) != 3 || results[0].Label != "fashion" || results[2].Label != "chat" {
+		t.Errorf("expected every label, highest score first, got %+v", results)
+	}
+
+	label, score, err := c.Classify(context.Background(), "nice dress", []string{"food", "fashion", "chat"})
+	if err != nil || label != "fashion" || score != 0.7 {
+		t.Errorf("expected Classify to return the top label, got %q (%v), %v", label, score, err)
+	}
+}
+
+func TestClientClassifyAll_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model loading", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	c := NewClient("key", 0)
+	c.url = server.URL
+	if _, err := c.ClassifyAll(context.Background(), "hi", []string{"a"}); err == nil {
+		t.Error("expected an error for a failed request")
+	}
+}
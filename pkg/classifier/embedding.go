@@ -0,0 +1,141 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// similarityScale sharpens the softmax over cosine similarities. Embedding
+// similarities of related texts differ by a few hundredths, so without it
+// every label would score about the same; with it the scores spread out
+// roughly like the zero-shot model's, so the same thresholds apply.
+const similarityScale = 30
+
+// Embedder turns text into a vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// batchEmbedder is implemented by embedders that embed several texts per
+// call, such as the caching embedding client.
+type batchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// EmbeddingClassifier scores labels by how similar their descriptions are to
+// the text in embedding space. It needs no classification model, only the
+// embedding API, so it can stand in for the zero-shot classifier when that is
+// down or replace it altogether.
+//
+// The text and the labels must be embedded by the same model, so the
+// embedder should not fall back to local embeddings on its own. Wrapping it
+// in a cache keeps label embeddings from being requested again and again.
+type EmbeddingClassifier struct {
+	embedder     Embedder
+	descriptions map[string]string // Label -> text embedded for it
+}
+
+func NewEmbeddingClassifier(embedder Embedder) *EmbeddingClassifier {
+	return &EmbeddingClassifier{embedder: embedder}
+}
+
+// SetDescriptions sets the text embedded for each label. Labels without a
+// description are embedded as they are, which works well for labels that
+// already describe what they match.
+func (c *EmbeddingClassifier) SetDescriptions(descriptions map[string]string) {
+	c.descriptions = descriptions
+}
+
+// Classify returns the label most similar to text, with its softmax score.
+func (c *EmbeddingClassifier) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := c.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+// ClassifyAll returns the softmax score of every label, highest first. The
+// scores always add up to 1; there is no multi-label mode.
+func (c *EmbeddingClassifier) ClassifyAll(ctx context.Context, text string, labels []string) ([]ClassificationResult, error) {
+	if len(labels) == 0 {
+		return nil, errors.New("no labels to classify with")
+	}
+
+	texts := make([]string, 0, len(labels)+1)
+	texts = append(texts, text)
+	for _, label := range labels {
+		if description, ok := c.descriptions[label]; ok {
+			texts = append(texts, description)
+		} else {
+			texts = append(texts, label)
+		}
+	}
+	vectors, err := c.embed(ctx, texts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed: %w", err)
+	}
+
+	scores := make([]float64, len(labels))
+	for i := range labels {
+		scores[i] = similarityScale * cosineSimilarity(vectors[0], vectors[i+1])
+	}
+	softmax(scores)
+
+	results := make([]ClassificationResult, len(labels))
+	for i, label := range labels {
+		results[i] = ClassificationResult{Label: label, Score: scores[i]}
+	}
+	sortResults(results)
+	return results, nil
+}
+
+func (c *EmbeddingClassifier) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if be, ok := c.embedder.(batchEmbedder); ok {
+		return be.EmbedBatch(ctx, texts)
+	}
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vector, err := c.embedder.Embed(ctx, text)
+		if err != nil {
+			return nil, err
+		}
+		vectors[i] = vector
+	}
+	return vectors, nil
+}
+
+// softmax turns scores into probabilities in place.
+func softmax(scores []float64) {
+	highest := math.Inf(-1)
+	for _, s := range scores {
+		highest = math.Max(highest, s)
+	}
+	var sum float64
+	for i, s := range scores {
+		// Subtracting the highest score keeps exp from overflowing
+		scores[i] = math.Exp(s - highest)
+		sum += scores[i]
+	}
+	for i := range scores {
+		scores[i] /= sum
+	}
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,80 @@
+package classifier
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// keywordEmbedder embeds texts by which of a few keywords they contain.
+type keywordEmbedder struct {
+	keywords []string
+	calls    int
+}
+
+func (e *keywordEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.calls++
+	vector := make([]float32, len(e.keywords)+1)
+	vector[len(e.keywords)] = 0.1 // Keeps texts without keywords from being zero vectors
+	for i, keyword := range e.keywords {
+		if strings.Contains(text, keyword) {
+			vector[i] = 1
+		}
+	}
+	return vector, nil
+}
+
+func TestEmbeddingClassifier(t *testing.T) {
+	embedder := &keywordEmbedder{keywords: []string{"food", "fashion", "sleep"}}
+	c := NewEmbeddingClassifier(embedder)
+	c.SetDescriptions(map[string]string{"tired": "not getting enough sleep"})
+	ctx := context.Background()
+	labels := []string{"talk about food", "talk about fashion", "tired"}
+
+	label, score, err := c.Classify(ctx, "what food should I cook tonight", labels)
+	if err != nil {
+		t.Fatalf("Classify failed: %v", err)
+	}
+	if label != "talk about food" || score < 0.9 {
+		t.Errorf("expected a confident food label, got %q (%.2f)", label, score)
+	}
+
+	results, err := c.ClassifyAll(ctx, "what food should I cook tonight", labels)
+	if err != nil || len(results) != 3 || results[0].Label != "talk about food" {
+		t.Fatalf("expected every label with food first, got %+v, %v", results, err)
+	}
+	if sum := results[0].Score + results[1].Score + results[2].Score; sum < 0.999 || sum > 1.001 {
+		t.Errorf("expected the scores to add up to 1, got %v", sum)
+	}
+
+	label, _, err = c.Classify(ctx, "I only got three hours of sleep", labels)
+	if err != nil || label != "tired" {
+		t.Errorf("expected the label matched through its description, got %q, %v", label, err)
+	}
+
+	if _, _, err := c.Classify(ctx, "hi", nil); err == nil {
+		t.Error("expected an error without labels")
+	}
+}
+
+type failingEmbedder struct{}
+
+func (failingEmbedder) Embed(context.Context, string) ([]float32, error) {
+	return nil, errors.New("api down")
+}
+
+func TestEmbeddingClassifier_EmbedError(t *testing.T) {
+	c := NewEmbeddingClassifier(failingEmbedder{})
+	if _, _, err := c.Classify(context.Background(), "hi", []string{"a", "b"}); err == nil {
+		t.Error("expected the embedding error to be returned")
+	}
+}
+
+func TestSoftmax(t *testing.T) {
+	scores := []float64{1000, 1000}
+	softmax(scores)
+	if scores[0] != 0.5 || scores[1] != 0.5 {
+		t.Errorf("expected equal scores to split evenly without overflowing, got %v", scores)
+	}
+}
@@ -0,0 +1,111 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"ninoai/pkg/cerebras"
+	"ninoai/pkg/usage"
+)
+
+// stubCompleter answers every JSON completion with a fixed reply.
+type stubCompleter struct {
+	reply    string
+	err      error
+	messages []cerebras.Message
+	schema   *cerebras.JSONSchema
+	kind     string
+}
+
+func (s *stubCompleter) ChatCompletionJSON(ctx context.Context, messages []cerebras.Message, schema *cerebras.JSONSchema, out interface{}) error {
+	s.messages, s.schema = messages, schema
+	s.kind = usage.AttributionFrom(ctx).Kind
+	if s.err != nil {
+		return s.err
+	}
+	if err := json.Unmarshal([]byte(s.reply), out); err != nil {
+		return err
+	}
+	if v, ok := out.(cerebras.Validator); ok {
+		return v.Validate()
+	}
+	return nil
+}
+
+func TestLLMClassifier(t *testing.T) {
+	labels := []string{"food", "fashion", "chat"}
+	ctx := context.Background()
+
+	t.Run("Single label normalises confidences", func(t *testing.T) {
+		client := &stubCompleter{reply: `{"scores": [{"label": "food", "confidence": 0.8}, {"label": "fashion", "confidence": 0.4}, {"label": "chat", "confidence": 0.4}]}`}
+		label, score, err := NewLLMClassifier(client).Classify(ctx, "what's for dinner", labels)
+		if err != nil {
+			t.Fatalf("Classify failed: %v", err)
+		}
+		if label != "food" || math.Abs(score-0.5) > 1e-9 {
+			t.Errorf("expected food with 0.5, got %q with %v", label, score)
+		}
+		if !strings.Contains(client.messages[1].Content, "- fashion") || !strings.Contains(client.messages[1].Content, "what's for dinner") {
+			t.Errorf("expected the labels and message in the prompt, got %q", client.messages[1].Content)
+		}
+		if !strings.Contains(string(client.schema.Schema), `"enum":["food","fashion","chat"]`) {
+			t.Errorf("expected the schema to constrain labels, got %s", client.schema.Schema)
+		}
+		if client.kind != usage.KindClassification {
+			t.Errorf("expected the call to be attributed to classification, got %q", client.kind)
+		}
+	})
+
+	t.Run("Multi label keeps raw confidences", func(t *testing.T) {
+		client := &stubCompleter{reply: `{"scores": [{"label": "food", "confidence": 0.9}, {"label": "fashion", "confidence": 0.8}]}`}
+		c := NewLLMClassifier(client)
+		c.SetMultiLabel(true)
+		results, err := c.ClassifyAll(ctx, "cooking in a nice apron", labels)
+		if err != nil {
+			t.Fatalf("ClassifyAll failed: %v", err)
+		}
+		want := []ClassificationResult{{"food", 0.9}, {"fashion", 0.8}, {"chat", 0}}
+		for i := range want {
+			if results[i] != want[i] {
+				t.Errorf("expected %+v, got %+v", want, results)
+				break
+			}
+		}
+		if !strings.Contains(client.messages[0].Content, multiLabelInstruction) {
+			t.Error("expected the multi-label instruction in the prompt")
+		}
+	})
+
+	t.Run("Unknown labels are ignored", func(t *testing.T) {
+		client := &stubCompleter{reply: `{"scores": [{"label": "sports", "confidence": 1}, {"label": "chat", "confidence": 0.3}]}`}
+		label, score, err := NewLLMClassifier(client).Classify(ctx, "hi", labels)
+		if err != nil || label != "chat" || score != 1 {
+			t.Errorf("expected chat with 1, got %q with %v, %v", label, score, err)
+		}
+	})
+
+	t.Run("No known labels", func(t *testing.T) {
+		client := &stubCompleter{reply: `{"scores": [{"label": "sports", "confidence": 1}]}`}
+		if _, _, err := NewLLMClassifier(client).Classify(ctx, "hi", labels); err == nil {
+			t.Error("expected an error when no candidate label is scored")
+		}
+	})
+
+	t.Run("Confidence out of range", func(t *testing.T) {
+		client := &stubCompleter{reply: `{"scores": [{"label": "food", "confidence": 7}]}`}
+		if _, _, err := NewLLMClassifier(client).Classify(ctx, "hi", labels); err == nil {
+			t.Error("expected an error for a confidence above 1")
+		}
+	})
+
+	t.Run("LLM error", func(t *testing.T) {
+		client := &stubCompleter{err: errors.New("all models failed")}
+		if _, _, err := NewLLMClassifier(client).Classify(ctx, "hi", labels); err == nil {
+			t.Error("expected the LLM error to be returned")
+		}
+	})
+}
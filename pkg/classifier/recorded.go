@@ -0,0 +1,116 @@
+package classifier
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Recording is one classification kept by a Recorder, stored as a line of
+// JSON.
+type Recording struct {
+	Text    string                 `json:"text"`
+	Labels  []string               `json:"labels"`
+	Results []ClassificationResult `json:"results"`
+}
+
+// Recorder passes classifications through to another classifier and appends
+// each answer to a file, so that they can be replayed offline later.
+type Recorder struct {
+	classifier Classifier
+	mu         sync.Mutex
+	file       *os.File
+	encoder    *json.Encoder
+}
+
+// NewRecorder records the answers of c to path, appending to what is already
+// there.
+func NewRecorder(c Classifier, path string) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	return &Recorder{classifier: c, file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+func (r *Recorder) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := r.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (r *Recorder) ClassifyAll(ctx context.Context, text string, labels []string) ([]ClassificationResult, error) {
+	results, err := r.classifier.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.encoder.Encode(Recording{Text: text, Labels: labels, Results: results}); err != nil {
+		return nil, fmt.Errorf("failed to record classification: %w", err)
+	}
+	return results, nil
+}
+
+func (r *Recorder) Close() error {
+	return r.file.Close()
+}
+
+// Replay answers with classifications recorded earlier, without calling any
+// model. Asking for a text and labels that were never recorded is an error.
+type Replay struct {
+	recordings map[string][]ClassificationResult
+}
+
+// LoadReplay reads the recordings in path. When a text and labels were
+// recorded more than once, the last answer wins.
+func LoadReplay(path string) (*Replay, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recording: %w", err)
+	}
+	defer file.Close()
+
+	r := &Replay{recordings: make(map[string][]ClassificationResult)}
+	decoder := json.NewDecoder(file)
+	for {
+		var rec Recording
+		if err := decoder.Decode(&rec); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read recording: %w", err)
+		}
+		r.recordings[recordingKey(rec.Text, rec.Labels)] = rec.Results
+	}
+	return r, nil
+}
+
+func (r *Replay) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := r.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (r *Replay) ClassifyAll(_ context.Context, text string, labels []string) ([]ClassificationResult, error) {
+	results, ok := r.recordings[recordingKey(text, labels)]
+	if !ok || len(results) == 0 {
+		return nil, fmt.Errorf("no recorded classification of %q with these labels", text)
+	}
+	// Callers may reorder the results
+	return append([]ClassificationResult(nil), results...), nil
+}
+
+// recordingKey identifies a classification by its text and labels, in order.
+func recordingKey(text string, labels []string) string {
+	return text + "\x00" + strings.Join(labels, "\x00")
+}
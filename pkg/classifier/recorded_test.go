@@ -0,0 +1,44 @@
+package classifier
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recording.jsonl")
+	ctx := context.Background()
+	labels := []string{"food", "chat"}
+
+	recorder, err := NewRecorder(&stubClassifier{label: "food"}, path)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+	if _, err := recorder.ClassifyAll(ctx, "what's for dinner", labels); err != nil {
+		t.Fatalf("ClassifyAll() error = %v", err)
+	}
+	// A failed call is passed on and not recorded
+	recorder.classifier = &stubClassifier{err: context.DeadlineExceeded}
+	if _, err := recorder.ClassifyAll(ctx, "hello", labels); err == nil {
+		t.Error("expected the classifier's error")
+	}
+	if err := recorder.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	replay, err := LoadReplay(path)
+	if err != nil {
+		t.Fatalf("LoadReplay() error = %v", err)
+	}
+	label, score, err := replay.Classify(ctx, "what's for dinner", labels)
+	if err != nil || label != "food" || score != 0.9 {
+		t.Errorf("Classify() = %q, %v, %v; want the recorded answer", label, score, err)
+	}
+	if _, err := replay.ClassifyAll(ctx, "hello", labels); err == nil {
+		t.Error("expected an error for a text that was never recorded")
+	}
+	if _, err := replay.ClassifyAll(ctx, "what's for dinner", []string{"chat", "food"}); err == nil {
+		t.Error("expected an error for labels that were never recorded")
+	}
+}
@@ -1,18 +1,139 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"time"
+
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	ModelSettings   struct {
+	ModelSettings struct {
 		Temperature float64 `yaml:"temperature"`
 		TopP        float64 `yaml:"top_p"`
 	} `yaml:"model_settings"`
 	Delays struct {
 		MessageProcessing float64 `yaml:"message_processing"`
 	} `yaml:"delays"`
+	Streaming struct {
+		Enabled      bool    `yaml:"enabled"`
+		EditInterval float64 `yaml:"edit_interval"` // Seconds between message edits
+	} `yaml:"streaming"`
+	// Timeouts bound every outbound call, in seconds; 0 means no timeout. The
+	// LLM timeout applies to each model attempt, so a hanging model is
+	// abandoned for the next one. Unset timeouts get a default.
+	Timeouts struct {
+		LLM        *float64 `yaml:"llm"`
+		Embedding  *float64 `yaml:"embedding"`
+		Classifier *float64 `yaml:"classifier"`
+		Database   *float64 `yaml:"database"`
+	} `yaml:"timeouts"`
+	// Budgets cap the LLM tokens spent per UTC day and hour. 0 means unlimited.
+	Budgets struct {
+		Global BudgetLimits `yaml:"global"`
+		Guild  BudgetLimits `yaml:"guild"` // Applies to each guild separately
+		User   BudgetLimits `yaml:"user"`  // Applies to each user separately
+	} `yaml:"budgets"`
+	// Embedding configures batching, caching and what happens when the
+	// embedding API is down
+	Embedding struct {
+		// Model names the API's embedding model in cache keys; defaults to the API URL
+		Model string `yaml:"model"`
+		// Dimensions is the length of the model's vectors; 0 detects it at startup
+		Dimensions    int     `yaml:"dimensions"`
+		BatchSize     int     `yaml:"batch_size"`     // Texts per API request
+		CacheSize     int     `yaml:"cache_size"`     // Embeddings kept in memory
+		CacheDir      string  `yaml:"cache_dir"`      // Also keeps embeddings on disk when set
+		CacheMaxAge   float64 `yaml:"cache_max_age"`  // Days an embedding is kept on disk after it was last used
+		LocalFallback bool    `yaml:"local_fallback"` // Embed queries locally instead of skipping retrieval
+		RetryInterval float64 `yaml:"retry_interval"` // Seconds between attempts to store queued memories
+	} `yaml:"embedding"`
+	// Retrieval ranks the memories put in the prompt by a weighted average of
+	// their similarity to the message, how well its words match, how recent
+	// and how important they are
+	Retrieval struct {
+		Weights RetrievalWeights `yaml:"weights"`
+		// HalfLife is the number of days after which recency counts half
+		HalfLife float64 `yaml:"half_life"`
+		// MinSimilarity leaves out less similar memories that share no words
+		// with the message
+		MinSimilarity *float64 `yaml:"min_similarity"`
+	} `yaml:"retrieval"`
+	// Tools let the model call built-in functions while replying
+	Tools struct {
+		Enabled       bool `yaml:"enabled"`
+		MaxIterations int  `yaml:"max_iterations"` // Rounds of tool calls per reply; 0 keeps the default
+	} `yaml:"tools"`
+	// Classifier decides whether to answer messages that don't mention Nino and
+	// whether a message asks for a long task
+	Classifier struct {
+		// Chain lists the classifiers to try in order; later ones take over
+		// while earlier ones fail: hf, embedding or llm
+		Chain []string `yaml:"chain"`
+		// MultiLabel scores each label on its own instead of splitting one
+		// unit of probability between them (hf and llm)
+		MultiLabel bool `yaml:"multi_label"`
+	} `yaml:"classifier"`
+	// Reply decides whether Nino answers guild messages that don't mention
+	// her. Guild and channel overrides only need the fields they change.
+	Reply struct {
+		ReplyPolicy `yaml:",inline"`
+		Guilds      map[string]ReplyPolicy `yaml:"guilds"`   // Guild ID -> override
+		Channels    map[string]ReplyPolicy `yaml:"channels"` // Channel ID -> override; wins over its guild
+	} `yaml:"reply"`
+	// Providers make up the LLM fallback chain, tried in order. When the
+	// section is absent, the default Cerebras models are used.
+	Providers []ProviderConfig `yaml:"providers"`
+}
+
+// Classifier types: hf is the zero-shot model on HuggingFace, which needs
+// HF_API_KEY, embedding compares the message with each label using the
+// embedding API, and llm asks the LLM providers.
+const (
+	ClassifierHF        = "hf"
+	ClassifierEmbedding = "embedding"
+	ClassifierLLM       = "llm"
+)
+
+// Reply modes
+const (
+	ReplyModeClassify     = "classify"      // Reply when a trigger fires
+	ReplyModeMentionsOnly = "mentions_only" // Never reply unless mentioned
+)
+
+// ReplyPolicy says when to answer untagged messages. Unset fields are
+// inherited from the policy it overrides, and in the end from the built-in
+// defaults.
+type ReplyPolicy struct {
+	Mode        string         `yaml:"mode"`        // classify or mentions_only
+	Probability *float64       `yaml:"probability"` // Chance of replying once a trigger fires
+	Triggers    []ReplyTrigger `yaml:"triggers"`    // Replace the inherited triggers as a whole
+}
+
+// ReplyTrigger is a label messages are classified with, and the score at
+// which it makes Nino reply; 0 never does.
+type ReplyTrigger struct {
+	Label     string  `yaml:"label"`
+	Threshold float64 `yaml:"threshold"`
+}
+
+// RetrievalWeights weigh the signals memories are ranked by. Only their
+// ratios matter.
+type RetrievalWeights struct {
+	Similarity float64 `yaml:"similarity"`
+	Keyword    float64 `yaml:"keyword"`
+	Recency    float64 `yaml:"recency"`
+	Importance float64 `yaml:"importance"`
+}
+
+// BudgetLimits are token limits per window
+type BudgetLimits struct {
+	Daily  int `yaml:"daily"`
+	Hourly int `yaml:"hourly"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -24,6 +145,12 @@ func LoadConfig(path string) (*Config, error) {
 		config.ModelSettings.Temperature = 1
 		config.ModelSettings.TopP = 1
 		config.Delays.MessageProcessing = 0.5
+		config.Streaming.EditInterval = 1
+		config.applyTimeoutDefaults()
+		config.applyEmbeddingDefaults()
+		config.applyRetrievalDefaults()
+		config.applyClassifierDefaults()
+		config.applyProviderDefaults()
 		return config, nil
 	}
 
@@ -32,10 +159,186 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
-	err = yaml.Unmarshal(file, config)
-	if err != nil {
-		return nil, err
+	// Reject unknown fields so that typos don't silently fall back to defaults
+	decoder := yaml.NewDecoder(bytes.NewReader(file))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+
+	config.applyTimeoutDefaults()
+	config.applyEmbeddingDefaults()
+	config.applyRetrievalDefaults()
+	config.applyClassifierDefaults()
+	config.applyProviderDefaults()
+	if err := errors.Join(config.validateTimeouts(), config.validateEmbedding(), config.validateBudgets(), config.validateRetrieval(), config.validateTools(), config.validateClassifier(), config.validateReply(), config.validateProviders()); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
 	}
 
 	return config, nil
 }
+
+// applyTimeoutDefaults fills in timeouts that are not set.
+func (c *Config) applyTimeoutDefaults() {
+	t := &c.Timeouts
+	setDefault(&t.LLM, 60)
+	setDefault(&t.Embedding, 15)
+	setDefault(&t.Classifier, 15)
+	setDefault(&t.Database, 10)
+}
+
+// setDefault sets *v to def if it is not set.
+func setDefault(v **float64, def float64) {
+	if *v == nil {
+		*v = &def
+	}
+}
+
+func (c *Config) validateTimeouts() error {
+	t := c.Timeouts
+	for _, timeout := range []*float64{t.LLM, t.Embedding, t.Classifier, t.Database} {
+		if timeout != nil && *timeout < 0 {
+			return errors.New("timeouts: must not be negative")
+		}
+	}
+	return nil
+}
+
+// applyEmbeddingDefaults fills in embedding settings that are not set.
+func (c *Config) applyEmbeddingDefaults() {
+	e := &c.Embedding
+	if e.BatchSize == 0 {
+		e.BatchSize = 32
+	}
+	if e.CacheSize == 0 {
+		e.CacheSize = 1000
+	}
+	if e.CacheMaxAge == 0 {
+		e.CacheMaxAge = 30
+	}
+	if e.RetryInterval == 0 {
+		e.RetryInterval = 60
+	}
+}
+
+func (c *Config) validateEmbedding() error {
+	e := c.Embedding
+	if e.BatchSize < 0 || e.CacheSize < 0 || e.CacheMaxAge < 0 || e.RetryInterval < 0 {
+		return errors.New("embedding: batch_size, cache_size, cache_max_age and retry_interval must be positive")
+	}
+	if e.Dimensions < 0 {
+		return errors.New("embedding: dimensions must be positive, or 0 to detect them")
+	}
+	return nil
+}
+
+// applyRetrievalDefaults mostly ranks by similarity unless weights are set.
+func (c *Config) applyRetrievalDefaults() {
+	r := &c.Retrieval
+	if r.Weights == (RetrievalWeights{}) {
+		r.Weights = RetrievalWeights{Similarity: 0.6, Keyword: 0.2, Recency: 0.1, Importance: 0.1}
+	}
+	if r.HalfLife == 0 {
+		r.HalfLife = 30
+	}
+	if r.MinSimilarity == nil {
+		minSimilarity := 0.6
+		r.MinSimilarity = &minSimilarity
+	}
+}
+
+func (c *Config) validateRetrieval() error {
+	r := c.Retrieval
+	var errs []error
+	w := r.Weights
+	if w.Similarity < 0 || w.Keyword < 0 || w.Recency < 0 || w.Importance < 0 {
+		errs = append(errs, errors.New("retrieval: weights must not be negative"))
+	}
+	if r.HalfLife < 0 {
+		errs = append(errs, errors.New("retrieval: half_life must be positive"))
+	}
+	if r.MinSimilarity != nil && (*r.MinSimilarity < 0 || *r.MinSimilarity > 1) {
+		errs = append(errs, errors.New("retrieval: min_similarity must be between 0 and 1"))
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateBudgets() error {
+	b := c.Budgets
+	for _, limits := range []BudgetLimits{b.Global, b.Guild, b.User} {
+		if limits.Daily < 0 || limits.Hourly < 0 {
+			return errors.New("budgets: limits must not be negative")
+		}
+	}
+	return nil
+}
+
+func (c *Config) validateTools() error {
+	if c.Tools.MaxIterations < 0 {
+		return errors.New("tools: max_iterations must not be negative")
+	}
+	return nil
+}
+
+// applyClassifierDefaults falls back to the embedding classifier when the
+// zero-shot model fails, unless a chain is configured.
+func (c *Config) applyClassifierDefaults() {
+	if len(c.Classifier.Chain) == 0 {
+		c.Classifier.Chain = []string{ClassifierHF, ClassifierEmbedding}
+	}
+}
+
+func (c *Config) validateClassifier() error {
+	var errs []error
+	seen := make(map[string]bool)
+	for _, name := range c.Classifier.Chain {
+		switch {
+		case name != ClassifierHF && name != ClassifierEmbedding && name != ClassifierLLM:
+			errs = append(errs, fmt.Errorf("classifier: unknown classifier %q (want hf, embedding or llm)", name))
+		case seen[name]:
+			errs = append(errs, fmt.Errorf("classifier: %q is listed twice", name))
+		}
+		seen[name] = true
+	}
+	return errors.Join(errs...)
+}
+
+func (c *Config) validateReply() error {
+	errs := []error{c.Reply.ReplyPolicy.validate("reply")}
+	for id, p := range c.Reply.Guilds {
+		errs = append(errs, p.validate("reply.guilds."+id))
+	}
+	for id, p := range c.Reply.Channels {
+		errs = append(errs, p.validate("reply.channels."+id))
+	}
+	return errors.Join(errs...)
+}
+
+func (p ReplyPolicy) validate(field string) error {
+	var errs []error
+	if p.Mode != "" && p.Mode != ReplyModeClassify && p.Mode != ReplyModeMentionsOnly {
+		errs = append(errs, fmt.Errorf("%s: unknown mode %q (want classify or mentions_only)", field, p.Mode))
+	}
+	if p.Probability != nil && (*p.Probability < 0 || *p.Probability > 1) {
+		errs = append(errs, fmt.Errorf("%s: probability must be between 0 and 1", field))
+	}
+	labels := make(map[string]bool)
+	for i, t := range p.Triggers {
+		switch {
+		case t.Label == "":
+			errs = append(errs, fmt.Errorf("%s.triggers[%d]: label is required", field, i))
+		case labels[t.Label]:
+			errs = append(errs, fmt.Errorf("%s.triggers[%d]: duplicate label %q", field, i, t.Label))
+		}
+		labels[t.Label] = true
+		if t.Threshold < 0 || t.Threshold > 1 {
+			errs = append(errs, fmt.Errorf("%s.triggers[%d]: threshold must be between 0 and 1", field, i))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Seconds converts a duration given in seconds in the config to a time.Duration.
+func Seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
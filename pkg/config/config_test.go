@@ -0,0 +1,257 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadConfig_DefaultProviders(t *testing.T) {
+	for name, path := range map[string]string{
+		"Missing file":    filepath.Join(t.TempDir(), "missing.yml"),
+		"Missing section": writeConfig(t, "model_settings:\n  temperature: 0.7\n"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := LoadConfig(path)
+			if err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			if len(cfg.Providers) != 1 || cfg.Providers[0].Type != ProviderCerebras {
+				t.Fatalf("expected the default Cerebras provider, got %+v", cfg.Providers)
+			}
+			p := cfg.Providers[0]
+			if p.BaseURL != "https://api.cerebras.ai/v1" || p.APIKeyEnv != "CEREBRAS_API_KEY" {
+				t.Errorf("unexpected default provider: %+v", p)
+			}
+			if len(p.Models) != 6 || p.Models[0].MaxTokens != DefaultMaxTokens || !p.Models[0].IsEnabled() {
+				t.Errorf("unexpected default models: %+v", p.Models)
+			}
+			if *cfg.Timeouts.LLM != 60 || *cfg.Timeouts.Database != 10 {
+				t.Errorf("unexpected default timeouts: %+v", cfg.Timeouts)
+			}
+			if e := cfg.Embedding; e.BatchSize != 32 || e.CacheSize != 1000 || e.CacheMaxAge != 30 || e.RetryInterval != 60 {
+				t.Errorf("unexpected default embedding settings: %+v", e)
+			}
+			if c := cfg.Classifier.Chain; len(c) != 2 || c[0] != ClassifierHF || c[1] != ClassifierEmbedding {
+				t.Errorf("unexpected default classifier chain: %v", c)
+			}
+		})
+	}
+}
+
+func TestLoadConfig_ZeroTimeoutDisables(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, "timeouts:\n  llm: 0\n  database: 5\n"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if *cfg.Timeouts.LLM != 0 || *cfg.Timeouts.Database != 5 || *cfg.Timeouts.Embedding != 15 {
+		t.Errorf("expected 0 to be kept and unset timeouts defaulted, got llm=%v database=%v embedding=%v",
+			*cfg.Timeouts.LLM, *cfg.Timeouts.Database, *cfg.Timeouts.Embedding)
+	}
+}
+
+func TestLoadConfig_Models(t *testing.T) {
+	path := writeConfig(t, `
+providers:
+  - name: local
+    type: ollama
+    max_tokens: 512
+    models:
+      - id: llama3.1:8b
+        max_ctx: 8192
+      - id: qwen3:4b
+        max_ctx: 32768
+        max_tokens: 1024
+        temperature: 0.4
+        enabled: false
+`)
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	p := cfg.Providers[0]
+	if p.BaseURL != "http://localhost:11434" {
+		t.Errorf("expected default Ollama base URL, got %q", p.BaseURL)
+	}
+	if p.Models[0].MaxTokens != 512 {
+		t.Errorf("expected provider max_tokens to be inherited, got %d", p.Models[0].MaxTokens)
+	}
+	second := p.Models[1]
+	if second.MaxTokens != 1024 || second.Temperature == nil || *second.Temperature != 0.4 || second.IsEnabled() {
+		t.Errorf("unexpected overrides: %+v", second)
+	}
+}
+
+func TestLoadConfig_Reply(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+reply:
+  probability: 0.5
+  triggers:
+    - label: cats
+      threshold: 0.6
+  guilds:
+    "123":
+      mode: mentions_only
+  channels:
+    "456":
+      mode: classify
+      probability: 1
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	if p := cfg.Reply.Probability; p == nil || *p != 0.5 {
+		t.Errorf("unexpected default probability: %v", p)
+	}
+	if tr := cfg.Reply.Triggers; len(tr) != 1 || tr[0].Label != "cats" || tr[0].Threshold != 0.6 {
+		t.Errorf("unexpected triggers: %+v", tr)
+	}
+	if g := cfg.Reply.Guilds["123"]; g.Mode != ReplyModeMentionsOnly || g.Probability != nil {
+		t.Errorf("unexpected guild override: %+v", g)
+	}
+	if c := cfg.Reply.Channels["456"]; c.Mode != ReplyModeClassify || c.Probability == nil || *c.Probability != 1 {
+		t.Errorf("unexpected channel override: %+v", c)
+	}
+}
+
+func TestLoadConfig_Retrieval(t *testing.T) {
+	cfg, err := LoadConfig(writeConfig(t, `
+retrieval:
+  weights:
+    similarity: 1
+    keyword: 1
+  min_similarity: 0
+`))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	r := cfg.Retrieval
+	if r.Weights != (RetrievalWeights{Similarity: 1, Keyword: 1}) {
+		t.Errorf("expected the configured weights only, got %+v", r.Weights)
+	}
+	if r.HalfLife != 30 || r.MinSimilarity == nil || *r.MinSimilarity != 0 {
+		t.Errorf("expected the default half-life and an explicit 0 min_similarity, got %v and %v", r.HalfLife, r.MinSimilarity)
+	}
+}
+
+func TestLoadConfig_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "Unknown field",
+			content: "providers:\n  - name: c\n    type: cerebras\n    models:\n      - id: m\n        max_ctx: 8192\n        max_tokenz: 10\n",
+			wantErr: "max_tokenz",
+		},
+		{
+			name:    "Unknown type",
+			content: "providers:\n  - name: c\n    type: magic\n    base_url: http://x\n    models:\n      - id: m\n        max_ctx: 8192\n",
+			wantErr: "unknown type",
+		},
+		{
+			name:    "OpenAI without base URL",
+			content: "providers:\n  - name: o\n    type: openai\n    models:\n      - id: m\n        max_ctx: 8192\n",
+			wantErr: "base_url is required",
+		},
+		{
+			name:    "Budget larger than context",
+			content: "providers:\n  - name: c\n    type: cerebras\n    models:\n      - id: m\n        max_ctx: 1000\n",
+			wantErr: "must be smaller than max_ctx",
+		},
+		{
+			name:    "Negative timeout",
+			content: "timeouts:\n  embedding: -1\n",
+			wantErr: "timeouts",
+		},
+		{
+			name:    "Negative batch size",
+			content: "embedding:\n  batch_size: -1\n",
+			wantErr: "batch_size",
+		},
+		{
+			name:    "Negative embedding dimensions",
+			content: "embedding:\n  dimensions: -1\n",
+			wantErr: "dimensions",
+		},
+		{
+			name:    "Unknown classifier",
+			content: "classifier:\n  chain: [hf, magic]\n",
+			wantErr: "unknown classifier",
+		},
+		{
+			name:    "Duplicate classifier",
+			content: "classifier:\n  chain: [embedding, embedding]\n",
+			wantErr: "listed twice",
+		},
+		{
+			name:    "Unknown reply mode",
+			content: "reply:\n  mode: sometimes\n",
+			wantErr: "unknown mode",
+		},
+		{
+			name:    "Reply probability out of range",
+			content: "reply:\n  guilds:\n    \"123\":\n      probability: 1.5\n",
+			wantErr: "reply.guilds.123: probability",
+		},
+		{
+			name:    "Reply trigger without label",
+			content: "reply:\n  channels:\n    \"456\":\n      triggers:\n        - threshold: 0.5\n",
+			wantErr: "label is required",
+		},
+		{
+			name:    "Reply threshold out of range",
+			content: "reply:\n  triggers:\n    - label: cats\n      threshold: 2\n",
+			wantErr: "threshold",
+		},
+		{
+			name:    "Negative retrieval weight",
+			content: "retrieval:\n  weights:\n    keyword: -0.1\n",
+			wantErr: "weights",
+		},
+		{
+			name:    "Retrieval min similarity out of range",
+			content: "retrieval:\n  min_similarity: 1.5\n",
+			wantErr: "min_similarity",
+		},
+		{
+			name:    "Negative budget",
+			content: "budgets:\n  user:\n    daily: -5\n",
+			wantErr: "budgets",
+		},
+		{
+			name:    "Negative tool iterations",
+			content: "tools:\n  max_iterations: -1\n",
+			wantErr: "max_iterations",
+		},
+		{
+			name:    "Everything disabled",
+			content: "providers:\n  - name: c\n    type: cerebras\n    models:\n      - id: m\n        max_ctx: 8192\n        enabled: false\n",
+			wantErr: "at least one model",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadConfig(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("LoadConfig() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DefaultMaxTokens is the completion budget for models that don't set one
+const DefaultMaxTokens = 2000
+
+// Provider types
+const (
+	ProviderCerebras = "cerebras"
+	ProviderOpenAI   = "openai"
+	ProviderOllama   = "ollama"
+)
+
+// defaultBaseURLs are used when a provider of that type has no base_url.
+// OpenAI-compatible providers have no default and must set one.
+var defaultBaseURLs = map[string]string{
+	ProviderCerebras: "https://api.cerebras.ai/v1",
+	ProviderOllama:   "http://localhost:11434",
+}
+
+// ProviderConfig describes one LLM backend of the fallback chain.
+type ProviderConfig struct {
+	Name      string        `yaml:"name"`
+	Type      string        `yaml:"type"`        // cerebras, openai or ollama
+	BaseURL   string        `yaml:"base_url"`    // Defaults per type; required for openai
+	APIKeyEnv string        `yaml:"api_key_env"` // Environment variable holding the API key
+	MaxTokens int           `yaml:"max_tokens"`  // Default completion budget for this provider's models
+	Models    []ModelConfig `yaml:"models"`      // Tried in order
+}
+
+// ModelConfig is one entry of a provider's ordered model list.
+type ModelConfig struct {
+	ID          string   `yaml:"id"`
+	MaxCtx      int      `yaml:"max_ctx"`
+	MaxTokens   int      `yaml:"max_tokens"`  // Overrides the provider's max_tokens
+	Temperature *float64 `yaml:"temperature"` // Overrides model_settings.temperature
+	Enabled     *bool    `yaml:"enabled"`     // Defaults to true
+}
+
+// IsEnabled reports whether the model takes part in the fallback chain.
+func (m ModelConfig) IsEnabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+// DefaultProviders returns the fallback chain used when config.yml has no
+// providers section: the Cerebras API with its prioritized models.
+func DefaultProviders() []ProviderConfig {
+	return []ProviderConfig{
+		{
+			Name:      "cerebras",
+			Type:      ProviderCerebras,
+			APIKeyEnv: "CEREBRAS_API_KEY",
+			Models: []ModelConfig{
+				{ID: "qwen-3-235b-a22b-instruct-2507", MaxCtx: 65536},
+				{ID: "llama-3.3-70b", MaxCtx: 65536},
+				{ID: "llama3.1-8b", MaxCtx: 8192},
+				{ID: "qwen-3-32b", MaxCtx: 65536},
+				{ID: "zai-glm-4.6", MaxCtx: 64000},
+				{ID: "gpt-oss-120b", MaxCtx: 65536},
+			},
+		},
+	}
+}
+
+// applyProviderDefaults fills in the default chain, base URLs and completion budgets.
+func (c *Config) applyProviderDefaults() {
+	if len(c.Providers) == 0 {
+		c.Providers = DefaultProviders()
+	}
+
+	for i := range c.Providers {
+		p := &c.Providers[i]
+		if p.Name == "" {
+			p.Name = p.Type
+		}
+		if p.BaseURL == "" {
+			p.BaseURL = defaultBaseURLs[p.Type]
+		}
+		if p.MaxTokens == 0 {
+			p.MaxTokens = DefaultMaxTokens
+		}
+		for j := range p.Models {
+			if p.Models[j].MaxTokens == 0 {
+				p.Models[j].MaxTokens = p.MaxTokens
+			}
+		}
+	}
+}
+
+// validateProviders checks the fallback chain once defaults have been applied.
+func (c *Config) validateProviders() error {
+	var errs []error
+	names := make(map[string]bool)
+	enabled := 0
+
+	for _, p := range c.Providers {
+		if names[p.Name] {
+			errs = append(errs, fmt.Errorf("providers: duplicate provider name %q", p.Name))
+		}
+		names[p.Name] = true
+
+		if _, ok := defaultBaseURLs[p.Type]; !ok && p.Type != ProviderOpenAI {
+			errs = append(errs, fmt.Errorf("providers.%s: unknown type %q (want cerebras, openai or ollama)", p.Name, p.Type))
+		}
+		if p.BaseURL == "" {
+			errs = append(errs, fmt.Errorf("providers.%s: base_url is required", p.Name))
+		}
+		if len(p.Models) == 0 {
+			errs = append(errs, fmt.Errorf("providers.%s: no models configured", p.Name))
+		}
+
+		ids := make(map[string]bool)
+		for i, m := range p.Models {
+			field := fmt.Sprintf("providers.%s.models[%d]", p.Name, i)
+			if m.ID == "" {
+				errs = append(errs, fmt.Errorf("%s: id is required", field))
+			} else if ids[m.ID] {
+				errs = append(errs, fmt.Errorf("%s: duplicate model %q", field, m.ID))
+			}
+			ids[m.ID] = true
+
+			if m.MaxCtx <= 0 {
+				errs = append(errs, fmt.Errorf("%s: max_ctx must be positive", field))
+			} else if m.MaxTokens >= m.MaxCtx {
+				errs = append(errs, fmt.Errorf("%s: max_tokens (%d) must be smaller than max_ctx (%d)", field, m.MaxTokens, m.MaxCtx))
+			}
+			if m.MaxTokens < 0 {
+				errs = append(errs, fmt.Errorf("%s: max_tokens must be positive", field))
+			}
+			if m.Temperature != nil && (*m.Temperature < 0 || *m.Temperature > 2) {
+				errs = append(errs, fmt.Errorf("%s: temperature must be between 0 and 2", field))
+			}
+			if m.IsEnabled() {
+				enabled++
+			}
+		}
+	}
+
+	if enabled == 0 {
+		errs = append(errs, errors.New("providers: at least one model must be enabled"))
+	}
+
+	return errors.Join(errs...)
+}
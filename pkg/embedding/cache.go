@@ -0,0 +1,285 @@
+package embedding
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// BatchEmbedder embeds several texts per call.
+type BatchEmbedder interface {
+	Embedder
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Cache keeps recently used embeddings in an LRU and, optionally, on disk
+// for up to maxAge since they were last used. Keys combine the model name and
+// its dimensions with a hash of the text, and vectors of another length are
+// never served, so a model change that alters the dimensions can't leak stale
+// vectors even if the model name stays the same.
+type Cache struct {
+	model      string
+	dimensions int
+	size       int
+	dir        string        // One file per embedding; empty keeps the cache in memory only
+	maxAge     time.Duration // How long unused files are kept; 0 keeps them forever
+	mu         sync.Mutex
+	entries    map[string]*list.Element
+	lru        *list.List // Front is the most recently used
+}
+
+type cacheEntry struct {
+	key    string
+	vector []float32
+}
+
+// NewCache creates a cache of up to size embeddings of the given dimensions
+// in memory, backed by dir if it is not empty.
+func NewCache(model string, dimensions, size int, dir string) *Cache {
+	return &Cache{
+		model:      model,
+		dimensions: dimensions,
+		size:       size,
+		dir:        dir,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// SetMaxAge drops embeddings from disk that were not used for maxAge. Call
+// Prune to remove the ones that expired while the bot was not running.
+func (c *Cache) SetMaxAge(maxAge time.Duration) {
+	c.maxAge = maxAge
+}
+
+func (c *Cache) key(text string) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s", c.model, c.dimensions, text)))
+	return hex.EncodeToString(sum[:])
+}
+
+// fits reports whether vector has the cache's dimensions.
+func (c *Cache) fits(vector []float32) bool {
+	return c.dimensions == 0 || len(vector) == c.dimensions
+}
+
+// Get returns a copy of the cached embedding of text.
+func (c *Cache) Get(text string) ([]float32, bool) {
+	key := c.key(text)
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.lru.MoveToFront(elem)
+		vector := elem.Value.(*cacheEntry).vector
+		c.mu.Unlock()
+		return append([]float32(nil), vector...), true
+	}
+	c.mu.Unlock()
+
+	if c.dir == "" {
+		return nil, false
+	}
+	vector, err := c.readFile(key)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error reading cached embedding: %v", err)
+		}
+		return nil, false
+	}
+	if !c.fits(vector) {
+		log.Printf("Dropping cached embedding of %d dimensions, want %d", len(vector), c.dimensions)
+		os.Remove(c.path(key))
+		return nil, false
+	}
+	// Keep it on disk for another maxAge
+	now := time.Now()
+	if err := os.Chtimes(c.path(key), now, now); err != nil {
+		log.Printf("Error touching cached embedding: %v", err)
+	}
+	c.remember(key, vector)
+	return append([]float32(nil), vector...), true
+}
+
+// Put caches the embedding of text, unless it doesn't have the cache's
+// dimensions.
+func (c *Cache) Put(text string, vector []float32) {
+	if !c.fits(vector) {
+		log.Printf("Not caching embedding of %d dimensions, want %d", len(vector), c.dimensions)
+		return
+	}
+	key := c.key(text)
+	vector = append([]float32(nil), vector...)
+	c.remember(key, vector)
+
+	if c.dir != "" {
+		if err := c.writeFile(key, vector); err != nil {
+			log.Printf("Error caching embedding: %v", err)
+		}
+	}
+}
+
+// remember adds an entry to the LRU, evicting the least recently used one if
+// the cache is full.
+func (c *Cache) remember(key string, vector []float32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*cacheEntry).vector = vector
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, vector: vector})
+
+	if c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+func (c *Cache) path(key string) string {
+	// Fan out so that no single directory gets too large
+	return filepath.Join(c.dir, key[:2], key+".bin")
+}
+
+// Prune removes the embeddings on disk that were not used for maxAge and
+// returns how many it removed.
+func (c *Cache) Prune() (int, error) {
+	if c.dir == "" || c.maxAge <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-c.maxAge)
+	removed := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".bin" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// readFile reads an embedding stored as little-endian float32s.
+func (c *Cache) readFile(key string) ([]float32, error) {
+	path := c.path(key)
+	if c.maxAge > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(info.ModTime()) > c.maxAge {
+			os.Remove(path)
+			return nil, os.ErrNotExist
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%4 != 0 {
+		return nil, fmt.Errorf("corrupt cache file %s", c.path(key))
+	}
+
+	vector := make([]float32, len(data)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:]))
+	}
+	return vector, nil
+}
+
+func (c *Cache) writeFile(key string, vector []float32) error {
+	data := make([]byte, len(vector)*4)
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(v))
+	}
+
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// Write then rename, so that a crash never leaves a truncated vector behind
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// CachedClient serves embeddings from a Cache and embeds only the texts it
+// has not seen before.
+type CachedClient struct {
+	next  BatchEmbedder
+	cache *Cache
+}
+
+func NewCachedClient(next BatchEmbedder, cache *Cache) *CachedClient {
+	return &CachedClient{next: next, cache: cache}
+}
+
+func (c *CachedClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := c.EmbedBatch(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch returns the vectors of texts, embedding the uncached ones in
+// one batch. Repeated texts are only embedded once.
+func (c *CachedClient) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	missing := make(map[string][]int) // text -> positions in texts
+	var toEmbed []string
+
+	for i, text := range texts {
+		if vector, ok := c.cache.Get(text); ok {
+			vectors[i] = vector
+			continue
+		}
+		if _, seen := missing[text]; !seen {
+			toEmbed = append(toEmbed, text)
+		}
+		missing[text] = append(missing[text], i)
+	}
+	if len(toEmbed) == 0 {
+		return vectors, nil
+	}
+
+	embedded, err := c.next.EmbedBatch(ctx, toEmbed)
+	if err != nil {
+		return nil, err
+	}
+	for j, text := range toEmbed {
+		c.cache.Put(text, embedded[j])
+		for _, i := range missing[text] {
+			vectors[i] = embedded[j]
+		}
+	}
+	return vectors, nil
+}
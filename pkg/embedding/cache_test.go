@@ -0,0 +1,114 @@
+package embedding
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewCache("m", 1, 2, "")
+	cache.Put("a", []float32{1})
+	cache.Put("b", []float32{2})
+	cache.Get("a")
+	cache.Put("c", []float32{3})
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expected the least recently used entry to be evicted")
+	}
+	if v, ok := cache.Get("a"); !ok || v[0] != 1 {
+		t.Errorf("Get(a) = %v, %v", v, ok)
+	}
+
+	// Callers get copies they can't corrupt the cache through
+	v, _ := cache.Get("c")
+	v[0] = 42
+	if v, _ := cache.Get("c"); v[0] != 3 {
+		t.Errorf("cached vector was modified through a returned copy: %v", v)
+	}
+}
+
+func TestCache_Disk(t *testing.T) {
+	dir := t.TempDir()
+	NewCache("m1", 2, 10, dir).Put("hello", []float32{0.5, -1.25})
+
+	// A fresh cache, as after a restart, finds it on disk
+	if v, ok := NewCache("m1", 2, 10, dir).Get("hello"); !ok || len(v) != 2 || v[0] != 0.5 || v[1] != -1.25 {
+		t.Errorf("Get() from disk = %v, %v", v, ok)
+	}
+
+	// Another model never gets the vector, nor does the same model name once
+	// its dimensions changed
+	if _, ok := NewCache("m2", 2, 10, dir).Get("hello"); ok {
+		t.Error("expected caches of different models to be separate")
+	}
+	if _, ok := NewCache("m1", 3, 10, dir).Get("hello"); ok {
+		t.Error("expected caches of different dimensions to be separate")
+	}
+
+	// Vectors of other dimensions are not cached
+	cache := NewCache("m1", 2, 10, dir)
+	cache.Put("odd", []float32{1, 2, 3})
+	if _, ok := cache.Get("odd"); ok {
+		t.Error("expected a vector of the wrong length not to be cached")
+	}
+}
+
+func TestCache_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	cache := NewCache("m", 1, 10, dir)
+	cache.SetMaxAge(time.Hour)
+	cache.Put("old", []float32{1})
+	cache.Put("new", []float32{2})
+
+	// "old" was last used two hours ago
+	old := cache.path(cache.key("old"))
+	past := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(old, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := cache.Prune(); err != nil || n != 1 {
+		t.Fatalf("Prune() = %d, %v, want 1 removed", n, err)
+	}
+	if _, err := os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expected the expired embedding to be removed, got %v", err)
+	}
+
+	fresh := NewCache("m", 1, 10, dir)
+	fresh.SetMaxAge(time.Hour)
+	if _, ok := fresh.Get("old"); ok {
+		t.Error("expected the expired embedding to be gone")
+	}
+	if v, ok := fresh.Get("new"); !ok || v[0] != 2 {
+		t.Errorf("Get(new) = %v, %v", v, ok)
+	}
+}
+
+func TestCachedClient(t *testing.T) {
+	var batches [][]string
+	server := newEmbeddingServer(t, &batches)
+	defer server.Close()
+
+	client := NewCachedClient(NewClient("key", server.URL, 0), NewCache("m", 1, 10, ""))
+	ctx := context.Background()
+
+	if _, err := client.Embed(ctx, "hi"); err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+	vectors, err := client.EmbedBatch(ctx, []string{"hi", "hey", "hey", "hello"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+
+	// "hi" is cached and "hey" is only embedded once
+	if len(batches) != 2 || len(batches[1]) != 2 || batches[1][0] != "hey" || batches[1][1] != "hello" {
+		t.Errorf("unexpected batches: %v", batches)
+	}
+	for i, want := range []float32{2, 3, 3, 5} {
+		if vectors[i][0] != want {
+			t.Errorf("vector %d = %v, want [%v]", i, vectors[i], want)
+		}
+	}
+}
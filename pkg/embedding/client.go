@@ -2,36 +2,77 @@ package embedding
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
+// DefaultBatchSize is how many texts EmbedBatch sends per request unless
+// SetBatchSize says otherwise.
+const DefaultBatchSize = 32
+
 type Client struct {
-	apiKey string
-	apiURL string
-	client *http.Client
+	apiKey    string
+	apiURL    string
+	client    *http.Client
+	batchSize int
 }
 
-func NewClient(apiKey, apiURL string) *Client {
+// NewClient creates an embedding client. timeout bounds each request; 0 means none.
+func NewClient(apiKey, apiURL string, timeout time.Duration) *Client {
 	return &Client{
-		apiKey: apiKey,
-		apiURL: apiURL,
-		client: &http.Client{},
+		apiKey:    apiKey,
+		apiURL:    apiURL,
+		client:    &http.Client{Timeout: timeout},
+		batchSize: DefaultBatchSize,
+	}
+}
+
+// SetBatchSize sets how many texts EmbedBatch sends per request. It must be
+// called before the client is used.
+func (c *Client) SetBatchSize(n int) {
+	if n > 0 {
+		c.batchSize = n
+	}
+}
+
+func (c *Client) Embed(ctx context.Context, text string) ([]float32, error) {
+	vectors, err := c.embed(ctx, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return vectors[0], nil
+}
+
+// EmbedBatch embeds texts in requests of up to the batch size and returns
+// their vectors in the same order.
+func (c *Client) EmbedBatch(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += c.batchSize {
+		end := min(start+c.batchSize, len(texts))
+		batch, err := c.embed(ctx, texts[start:end])
+		if err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, batch...)
 	}
+	return vectors, nil
 }
 
-func (c *Client) Embed(text string) ([]float32, error) {
+// embed sends a single request for texts.
+func (c *Client) embed(ctx context.Context, texts []string) ([][]float32, error) {
 	reqBody := map[string]interface{}{
-		"texts": []string{text},
+		"texts": texts,
 	}
 	jsonBody, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", c.apiURL, bytes.NewBuffer(jsonBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL, bytes.NewBuffer(jsonBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -57,9 +98,9 @@ func (c *Client) Embed(text string) ([]float32, error) {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	if len(apiResp.Embeddings) == 0 {
-		return nil, fmt.Errorf("no embeddings returned")
+	if len(apiResp.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(apiResp.Embeddings))
 	}
 
-	return apiResp.Embeddings[0], nil
+	return apiResp.Embeddings, nil
 }
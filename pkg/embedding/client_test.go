@@ -0,0 +1,66 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newEmbeddingServer embeds each text as {len(text)} and records the batches.
+func newEmbeddingServer(t *testing.T, batches *[][]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Texts []string `json:"texts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+		*batches = append(*batches, req.Texts)
+
+		embeddings := make([][]float32, len(req.Texts))
+		for i, text := range req.Texts {
+			embeddings[i] = []float32{float32(len(text))}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"embeddings": embeddings})
+	}))
+}
+
+func TestClient_EmbedBatch(t *testing.T) {
+	var batches [][]string
+	server := newEmbeddingServer(t, &batches)
+	defer server.Close()
+
+	client := NewClient("key", server.URL, 0)
+	client.SetBatchSize(2)
+
+	vectors, err := client.EmbedBatch(context.Background(), []string{"a", "bb", "ccc", "dddd", "eeeee"})
+	if err != nil {
+		t.Fatalf("EmbedBatch() error = %v", err)
+	}
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[2]) != 1 {
+		t.Errorf("expected batches of 2, 2 and 1, got %v", batches)
+	}
+	for i, v := range vectors {
+		if v[0] != float32(i+1) {
+			t.Errorf("vector %d = %v, want [%d]", i, v, i+1)
+		}
+	}
+
+	if v, err := client.Embed(context.Background(), "hello"); err != nil || v[0] != 5 {
+		t.Errorf("Embed() = %v, %v", v, err)
+	}
+}
+
+func TestClient_RejectsMissingEmbeddings(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"embeddings": [[1]]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient("key", server.URL, 0)
+	if _, err := client.EmbedBatch(context.Background(), []string{"a", "b"}); err == nil {
+		t.Error("expected an error when fewer embeddings than texts come back")
+	}
+}
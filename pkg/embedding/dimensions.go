@@ -0,0 +1,40 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// dimensionsProbe is embedded to find out how long the model's vectors are
+const dimensionsProbe = "dimensions probe"
+
+// Dimensions returns the length of the vectors e produces, found by embedding
+// a probe text. configured, if not 0, is the length set in the config and
+// must agree with e. If e cannot be reached, configured is trusted instead,
+// or else known, the length already in use elsewhere (such as the database);
+// 0 means unknown.
+func Dimensions(ctx context.Context, e Embedder, configured, known int) (int, error) {
+	vector, err := e.Embed(ctx, dimensionsProbe)
+	if err != nil {
+		switch {
+		case configured > 0:
+			log.Printf("Could not check embedding dimensions, using the configured %d: %v", configured, err)
+			return configured, nil
+		case known > 0:
+			log.Printf("Could not detect embedding dimensions, assuming %d: %v", known, err)
+			return known, nil
+		}
+		return 0, fmt.Errorf("failed to detect embedding dimensions, set embedding.dimensions in the config: %w", err)
+	}
+
+	detected := len(vector)
+	if detected == 0 {
+		return 0, errors.New("embedding API returned an empty vector")
+	}
+	if configured > 0 && configured != detected {
+		return 0, fmt.Errorf("embedding.dimensions is %d but the embedding model returns %d-dimensional vectors", configured, detected)
+	}
+	return detected, nil
+}
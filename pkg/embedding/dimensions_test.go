@@ -0,0 +1,45 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDimensions(t *testing.T) {
+	ctx := context.Background()
+	up := &stubEmbedder{vector: make([]float32, 768)}
+	down := &stubEmbedder{err: errors.New("api down")}
+
+	tests := []struct {
+		name       string
+		embedder   Embedder
+		configured int
+		known      int
+		want       int
+		wantErr    bool
+	}{
+		{name: "Detected", embedder: up, want: 768},
+		{name: "Configured and matching", embedder: up, configured: 768, want: 768},
+		{name: "Configured and mismatched", embedder: up, configured: 2048, wantErr: true},
+		{name: "Detection ignores the known length", embedder: up, known: 2048, want: 768},
+		{name: "API down, configured", embedder: down, configured: 1024, known: 2048, want: 1024},
+		{name: "API down, known", embedder: down, known: 2048, want: 2048},
+		{name: "API down, nothing to go by", embedder: down, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Dimensions(ctx, tt.embedder, tt.configured, tt.known)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %d, got %d, %v", tt.want, got, err)
+			}
+		})
+	}
+}
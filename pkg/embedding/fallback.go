@@ -0,0 +1,58 @@
+package embedding
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Embedder turns text into a vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// FallbackClient embeds with a primary embedder, normally the API, and falls
+// back to a local one while the primary fails.
+//
+// Fallback vectors are not comparable with the primary's, so searches made
+// with them find little among memories stored with primary vectors; they keep
+// replies going rather than keeping retrieval working. Anything that is
+// persisted should be embedded with EmbedPrimary instead.
+type FallbackClient struct {
+	primary Embedder
+	local   Embedder
+	mu      sync.Mutex
+	down    bool // Whether the last primary call failed
+}
+
+func NewFallbackClient(primary, local Embedder) *FallbackClient {
+	return &FallbackClient{primary: primary, local: local}
+}
+
+// Embed returns the primary's vector, or the local one if the primary fails.
+func (c *FallbackClient) Embed(ctx context.Context, text string) ([]float32, error) {
+	vector, err := c.EmbedPrimary(ctx, text)
+	if err == nil || ctx.Err() != nil {
+		return vector, err
+	}
+	return c.local.Embed(ctx, text)
+}
+
+// EmbedPrimary embeds with the primary only.
+func (c *FallbackClient) EmbedPrimary(ctx context.Context, text string) ([]float32, error) {
+	vector, err := c.primary.Embed(ctx, text)
+	if ctx.Err() != nil {
+		// Cancellation says nothing about the primary's health
+		return nil, ctx.Err()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil && !c.down {
+		log.Printf("Embedding API failed, falling back to local embeddings: %v", err)
+	} else if err == nil && c.down {
+		log.Printf("Embedding API recovered")
+	}
+	c.down = err != nil
+	return vector, err
+}
@@ -0,0 +1,41 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type stubEmbedder struct {
+	vector []float32
+	err    error
+}
+
+func (s *stubEmbedder) Embed(_ context.Context, _ string) ([]float32, error) {
+	return s.vector, s.err
+}
+
+func TestFallbackClient(t *testing.T) {
+	primary := &stubEmbedder{vector: []float32{1, 0}}
+	local := &stubEmbedder{vector: []float32{0, 1}}
+	client := NewFallbackClient(primary, local)
+	ctx := context.Background()
+
+	if v, err := client.Embed(ctx, "hi"); err != nil || v[0] != 1 {
+		t.Errorf("expected the primary's vector, got %v, %v", v, err)
+	}
+
+	primary.err = errors.New("api down")
+	if v, err := client.Embed(ctx, "hi"); err != nil || v[1] != 1 {
+		t.Errorf("expected the local vector while the primary is down, got %v, %v", v, err)
+	}
+	if _, err := client.EmbedPrimary(ctx, "hi"); err == nil {
+		t.Error("expected EmbedPrimary not to fall back")
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := client.Embed(cancelled, "hi"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected cancellation not to fall back, got %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package embedding
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// LocalEmbedder computes embeddings in-process by feature hashing: words,
+// word pairs and character trigrams are hashed into a fixed number of
+// dimensions. It needs no model or network, so it always works, but it only
+// captures shared wording, not meaning. Its vectors are not comparable with
+// those of the embedding API.
+type LocalEmbedder struct {
+	dimensions int
+}
+
+// Feature weights; whole words count more than fragments of them
+const (
+	wordWeight    = 1.0
+	bigramWeight  = 0.7
+	trigramWeight = 0.3
+)
+
+// NewLocalEmbedder creates an embedder producing vectors of the given length.
+func NewLocalEmbedder(dimensions int) *LocalEmbedder {
+	return &LocalEmbedder{dimensions: dimensions}
+}
+
+// Embed returns the L2-normalised feature vector of text.
+func (e *LocalEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	if len(words) == 0 {
+		return nil, errors.New("nothing to embed")
+	}
+
+	vector := make([]float64, e.dimensions)
+	for i, word := range words {
+		e.add(vector, "w:"+word, wordWeight)
+		if i > 0 {
+			e.add(vector, "b:"+words[i-1]+" "+word, bigramWeight)
+		}
+		padded := []rune(" " + word + " ")
+		for j := 0; j+3 <= len(padded); j++ {
+			e.add(vector, "t:"+string(padded[j:j+3]), trigramWeight)
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return nil, errors.New("nothing to embed")
+	}
+
+	out := make([]float32, e.dimensions)
+	for i, v := range vector {
+		out[i] = float32(v / norm)
+	}
+	return out, nil
+}
+
+// add hashes feature into vector. The sign comes from the hash too, so that
+// collisions tend to cancel out instead of piling up.
+func (e *LocalEmbedder) add(vector []float64, feature string, weight float64) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	idx := int(sum % uint64(e.dimensions))
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	vector[idx] += weight
+}
@@ -0,0 +1,57 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestLocalEmbedder(t *testing.T) {
+	embedder := NewLocalEmbedder(256)
+	ctx := context.Background()
+
+	embed := func(text string) []float32 {
+		vector, err := embedder.Embed(ctx, text)
+		if err != nil {
+			t.Fatalf("Embed(%q) error = %v", text, err)
+		}
+		return vector
+	}
+
+	tea := embed("User likes green tea")
+	if len(tea) != 256 {
+		t.Fatalf("expected 256 dimensions, got %d", len(tea))
+	}
+	var norm float64
+	for _, v := range tea {
+		norm += float64(v * v)
+	}
+	if math.Abs(norm-1) > 1e-5 {
+		t.Errorf("expected a unit vector, got norm %f", norm)
+	}
+
+	// Deterministic, so vectors stay comparable across restarts
+	if cosine(tea, embed("User likes green tea")) < 0.9999 {
+		t.Error("expected the same text to embed identically")
+	}
+
+	similar := cosine(tea, embed("the user LIKES drinking green tea!"))
+	unrelated := cosine(tea, embed("Works as a nurse in Osaka"))
+	if similar <= unrelated {
+		t.Errorf("expected shared wording to score higher: similar %.3f, unrelated %.3f", similar, unrelated)
+	}
+
+	if _, err := embedder.Embed(ctx, " ?! "); err == nil {
+		t.Error("expected text without words to fail")
+	}
+}
+
+func cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i] * b[i])
+		normA += float64(a[i] * a[i])
+		normB += float64(b[i] * b[i])
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,65 @@
+package eval
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Example is one message of a dataset, labelled with the decisions Nino
+// should make about it.
+type Example struct {
+	Text      string `json:"text"`
+	Reply     bool   `json:"reply"`      // She should answer it without being mentioned
+	Task      bool   `json:"task"`       // It asks for work she refuses to do
+	Label     string `json:"label"`      // Optional: the reply trigger that should fire
+	TaskLabel string `json:"task_label"` // Optional: the task label it should get
+}
+
+// LoadDataset reads a dataset with one JSON example per line. Blank lines
+// are skipped.
+func LoadDataset(path string) ([]Example, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dataset: %w", err)
+	}
+	defer file.Close()
+
+	var examples []Example
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var ex Example
+		if err := json.Unmarshal([]byte(text), &ex); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := ex.validate(); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		examples = append(examples, ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read dataset: %w", err)
+	}
+	if len(examples) == 0 {
+		return nil, errors.New("dataset is empty")
+	}
+	return examples, nil
+}
+
+func (ex Example) validate() error {
+	if ex.Text == "" {
+		return errors.New("text is required")
+	}
+	if ex.Label != "" && !ex.Reply {
+		return errors.New("label is set but reply is false")
+	}
+	return nil
+}
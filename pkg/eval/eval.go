@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"ninoai/pkg/bot"
+)
+
+// DefaultStep is the distance between the thresholds tried by sweeps.
+const DefaultStep = 0.05
+
+// noLabel stands for no reply trigger firing, or no task, in confusion
+// matrices.
+const noLabel = "(none)"
+
+// Report holds how well a classifier and a reply policy made the decisions
+// of a dataset.
+type Report struct {
+	Examples int
+
+	Reply       Binary     // Whether a trigger fired, against Example.Reply
+	ReplyLabels *Confusion // Trigger expected by Example.Label against the one that fired
+	ReplySweeps []Sweep    // One per trigger, with the other thresholds as they are
+
+	Task       Binary     // Whether CheckTask would refuse, against Example.Task
+	TaskLabels *Confusion // Example.TaskLabel against the task CheckTask would see
+	TaskSweep  Sweep
+}
+
+// Sweep shows how a decision fares as one threshold changes.
+type Sweep struct {
+	Label   string // Trigger label; empty for the task threshold
+	Current float64
+	Points  []SweepPoint
+}
+
+type SweepPoint struct {
+	Threshold float64
+	Binary
+}
+
+// Best returns the point with the highest F1, preferring thresholds close to
+// the current one on ties.
+func (s Sweep) Best() SweepPoint {
+	var best SweepPoint
+	for i, p := range s.Points {
+		switch f1, bestF1 := p.F1(), best.F1(); {
+		case i == 0 || f1 > bestF1:
+			best = p
+		case f1 == bestF1 && math.Abs(p.Threshold-s.Current) < math.Abs(best.Threshold-s.Current):
+			best = p
+		}
+	}
+	return best
+}
+
+// classified keeps the analysis of an example, so that sweeps can decide
+// again without asking the classifier.
+type classified struct {
+	Example
+	*bot.Analysis
+}
+
+// Run analyses every example like the bot does, in one classification with
+// the policy's triggers and the task labels, and compares the decisions with
+// the expected ones. Only the policy's triggers are evaluated: its mode and
+// probability are ignored.
+// step spaces the thresholds of the sweeps; 0 means DefaultStep.
+func Run(ctx context.Context, cl bot.Classifier, policy bot.ReplyPolicy, examples []Example, step float64) (*Report, error) {
+	if step <= 0 {
+		step = DefaultStep
+	}
+	replyLabels := policy.Labels()
+	taskLabels := bot.TaskLabels()
+
+	all := make([]classified, 0, len(examples))
+	for i, ex := range examples {
+		if ex.Label != "" && !slices.Contains(replyLabels, ex.Label) {
+			return nil, fmt.Errorf("example %d: %q is not a reply trigger", i+1, ex.Label)
+		}
+		if ex.TaskLabel != "" && !slices.Contains(taskLabels, ex.TaskLabel) {
+			return nil, fmt.Errorf("example %d: %q is not a task label", i+1, ex.TaskLabel)
+		}
+
+		analysis, err := bot.Analyze(ctx, cl, ex.Text, policy)
+		if err != nil {
+			return nil, fmt.Errorf("example %d: failed to classify: %w", i+1, err)
+		}
+		all = append(all, classified{Example: ex, Analysis: analysis})
+	}
+
+	report := &Report{
+		Examples:    len(all),
+		ReplyLabels: NewConfusion(),
+		TaskLabels:  NewConfusion(),
+	}
+	report.Reply = replyDecisions(all, policy, report.ReplyLabels)
+	report.Task = taskDecisions(all, bot.TaskThreshold, report.TaskLabels)
+
+	thresholds := sweepThresholds(step)
+	for i, trigger := range policy.Triggers {
+		sweep := Sweep{Label: trigger.Label, Current: trigger.Threshold}
+		swept := policy
+		swept.Triggers = slices.Clone(policy.Triggers)
+		for _, t := range withCurrent(thresholds, trigger.Threshold) {
+			swept.Triggers[i].Threshold = t
+			sweep.Points = append(sweep.Points, SweepPoint{Threshold: t, Binary: replyDecisions(all, swept, nil)})
+		}
+		report.ReplySweeps = append(report.ReplySweeps, sweep)
+	}
+	report.TaskSweep = Sweep{Current: bot.TaskThreshold}
+	for _, t := range withCurrent(thresholds, bot.TaskThreshold) {
+		report.TaskSweep.Points = append(report.TaskSweep.Points, SweepPoint{Threshold: t, Binary: taskDecisions(all, t, nil)})
+	}
+	return report, nil
+}
+
+// replyDecisions decides whether to reply to every example, filling labels
+// if it isn't nil.
+func replyDecisions(all []classified, policy bot.ReplyPolicy, labels *Confusion) Binary {
+	var b Binary
+	for _, c := range all {
+		trigger, ok := policy.Trigger(c.Results)
+		b.Add(c.Reply, ok)
+		if labels == nil {
+			continue
+		}
+
+		predicted := noLabel
+		if ok {
+			predicted = trigger.Label
+		}
+		switch {
+		case c.Label != "":
+			labels.Add(c.Label, predicted)
+		case !c.Reply:
+			labels.Add(noLabel, predicted)
+		}
+		// A reply without an expected trigger only counts for the decision
+	}
+	return b
+}
+
+// taskDecisions decides whether every example is a task, filling labels if
+// it isn't nil.
+func taskDecisions(all []classified, threshold float64, labels *Confusion) Binary {
+	var b Binary
+	for _, c := range all {
+		isTask := bot.IsTask(c.Analysis.Task.Label, c.Analysis.Task.Score, threshold)
+		b.Add(c.Example.Task, isTask)
+		if labels == nil {
+			continue
+		}
+
+		predicted := noLabel
+		if isTask {
+			predicted = c.Analysis.Task.Label
+		}
+		switch {
+		case c.TaskLabel != "":
+			labels.Add(c.TaskLabel, predicted)
+		case !c.Example.Task:
+			labels.Add(noLabel, predicted)
+		}
+	}
+	return b
+}
+
+// sweepThresholds returns the thresholds between 0 and 1, exclusive, step
+// apart.
+func sweepThresholds(step float64) []float64 {
+	var thresholds []float64
+	for i := 1; ; i++ {
+		t := math.Round(float64(i)*step*1000) / 1000
+		if t >= 1 {
+			return thresholds
+		}
+		thresholds = append(thresholds, t)
+	}
+}
+
+// withCurrent adds the current threshold to the swept ones, unless it is 0,
+// which disables a trigger.
+func withCurrent(thresholds []float64, current float64) []float64 {
+	if current <= 0 || slices.Contains(thresholds, current) {
+		return thresholds
+	}
+	thresholds = append(slices.Clone(thresholds), current)
+	slices.Sort(thresholds)
+	return thresholds
+}
@@ -0,0 +1,166 @@
+package eval
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"ninoai/pkg/bot"
+	"ninoai/pkg/classifier"
+)
+
+// scoreClassifier gives each text fixed scores, and every other label 0
+type scoreClassifier map[string]map[string]float64
+
+func (s scoreClassifier) Classify(ctx context.Context, text string, labels []string) (string, float64, error) {
+	results, err := s.ClassifyAll(ctx, text, labels)
+	if err != nil {
+		return "", 0, err
+	}
+	return results[0].Label, results[0].Score, nil
+}
+
+func (s scoreClassifier) ClassifyAll(_ context.Context, text string, labels []string) ([]classifier.ClassificationResult, error) {
+	results := make([]classifier.ClassificationResult, len(labels))
+	for i, label := range labels {
+		results[i] = classifier.ClassificationResult{Label: label, Score: s[text][label]}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	return results, nil
+}
+
+func TestRun(t *testing.T) {
+	essay := bot.TaskLabels()[3]
+	policy := bot.ReplyPolicy{Triggers: []bot.ReplyTrigger{
+		{Label: "food", Threshold: 0.6},
+		{Label: "casual", Threshold: 0},
+	}}
+	cl := scoreClassifier{
+		"pasta?":      {"food": 0.8},
+		"snacks":      {"food": 0.5},
+		"hi":          {"casual": 0.9},
+		"hungry":      {"food": 0.7},
+		"write essay": {"casual": 0.9, essay: 0.8},
+	}
+	examples := []Example{
+		{Text: "pasta?", Reply: true, Label: "food"}, // Fires
+		{Text: "snacks", Reply: true, Label: "food"}, // Under the threshold
+		{Text: "hi"},     // Casual never fires
+		{Text: "hungry"}, // Fires, but shouldn't
+		{Text: "write essay", Task: true, TaskLabel: essay},
+	}
+
+	report, err := Run(context.Background(), cl, policy, examples, 0.1)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if want := (Binary{TP: 1, FP: 1, FN: 1, TN: 2}); report.Reply != want {
+		t.Errorf("Reply = %+v, want %+v", report.Reply, want)
+	}
+	if n := report.ReplyLabels.Count("food", noLabel); n != 1 {
+		t.Errorf("expected one missed food trigger, got %d", n)
+	}
+	if n := report.ReplyLabels.Count(noLabel, "food"); n != 1 {
+		t.Errorf("expected one food trigger that shouldn't have fired, got %d", n)
+	}
+	if want := (Binary{TP: 1, TN: 4}); report.Task != want {
+		t.Errorf("Task = %+v, want %+v", report.Task, want)
+	}
+	if n := report.TaskLabels.Count(essay, essay); n != 1 {
+		t.Errorf("expected the essay label to be right, got %d", n)
+	}
+	if n := report.TaskLabels.Count(noLabel, noLabel); n != 4 {
+		t.Errorf("expected four messages rightly not taken for tasks, got %d", n)
+	}
+
+	if len(report.ReplySweeps) != 2 {
+		t.Fatalf("expected a sweep per trigger, got %d", len(report.ReplySweeps))
+	}
+	// 0.5 also catches snacks, which beats the current F1; lower thresholds
+	// tie with it but are further from the current one
+	if best := report.ReplySweeps[0].Best(); best.Threshold != 0.5 {
+		t.Errorf("expected the best food threshold to be 0.5, got %+v", best)
+	}
+	if p := report.TaskSweep.Points; !containsThreshold(p, bot.TaskThreshold) {
+		t.Errorf("expected the task sweep to include the current threshold, got %+v", p)
+	}
+
+	var out bytes.Buffer
+	if err := report.Write(&out); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	if !strings.Contains(out.String(), "Threshold sweep: food (current 0.60, best 0.50)") {
+		t.Errorf("unexpected report:\n%s", out.String())
+	}
+}
+
+func containsThreshold(points []SweepPoint, threshold float64) bool {
+	for _, p := range points {
+		if p.Threshold == threshold {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRun_UnknownLabel(t *testing.T) {
+	policy := bot.ReplyPolicy{Triggers: []bot.ReplyTrigger{{Label: "food", Threshold: 0.6}}}
+	_, err := Run(context.Background(), scoreClassifier{}, policy, []Example{{Text: "hi", Reply: true, Label: "cats"}}, 0)
+	if err == nil || !strings.Contains(err.Error(), "not a reply trigger") {
+		t.Errorf("expected an unknown trigger error, got %v", err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	var b Binary
+	b.Add(true, true)
+	b.Add(true, false)
+	b.Add(false, true)
+	b.Add(false, true)
+	b.Add(false, false)
+
+	if b.Precision() != 1.0/3 || b.Recall() != 0.5 || b.F1() != 0.4 {
+		t.Errorf("unexpected metrics: precision %v, recall %v, F1 %v", b.Precision(), b.Recall(), b.F1())
+	}
+	if (Binary{}).Precision() != 0 || (Binary{}).F1() != 0 {
+		t.Error("expected metrics without decisions to be 0")
+	}
+}
+
+func TestLoadDataset(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "dataset.jsonl")
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	examples, err := LoadDataset(write(`{"text": "what's for dinner", "reply": true, "label": "food"}
+
+{"text": "write my essay", "task": true}
+`))
+	if err != nil {
+		t.Fatalf("LoadDataset() error = %v", err)
+	}
+	if len(examples) != 2 || examples[0].Label != "food" || !examples[1].Task {
+		t.Errorf("unexpected examples: %+v", examples)
+	}
+
+	for content, wantErr := range map[string]string{
+		`{"reply": true}`:                 "line 1: text is required",
+		`{"text": "hi", "label": "food"}`: "reply is false",
+		`{"text": "hi"` + "\n" + `nope`:   "line 1",
+		"\n\n":                            "empty",
+	} {
+		if _, err := LoadDataset(write(content)); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("LoadDataset(%q) error = %v, want it to mention %q", content, err, wantErr)
+		}
+	}
+}
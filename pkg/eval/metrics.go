@@ -0,0 +1,104 @@
+package eval
+
+import "sort"
+
+// Binary counts the outcomes of a yes or no decision.
+type Binary struct {
+	TP, FP, FN, TN int
+}
+
+// Add counts one decision.
+func (b *Binary) Add(expected, predicted bool) {
+	switch {
+	case expected && predicted:
+		b.TP++
+	case predicted:
+		b.FP++
+	case expected:
+		b.FN++
+	default:
+		b.TN++
+	}
+}
+
+// Precision is the share of yes decisions that were right, 0 if there were
+// none.
+func (b Binary) Precision() float64 {
+	return ratio(b.TP, b.TP+b.FP)
+}
+
+// Recall is the share of expected yes decisions that were made, 0 if none
+// were expected.
+func (b Binary) Recall() float64 {
+	return ratio(b.TP, b.TP+b.FN)
+}
+
+func (b Binary) F1() float64 {
+	return f1(b.Precision(), b.Recall())
+}
+
+// Confusion counts how often each expected label was predicted as each
+// label.
+type Confusion struct {
+	counts map[string]map[string]int // Expected -> predicted -> count
+}
+
+func NewConfusion() *Confusion {
+	return &Confusion{counts: make(map[string]map[string]int)}
+}
+
+// Add counts one prediction.
+func (c *Confusion) Add(expected, predicted string) {
+	if c.counts[expected] == nil {
+		c.counts[expected] = make(map[string]int)
+	}
+	c.counts[expected][predicted]++
+}
+
+func (c *Confusion) Count(expected, predicted string) int {
+	return c.counts[expected][predicted]
+}
+
+// Labels returns every label seen as expected or predicted, sorted.
+func (c *Confusion) Labels() []string {
+	seen := make(map[string]bool)
+	for expected, row := range c.counts {
+		seen[expected] = true
+		for predicted := range row {
+			seen[predicted] = true
+		}
+	}
+	labels := make([]string, 0, len(seen))
+	for label := range seen {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Binary returns the counts of predicting label, against all other labels.
+func (c *Confusion) Binary(label string) Binary {
+	var b Binary
+	for expected, row := range c.counts {
+		for predicted, n := range row {
+			for range n {
+				b.Add(expected == label, predicted == label)
+			}
+		}
+	}
+	return b
+}
+
+func ratio(n, d int) float64 {
+	if d == 0 {
+		return 0
+	}
+	return float64(n) / float64(d)
+}
+
+func f1(precision, recall float64) float64 {
+	if precision+recall == 0 {
+		return 0
+	}
+	return 2 * precision * recall / (precision + recall)
+}
@@ -0,0 +1,87 @@
+package eval
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Write prints the report as plain text tables.
+func (r *Report) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Examples: %d\n\n", r.Examples)
+
+	fmt.Fprintln(tw, "== Reply ==")
+	writeBinary(tw, r.Reply)
+	writeConfusion(tw, "Trigger labels", r.ReplyLabels)
+	for _, s := range r.ReplySweeps {
+		writeSweep(tw, fmt.Sprintf("Threshold sweep: %s", s.Label), s)
+	}
+
+	fmt.Fprintln(tw, "== Task ==")
+	writeBinary(tw, r.Task)
+	writeConfusion(tw, "Task labels", r.TaskLabels)
+	writeSweep(tw, "Threshold sweep", r.TaskSweep)
+	return tw.Flush()
+}
+
+func writeBinary(w io.Writer, b Binary) {
+	fmt.Fprintf(w, "precision %.2f, recall %.2f, F1 %.2f (TP %d, FP %d, FN %d, TN %d)\n\n",
+		b.Precision(), b.Recall(), b.F1(), b.TP, b.FP, b.FN, b.TN)
+}
+
+// writeConfusion prints a per-label table and the confusion matrix. Labels
+// are long, so the matrix refers to them by number.
+func writeConfusion(w io.Writer, title string, c *Confusion) {
+	labels := c.Labels()
+	if len(labels) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "%s:\n", title)
+	fmt.Fprintln(w, "#\tprecision\trecall\tF1\tsupport\t label")
+	for i, label := range labels {
+		b := c.Binary(label)
+		fmt.Fprintf(w, "%d\t%.2f\t%.2f\t%.2f\t%d\t %s\n", i+1, b.Precision(), b.Recall(), b.F1(), b.TP+b.FN, label)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Confusion (rows expected, columns predicted):")
+	header := []string{""}
+	for i := range labels {
+		header = append(header, fmt.Sprint(i+1))
+	}
+	fmt.Fprintln(w, strings.Join(header, "\t")+"\t")
+	for i, expected := range labels {
+		row := []string{fmt.Sprint(i + 1)}
+		for _, predicted := range labels {
+			row = append(row, fmt.Sprint(c.Count(expected, predicted)))
+		}
+		fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+	}
+	fmt.Fprintln(w)
+}
+
+// writeSweep prints one row per threshold, marking the current one and the
+// best one.
+func writeSweep(w io.Writer, title string, s Sweep) {
+	best := s.Best()
+	fmt.Fprintf(w, "%s (current %.2f, best %.2f)\n", title, s.Current, best.Threshold)
+	fmt.Fprintln(w, "threshold\tprecision\trecall\tF1\t")
+	for _, p := range s.Points {
+		var marks []string
+		if p.Threshold == s.Current {
+			marks = append(marks, "current")
+		}
+		if p.Threshold == best.Threshold {
+			marks = append(marks, "best")
+		}
+		mark := ""
+		if len(marks) > 0 {
+			mark = " " + strings.Join(marks, ", ")
+		}
+		fmt.Fprintf(w, "%.2f\t%.2f\t%.2f\t%.2f\t%s\n", p.Threshold, p.Precision(), p.Recall(), p.F1(), mark)
+	}
+	fmt.Fprintln(w)
+}
@@ -0,0 +1,71 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Memory categories
+const (
+	CategoryIdentity     = "identity"     // Name, age, pronouns, job, where they live
+	CategoryPreference   = "preference"   // Likes and dislikes
+	CategoryRelationship = "relationship" // People and pets in their life
+	CategoryEvent        = "event"        // Things that happened or will happen to them
+	CategoryGoal         = "goal"         // What they are working towards
+)
+
+// Categories lists every memory category.
+var Categories = []string{CategoryIdentity, CategoryPreference, CategoryRelationship, CategoryEvent, CategoryGoal}
+
+// Memory is a fact about a user worth remembering.
+type Memory struct {
+	Text     string `json:"text"`
+	Category string `json:"category,omitempty"`
+	// Key and Value restate the fact as a profile field, e.g. name=Alex or
+	// birthday=03-14, for facts that have a single current value.
+	Key             string  `json:"key,omitempty"`
+	Value           string  `json:"value,omitempty"`
+	SourceMessageID string  `json:"source_message_id,omitempty"`
+	SourceChannelID string  `json:"source_channel_id,omitempty"`
+	Confidence      float64 `json:"confidence,omitempty"` // 0 to 1; 0 if unknown
+}
+
+// ValidCategory reports whether category is one of Categories.
+func ValidCategory(category string) bool {
+	return slices.Contains(Categories, category)
+}
+
+// NormalizeKey turns a profile key into lowercase words joined by
+// underscores, so that "Favorite Food" and "favorite_food" are one field.
+func NormalizeKey(key string) string {
+	words := strings.FieldsFunc(strings.ToLower(key), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "_")
+}
+
+// Profile consolidates the keyed facts about a user: the latest value of
+// each key.
+type Profile struct {
+	Fields    map[string]string `json:"fields"`
+	UpdatedAt int64             `json:"updated_at"` // Unix timestamp
+}
+
+// String lists the fields one per line, sorted by key, or returns "" for an
+// empty profile.
+func (p Profile) String() string {
+	keys := make([]string, 0, len(p.Fields))
+	for key := range p.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, len(keys))
+	for i, key := range keys {
+		lines[i] = fmt.Sprintf("- %s: %s", strings.ReplaceAll(key, "_", " "), p.Fields[key])
+	}
+	return strings.Join(lines, "\n")
+}
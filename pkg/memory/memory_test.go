@@ -0,0 +1,28 @@
+package memory
+
+import "testing"
+
+func TestNormalizeKey(t *testing.T) {
+	for key, want := range map[string]string{
+		"name":             "name",
+		"Favorite Food":    "favorite_food",
+		" favorite-food! ": "favorite_food",
+		"pet's name":       "pet_s_name",
+		"???":              "",
+	} {
+		if got := NormalizeKey(key); got != want {
+			t.Errorf("NormalizeKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestProfileString(t *testing.T) {
+	if s := (Profile{}).String(); s != "" {
+		t.Errorf("expected an empty profile to render as nothing, got %q", s)
+	}
+
+	p := Profile{Fields: map[string]string{"name": "Alex", "favorite_food": "ramen"}}
+	if s, want := p.String(), "- favorite food: ramen\n- name: Alex"; s != want {
+		t.Errorf("String() = %q, want %q", s, want)
+	}
+}
@@ -0,0 +1,165 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxQueuedMemories bounds the queue during a long outage; the oldest facts
+// are dropped first.
+const maxQueuedMemories = 1000
+
+// Embedder turns text into a vector.
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float32, error)
+}
+
+// QueuedMemory is a fact waiting to be embedded and stored.
+type QueuedMemory struct {
+	UserID string `json:"user_id"`
+	Memory
+	QueuedAt int64 `json:"queued_at"` // Unix timestamp
+}
+
+// EmbedQueue keeps facts that could not be embedded, so that they can be
+// stored once the embedding API recovers instead of being lost. The queue is
+// persisted to a JSON file and survives restarts.
+type EmbedQueue struct {
+	path  string
+	mu    sync.Mutex
+	items []QueuedMemory
+}
+
+// NewEmbedQueue loads the queue persisted at path, if any.
+func NewEmbedQueue(path string) *EmbedQueue {
+	q := &EmbedQueue{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading embed queue: %v", err)
+		}
+		return q
+	}
+	if err := json.Unmarshal(data, &q.items); err != nil {
+		log.Printf("Error unmarshaling embed queue: %v", err)
+	}
+	return q
+}
+
+// Push queues a fact for userID.
+func (q *EmbedQueue) Push(userID string, m Memory) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.items = append(q.items, QueuedMemory{UserID: userID, Memory: m, QueuedAt: time.Now().Unix()})
+	if len(q.items) > maxQueuedMemories {
+		log.Printf("Embed queue full, dropping %d oldest memories", len(q.items)-maxQueuedMemories)
+		q.items = q.items[len(q.items)-maxQueuedMemories:]
+	}
+	q.save()
+}
+
+// Len returns the number of queued facts.
+func (q *EmbedQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// DeleteUser drops the queued facts of userID.
+func (q *EmbedQueue) DeleteUser(userID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if item.UserID != userID {
+			kept = append(kept, item)
+		}
+	}
+	q.items = kept
+	q.save()
+}
+
+// Flush embeds the queued facts in order and adds them to store. It stops at
+// the first failure, which most likely means the embedding API or the store
+// is still down, and keeps the remaining facts queued. Facts the store rejects
+// as duplicates are dropped. It returns the number of facts taken off the
+// queue.
+func (q *EmbedQueue) Flush(ctx context.Context, embedder Embedder, store Store) int {
+	q.mu.Lock()
+	batch := append([]QueuedMemory(nil), q.items...)
+	q.mu.Unlock()
+
+	done := make(map[QueuedMemory]bool)
+	for _, item := range batch {
+		if !q.contains(item) {
+			// Deleted while we were busy with earlier facts
+			continue
+		}
+		vector, err := embedder.Embed(ctx, item.Text)
+		if err != nil {
+			log.Printf("Embed queue: still unable to embed: %v", err)
+			break
+		}
+		var dup *DuplicateError
+		if err := store.Add(ctx, item.UserID, item.Memory, vector); err != nil && !errors.As(err, &dup) {
+			log.Printf("Embed queue: error storing memory: %v", err)
+			break
+		}
+		done[item] = true
+	}
+	if len(done) == 0 {
+		return 0
+	}
+
+	// Facts may have been pushed or deleted meanwhile, so filter the current queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.items[:0]
+	for _, item := range q.items {
+		if !done[item] {
+			kept = append(kept, item)
+		}
+	}
+	q.items = kept
+	q.save()
+
+	return len(done)
+}
+
+func (q *EmbedQueue) contains(item QueuedMemory) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, queued := range q.items {
+		if queued == item {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the queue to disk. The caller must hold q.mu.
+func (q *EmbedQueue) save() {
+	data, err := json.MarshalIndent(q.items, "", "  ")
+	if err != nil {
+		log.Printf("Error marshaling embed queue: %v", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		log.Printf("Error creating embed queue directory: %v", err)
+		return
+	}
+
+	if err := os.WriteFile(q.path, data, 0644); err != nil {
+		log.Printf("Error saving embed queue: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// flakyEmbedder fails once it has embedded okUntil texts
+type flakyEmbedder struct {
+	calls   int
+	okUntil int
+}
+
+func (e *flakyEmbedder) Embed(_ context.Context, text string) ([]float32, error) {
+	e.calls++
+	if e.calls > e.okUntil {
+		return nil, errors.New("api down")
+	}
+	return []float32{float32(len(text)), 1, 0}, nil
+}
+
+func TestEmbedQueue(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "embed_queue.json")
+	store := NewFileStore(t.TempDir())
+
+	queue := NewEmbedQueue(path)
+	queue.Push("alice", Memory{Text: "Likes tea"})
+	queue.Push("bob", Memory{Text: "Plays the violin"})
+	queue.Push("alice", Memory{Text: "Has a cat named Mochi"})
+
+	// The queue survives a restart
+	queue = NewEmbedQueue(path)
+	if queue.Len() != 3 {
+		t.Fatalf("expected 3 queued memories after reload, got %d", queue.Len())
+	}
+
+	// The API is still down after the first fact
+	if n := queue.Flush(ctx, &flakyEmbedder{okUntil: 1}, store); n != 1 || queue.Len() != 2 {
+		t.Fatalf("Flush() = %d with %d left, want 1 with 2 left", n, queue.Len())
+	}
+	if got, _ := store.SearchMemories(ctx, "alice", "tea", []float32{9, 1, 0}, 5); len(got) != 1 || got[0].Text != "Likes tea" {
+		t.Errorf("expected the first fact to be stored, got %v", got)
+	}
+
+	// Deleted users' facts are never stored
+	queue.DeleteUser("bob")
+	if n := queue.Flush(ctx, &flakyEmbedder{okUntil: 10}, store); n != 1 || queue.Len() != 0 {
+		t.Fatalf("Flush() = %d with %d left, want 1 with 0 left", n, queue.Len())
+	}
+	if got, _ := store.SearchMemories(ctx, "bob", "", []float32{16, 1, 0}, 5); len(got) != 0 {
+		t.Errorf("expected no memories for a deleted user, got %v", got)
+	}
+	if NewEmbedQueue(path).Len() != 0 {
+		t.Error("expected the emptied queue to be persisted")
+	}
+}
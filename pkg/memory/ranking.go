@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Ranking weighs the signals search results are ranked by. Each signal is
+// between 0 and 1, and a memory's score is their weighted average.
+type Ranking struct {
+	Similarity float64 // Cosine similarity of the memory to the query
+	Keyword    float64 // BM25 match of the query's words in the memory, relative to the best match
+	Recency    float64 // Halves every HalfLife since the memory was stored or last revised
+	Importance float64 // See Memory.Importance
+	HalfLife   time.Duration
+	// MinSimilarity leaves out memories less similar than this that share
+	// no words with the query
+	MinSimilarity float64
+}
+
+// DefaultRanking mostly ranks by similarity, as search did before the
+// other signals were added.
+func DefaultRanking() Ranking {
+	return Ranking{
+		Similarity:    0.6,
+		Keyword:       0.2,
+		Recency:       0.1,
+		Importance:    0.1,
+		HalfLife:      30 * 24 * time.Hour,
+		MinSimilarity: 0.6,
+	}
+}
+
+// categoryImportance is how much each category of fact tends to matter in a
+// conversation
+var categoryImportance = map[string]float64{
+	CategoryIdentity:     1,
+	CategoryRelationship: 0.9,
+	CategoryGoal:         0.8,
+	CategoryPreference:   0.7,
+	CategoryEvent:        0.6,
+}
+
+// Importance estimates how much the memory matters, between 0 and 1: the
+// weight of its category, scaled by its confidence when known. Memories
+// stored before categories count as 0.5.
+func (m Memory) Importance() float64 {
+	importance, ok := categoryImportance[m.Category]
+	if !ok {
+		importance = 0.5
+	}
+	if m.Confidence > 0 {
+		importance *= m.Confidence
+	}
+	return importance
+}
+
+// candidate is a memory considered for a search
+type candidate struct {
+	MemoryItem
+	Similarity float64 // Cosine similarity to the query
+	Score      float64 // Set by rank
+}
+
+func (c candidate) result() SearchResult {
+	return SearchResult{
+		ID:         c.ID,
+		Text:       c.Text,
+		Category:   c.Category,
+		Timestamp:  c.Timestamp,
+		Score:      c.Score,
+		Similarity: c.Similarity,
+	}
+}
+
+// rank scores the candidates for query and returns the best limit of them,
+// highest first.
+func (r Ranking) rank(query string, candidates []candidate, now time.Time, limit int) []candidate {
+	keywords := bm25(query, candidates)
+
+	total := r.Similarity + r.Keyword + r.Recency + r.Importance
+	if total <= 0 {
+		total = 1
+	}
+
+	var ranked []candidate
+	for i, c := range candidates {
+		if c.Similarity < r.MinSimilarity && keywords[i] == 0 {
+			continue
+		}
+		recency := 1.0
+		if age := now.Sub(time.Unix(c.Timestamp, 0)); r.HalfLife > 0 && age > 0 {
+			recency = math.Pow(0.5, float64(age)/float64(r.HalfLife))
+		}
+		c.Score = (r.Similarity*c.Similarity + r.Keyword*keywords[i] + r.Recency*recency + r.Importance*c.Importance()) / total
+		ranked = append(ranked, c)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	if len(ranked) > limit {
+		ranked = ranked[:limit]
+	}
+	return ranked
+}
+
+// BM25 parameters: k1 dampens repeated words and b normalises by length
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 scores how well each candidate's text matches the words of query,
+// with the candidates as the corpus. Scores are divided by the best one, so
+// they are between 0 and 1.
+func bm25(query string, candidates []candidate) []float64 {
+	scores := make([]float64, len(candidates))
+	terms := tokenize(query)
+	if len(terms) == 0 || len(candidates) == 0 {
+		return scores
+	}
+
+	docs := make([]map[string]int, len(candidates))
+	lengths := make([]int, len(candidates))
+	frequency := make(map[string]int) // Term -> candidates containing it
+	totalLength := 0
+	for i, c := range candidates {
+		docs[i] = make(map[string]int)
+		for _, token := range tokenize(c.Text) {
+			if docs[i][token] == 0 {
+				frequency[token]++
+			}
+			docs[i][token]++
+			lengths[i]++
+		}
+		totalLength += lengths[i]
+	}
+	avgLength := max(float64(totalLength)/float64(len(candidates)), 1)
+
+	n := float64(len(candidates))
+	best := 0.0
+	for i := range candidates {
+		for _, term := range terms {
+			tf := float64(docs[i][term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(frequency[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			scores[i] += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*float64(lengths[i])/avgLength))
+		}
+		best = max(best, scores[i])
+	}
+	if best > 0 {
+		for i := range scores {
+			scores[i] /= best
+		}
+	}
+	return scores
+}
+
+// stopwords are too common to tell memories apart
+var stopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "do": true, "did": true, "for": true, "from": true, "has": true, "have": true, "he": true,
+	"her": true, "his": true, "i": true, "in": true, "is": true, "it": true, "me": true, "my": true,
+	"of": true, "on": true, "or": true, "she": true, "that": true, "the": true, "their": true, "them": true,
+	"they": true, "this": true, "to": true, "was": true, "what": true, "with": true, "you": true, "your": true,
+}
+
+// tokenize splits text into lowercase words without stopwords, and strips
+// a plural s so that "cats" matches "cat".
+func tokenize(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	var tokens []string
+	for _, word := range words {
+		if stopwords[word] {
+			continue
+		}
+		if len(word) > 3 && strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss") {
+			word = strings.TrimSuffix(word, "s")
+		}
+		tokens = append(tokens, word)
+	}
+	return tokens
+}
@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestBM25(t *testing.T) {
+	candidates := []candidate{
+		{MemoryItem: MemoryItem{Memory: Memory{Text: "Has two cats named Mochi and Kuro"}}},
+		{MemoryItem: MemoryItem{Memory: Memory{Text: "Has a cat"}}},
+		{MemoryItem: MemoryItem{Memory: Memory{Text: "Works as a nurse"}}},
+	}
+
+	scores := bm25("what are my cats called", candidates)
+	if scores[2] != 0 {
+		t.Errorf("expected no match without shared words, got %v", scores[2])
+	}
+	// The shorter memory mentions cats just as often, so it matches best
+	if scores[1] != 1 || scores[0] <= 0 || scores[0] >= 1 {
+		t.Errorf("expected scores relative to the best match, got %v", scores)
+	}
+
+	if scores := bm25("the and of", candidates); scores[0] != 0 || scores[1] != 0 {
+		t.Errorf("expected stopwords not to match, got %v", scores)
+	}
+}
+
+func TestRankingOrder(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	day := int64(24 * time.Hour / time.Second)
+	newCandidate := func(text, category string, similarity float64, daysOld int64) candidate {
+		return candidate{
+			MemoryItem: MemoryItem{Memory: Memory{Text: text, Category: category}, Timestamp: now.Unix() - daysOld*day},
+			Similarity: similarity,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		ranking    Ranking
+		query      string
+		candidates []candidate
+		want       []string
+	}{
+		{
+			name:    "Similarity only",
+			ranking: Ranking{Similarity: 1},
+			candidates: []candidate{
+				newCandidate("Likes tea", "", 0.7, 0),
+				newCandidate("Likes coffee", "", 0.9, 0),
+			},
+			want: []string{"Likes coffee", "Likes tea"},
+		},
+		{
+			name:    "Keyword match outranks similarity",
+			ranking: Ranking{Similarity: 0.5, Keyword: 0.5, MinSimilarity: 0.6},
+			query:   "what is my dog called",
+			candidates: []candidate{
+				newCandidate("Enjoys long walks", "", 0.8, 0),
+				newCandidate("Has a dog named Rex", "", 0.65, 0),
+			},
+			want: []string{"Has a dog named Rex", "Enjoys long walks"},
+		},
+		{
+			name:    "Keyword match rescues a dissimilar memory",
+			ranking: Ranking{Similarity: 1, Keyword: 1, MinSimilarity: 0.6},
+			query:   "rex",
+			candidates: []candidate{
+				newCandidate("Has a dog named Rex", "", 0.3, 0),
+				newCandidate("Likes jazz", "", 0.4, 0),
+			},
+			want: []string{"Has a dog named Rex"},
+		},
+		{
+			name:    "Recency breaks ties",
+			ranking: Ranking{Similarity: 1, Recency: 1, HalfLife: 30 * 24 * time.Hour},
+			candidates: []candidate{
+				newCandidate("Lived in Paris", "", 0.8, 365),
+				newCandidate("Lives in Berlin", "", 0.8, 1),
+			},
+			want: []string{"Lives in Berlin", "Lived in Paris"},
+		},
+		{
+			name:    "Importance breaks ties",
+			ranking: Ranking{Similarity: 1, Importance: 1},
+			candidates: []candidate{
+				newCandidate("Went to a concert", CategoryEvent, 0.8, 0),
+				newCandidate("Is called Alex", CategoryIdentity, 0.8, 0),
+			},
+			want: []string{"Is called Alex", "Went to a concert"},
+		},
+		{
+			name:    "Limit",
+			ranking: Ranking{Similarity: 1},
+			candidates: []candidate{
+				newCandidate("a", "", 0.7, 0),
+				newCandidate("b", "", 0.9, 0),
+				newCandidate("c", "", 0.8, 0),
+			},
+			want: []string{"b", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := tt.ranking.rank(tt.query, tt.candidates, now, 2)
+			var got []string
+			for _, c := range ranked {
+				got = append(got, c.Text)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRankingScore(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	halfLife := 10 * 24 * time.Hour
+	c := candidate{
+		MemoryItem: MemoryItem{
+			Memory:    Memory{Text: "Is called Alex", Category: CategoryIdentity, Confidence: 0.8},
+			Timestamp: now.Add(-halfLife).Unix(),
+		},
+		Similarity: 0.9,
+	}
+
+	ranked := Ranking{Similarity: 2, Recency: 1, Importance: 1, HalfLife: halfLife}.rank("", []candidate{c}, now, 1)
+	// (2*0.9 + 1*0.5 + 1*0.8) / 4
+	if len(ranked) != 1 || math.Abs(ranked[0].Score-0.775) > 1e-9 {
+		t.Errorf("expected a weighted average of 0.775, got %+v", ranked)
+	}
+}
+
+func TestFileStoreSearchRanking(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	store.SetRanking(Ranking{Similarity: 0.5, Keyword: 0.5, MinSimilarity: 0.6})
+	ctx := context.Background()
+
+	store.Add(ctx, "alice", Memory{Text: "Enjoys long walks"}, []float32{1, 0, 0})
+	store.Add(ctx, "alice", Memory{Text: "Has a dog named Rex"}, []float32{0.6, 0.8, 0})
+	store.Add(ctx, "alice", Memory{Text: "Plays the piano"}, []float32{0, 0, 1})
+
+	got, err := store.SearchMemories(ctx, "alice", "how is Rex doing", []float32{1, 0, 0}, 5)
+	if err != nil || len(got) != 2 || got[0].Text != "Has a dog named Rex" || got[1].Text != "Enjoys long walks" {
+		t.Fatalf("expected the keyword match first and the dissimilar memory left out, got %v, %v", got, err)
+	}
+	if r := got[0]; r.ID == "" || r.Timestamp == 0 || math.Abs(r.Similarity-0.6) > 1e-6 || r.Score <= got[1].Score {
+		t.Errorf("expected the result to carry its ID, timestamp, similarity and score, got %+v", r)
+	}
+}
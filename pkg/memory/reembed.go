@@ -0,0 +1,441 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+)
+
+// BatchEmbedder embeds several texts per call.
+type BatchEmbedder interface {
+	EmbedBatch(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// ReembedOptions controls a re-embedding.
+type ReembedOptions struct {
+	BatchSize int  // Memories embedded per request
+	DryRun    bool // Only count what would be re-embedded
+}
+
+// ReembedStats reports the progress of a re-embedding.
+type ReembedStats struct {
+	Total    int // Memories in the store
+	Done     int // Memories an interrupted earlier run already re-embedded
+	Embedded int // Memories re-embedded by this run
+}
+
+// Reembedder is implemented by stores that can re-embed their memories when
+// the embedding model changes. Reembed writes the new vectors next to the
+// old ones and only switches the store over once every memory is done, so
+// the old memories stay usable if it fails. Calling it again after a failure
+// resumes where it stopped. The bot must not be running meanwhile, as
+// memories it adds could be missed.
+type Reembedder interface {
+	Reembed(ctx context.Context, embedder BatchEmbedder, dimensions int, opts ReembedOptions) (ReembedStats, error)
+}
+
+// embedTexts embeds texts in batches, checking that every vector has the
+// expected length.
+func embedTexts(ctx context.Context, embedder BatchEmbedder, texts []string, batchSize, dimensions int) ([][]float32, error) {
+	if batchSize <= 0 {
+		batchSize = len(texts)
+	}
+	vectors := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += batchSize {
+		batch := texts[start:min(start+batchSize, len(texts))]
+		embedded, err := embedder.EmbedBatch(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded) != len(batch) {
+			return nil, fmt.Errorf("embedded %d texts but got %d vectors", len(batch), len(embedded))
+		}
+		for _, vector := range embedded {
+			if len(vector) != dimensions {
+				return nil, fmt.Errorf("expected %d-dimensional vectors, got %d", dimensions, len(vector))
+			}
+		}
+		vectors = append(vectors, embedded...)
+	}
+	return vectors, nil
+}
+
+// SurrealDB
+
+var tableVersionPattern = regexp.MustCompile(`^(.*)_v(\d+)$`)
+
+// nextTableName returns the table the memories of table are re-embedded into:
+// memories_v2 after memories, memories_v3 after memories_v2 and so on.
+func nextTableName(table string) string {
+	if match := tableVersionPattern.FindStringSubmatch(table); match != nil {
+		version, _ := strconv.Atoi(match[2])
+		return fmt.Sprintf("%s_v%d", match[1], version+1)
+	}
+	return table + "_v2"
+}
+
+// surrealReembedState is the progress of a re-embedding, kept in the
+// database so that it can be resumed.
+type surrealReembedState struct {
+	Source     string `json:"source"`
+	Target     string `json:"target"`
+	Dimensions int    `json:"dimensions"`
+	After      string `json:"after"` // Key of the last memory re-embedded
+}
+
+// Reembed copies the memories into a new table with vectors from embedder,
+// then points the store at it. The old table is kept as a backup.
+func (s *SurrealStore) Reembed(ctx context.Context, embedder BatchEmbedder, dimensions int, opts ReembedOptions) (ReembedStats, error) {
+	var stats ReembedStats
+	if err := s.loadTable(ctx); err != nil {
+		return stats, fmt.Errorf("failed to look up the memories table: %w", err)
+	}
+
+	state, err := s.loadReembedState(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to load re-embedding progress: %w", err)
+	}
+	resuming := state != nil && state.Source == s.table && state.Dimensions == dimensions
+	if !resuming {
+		state = &surrealReembedState{Source: s.table, Target: nextTableName(s.table), Dimensions: dimensions}
+	}
+
+	if stats.Total, err = s.count(ctx, state.Source); err != nil {
+		return stats, err
+	}
+	if resuming {
+		if stats.Done, err = s.count(ctx, state.Target); err != nil {
+			return stats, err
+		}
+	}
+	if opts.DryRun {
+		return stats, nil
+	}
+
+	if resuming {
+		log.Printf("Resuming re-embedding of %s into %s", state.Source, state.Target)
+	} else {
+		// Whatever is in the target comes from an abandoned run, possibly with another model
+		query := fmt.Sprintf("REMOVE TABLE IF EXISTS %s;", state.Target)
+		if _, err := s.client.Query(ctx, query, map[string]interface{}{}); err != nil {
+			return stats, fmt.Errorf("failed to clear %s: %w", state.Target, err)
+		}
+		log.Printf("Re-embedding %s into %s", state.Source, state.Target)
+	}
+	if err := s.defineMemories(ctx, state.Target, dimensions); err != nil {
+		return stats, fmt.Errorf("failed to define %s: %w", state.Target, err)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = 32
+	}
+	for {
+		rows, err := s.memoriesAfter(ctx, state.Source, state.After, batchSize)
+		if err != nil {
+			return stats, err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		texts := make([]string, len(rows))
+		for i, row := range rows {
+			texts[i], _ = row["text"].(string)
+		}
+		vectors, err := embedTexts(ctx, embedder, texts, batchSize, dimensions)
+		if err != nil {
+			return stats, fmt.Errorf("failed to embed memories: %w", err)
+		}
+		for i, row := range rows {
+			row["vector"] = vectors[i]
+		}
+		state.After, _ = rows[len(rows)-1]["record_key"].(string)
+
+		// Write the batch together with the progress, so that a resumed run
+		// neither skips nor repeats memories
+		query := `
+			BEGIN TRANSACTION;
+			FOR $m IN $items {
+				UPSERT type::thing($target, $m.record_key) CONTENT {
+					user_id: $m.user_id,
+					text: $m.text,
+					category: $m.category,
+					⟨key⟩: $m.key,
+					⟨value⟩: $m.value,
+					source_message_id: $m.source_message_id,
+					source_channel_id: $m.source_channel_id,
+					confidence: $m.confidence,
+					history: $m.history,
+					timestamp: $m.timestamp,
+					vector: $m.vector
+				};
+			};
+			UPSERT store_meta:reembed CONTENT $state;
+			COMMIT TRANSACTION;
+		`
+		if _, err := s.client.Query(ctx, query, map[string]interface{}{
+			"items":  rows,
+			"target": state.Target,
+			"state":  state,
+		}); err != nil {
+			return stats, fmt.Errorf("failed to write re-embedded memories: %w", err)
+		}
+		stats.Embedded += len(rows)
+		log.Printf("Re-embedded %d/%d memories", stats.Done+stats.Embedded, stats.Total)
+	}
+
+	query := `
+		BEGIN TRANSACTION;
+		UPSERT store_meta:memories CONTENT { table: $target };
+		DELETE store_meta:reembed;
+		COMMIT TRANSACTION;
+	`
+	if _, err := s.client.Query(ctx, query, map[string]interface{}{"target": state.Target}); err != nil {
+		return stats, fmt.Errorf("failed to switch to %s: %w", state.Target, err)
+	}
+	s.table = state.Target
+	log.Printf("Switched memories to %s; %s is kept as a backup", state.Target, state.Source)
+	return stats, nil
+}
+
+func (s *SurrealStore) loadReembedState(ctx context.Context) (*surrealReembedState, error) {
+	result, err := s.client.QueryFirst(ctx, "SELECT * FROM store_meta:reembed;", map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	rows, _ := result.([]interface{})
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	row, ok := rows[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected row format")
+	}
+
+	state := &surrealReembedState{Dimensions: toInt(row["dimensions"])}
+	state.Source, _ = row["source"].(string)
+	state.Target, _ = row["target"].(string)
+	state.After, _ = row["after"].(string)
+	return state, nil
+}
+
+func (s *SurrealStore) count(ctx context.Context, table string) (int, error) {
+	result, err := s.client.QueryFirst(ctx, fmt.Sprintf("SELECT count() FROM %s GROUP ALL;", table), map[string]interface{}{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count %s: %w", table, err)
+	}
+	rows, _ := result.([]interface{})
+	if len(rows) == 0 {
+		return 0, nil
+	}
+	row, _ := rows[0].(map[string]interface{})
+	return toInt(row["count"]), nil
+}
+
+// memoriesAfter returns up to limit memories of table in key order, starting
+// after the key after ("" starts from the beginning).
+func (s *SurrealStore) memoriesAfter(ctx context.Context, table, after string, limit int) ([]map[string]interface{}, error) {
+	where := "true"
+	if after != "" {
+		where = "id > type::thing($table, $after)"
+	}
+	query := fmt.Sprintf(`
+		SELECT record::id(id) AS record_key, user_id, text, category, ⟨key⟩, ⟨value⟩,
+			source_message_id, source_channel_id, confidence, history, timestamp
+		FROM %s
+		WHERE %s
+		ORDER BY record_key
+		LIMIT %d;
+	`, table, where, limit)
+
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{"table": table, "after": after})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read memories from %s: %w", table, err)
+	}
+	rows, _ := result.([]interface{})
+	memories := make([]map[string]interface{}, 0, len(rows))
+	for _, row := range rows {
+		if rowMap, ok := row.(map[string]interface{}); ok {
+			memories = append(memories, rowMap)
+		}
+	}
+	return memories, nil
+}
+
+// toInt converts a number decoded from SurrealDB.
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case uint64:
+		return int(n)
+	case float64:
+		return int(n)
+	}
+	return 0
+}
+
+// FileStore
+
+// fileReembedState is the progress of a re-embedding, kept in the storage
+// directory so that it can be resumed.
+type fileReembedState struct {
+	Dimensions int  `json:"dimensions"`
+	Switching  bool `json:"switching"` // Every user is re-embedded and files are being replaced
+}
+
+const (
+	fileReembedStateName = "reembed.json"
+	reembedSuffix        = ".reembed" // Re-embedded memory.json waiting for the switch
+)
+
+// Reembed writes each user's re-embedded memories next to their memory.json,
+// then replaces every memory.json once all users are done.
+func (vs *FileStore) Reembed(ctx context.Context, embedder BatchEmbedder, dimensions int, opts ReembedOptions) (ReembedStats, error) {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	var stats ReembedStats
+	users, err := vs.memoryUsers()
+	if err != nil {
+		return stats, err
+	}
+
+	statePath := filepath.Join(vs.storageDir, fileReembedStateName)
+	state, err := loadFileReembedState(statePath)
+	if err != nil {
+		return stats, err
+	}
+	if state != nil && state.Switching && state.Dimensions != dimensions {
+		return stats, fmt.Errorf("an interrupted re-embedding to %d dimensions was already switching over; finish it with that model first", state.Dimensions)
+	}
+	resuming := state != nil && state.Dimensions == dimensions
+
+	pending := make(map[string][]MemoryItem)
+	for _, userID := range users {
+		items, err := vs.load(userID)
+		if err != nil {
+			return stats, fmt.Errorf("failed to load memories of %s: %w", userID, err)
+		}
+		stats.Total += len(items)
+		if resuming && fileExists(vs.getFilePath(userID)+reembedSuffix) {
+			stats.Done += len(items)
+			continue
+		}
+		pending[userID] = items
+	}
+	if resuming && state.Switching {
+		// Users whose files were already replaced have no re-embedded file left
+		stats.Done = stats.Total
+	}
+	if opts.DryRun {
+		return stats, nil
+	}
+
+	if !resuming {
+		// Files left by an abandoned run may come from another model
+		for _, userID := range users {
+			if err := os.Remove(vs.getFilePath(userID) + reembedSuffix); err != nil && !os.IsNotExist(err) {
+				return stats, err
+			}
+		}
+		state = &fileReembedState{Dimensions: dimensions}
+		if err := writeFileAtomic(statePath, state); err != nil {
+			return stats, err
+		}
+	}
+
+	if !state.Switching {
+		for _, userID := range users {
+			items, ok := pending[userID]
+			if !ok {
+				continue
+			}
+			texts := make([]string, len(items))
+			for i, item := range items {
+				texts[i] = item.Text
+			}
+			vectors, err := embedTexts(ctx, embedder, texts, opts.BatchSize, dimensions)
+			if err != nil {
+				return stats, fmt.Errorf("failed to embed memories of %s: %w", userID, err)
+			}
+			for i := range items {
+				items[i].Vector = vectors[i]
+			}
+			if err := writeFileAtomic(vs.getFilePath(userID)+reembedSuffix, items); err != nil {
+				return stats, err
+			}
+			stats.Embedded += len(items)
+			log.Printf("Re-embedded %d/%d memories", stats.Done+stats.Embedded, stats.Total)
+		}
+
+		state.Switching = true
+		if err := writeFileAtomic(statePath, state); err != nil {
+			return stats, err
+		}
+	}
+
+	for _, userID := range users {
+		path := vs.getFilePath(userID)
+		if err := os.Rename(path+reembedSuffix, path); err != nil && !os.IsNotExist(err) {
+			return stats, err
+		}
+	}
+	return stats, os.Remove(statePath)
+}
+
+// memoryUsers returns the users that have a memory file.
+func (vs *FileStore) memoryUsers() ([]string, error) {
+	entries, err := os.ReadDir(vs.storageDir)
+	if err != nil {
+		return nil, err
+	}
+	var users []string
+	for _, entry := range entries {
+		if entry.IsDir() && fileExists(filepath.Join(vs.storageDir, entry.Name(), "memory.json")) {
+			users = append(users, entry.Name())
+		}
+	}
+	return users, nil
+}
+
+func loadFileReembedState(path string) (*fileReembedState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var state fileReembedState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// writeFileAtomic writes v as JSON through a temporary file, so that a crash
+// never leaves a truncated file behind.
+func writeFileAtomic(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
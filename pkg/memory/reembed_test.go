@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// lengthEmbedder embeds each text as a vector filled with its length, and
+// fails once it has embedded failAfter texts (0 never fails).
+type lengthEmbedder struct {
+	dimensions int
+	failAfter  int
+	embedded   int
+}
+
+func (e *lengthEmbedder) EmbedBatch(_ context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		if e.failAfter > 0 && e.embedded >= e.failAfter {
+			return nil, errors.New("api down")
+		}
+		e.embedded++
+		vectors[i] = make([]float32, e.dimensions)
+		for j := range vectors[i] {
+			vectors[i][j] = float32(len(text))
+		}
+	}
+	return vectors, nil
+}
+
+func TestNextTableName(t *testing.T) {
+	for table, want := range map[string]string{
+		"memories":     "memories_v2",
+		"memories_v2":  "memories_v3",
+		"memories_v10": "memories_v11",
+	} {
+		if got := nextTableName(table); got != want {
+			t.Errorf("nextTableName(%q) = %q, want %q", table, got, want)
+		}
+	}
+}
+
+func TestFileStoreReembed(t *testing.T) {
+	dir := t.TempDir()
+	store := NewFileStore(dir)
+	ctx := context.Background()
+
+	for _, m := range []struct {
+		user, text string
+		vector     []float32
+	}{
+		{"alice", "likes tea", []float32{1, 0}},
+		{"alice", "has a cat", []float32{0, 1}},
+		{"bob", "lives in Oslo", []float32{1, 0}},
+	} {
+		if err := store.Add(ctx, m.user, Memory{Text: m.text}, m.vector); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	stats, err := store.Reembed(ctx, &lengthEmbedder{dimensions: 3}, 3, ReembedOptions{DryRun: true})
+	if err != nil || stats.Total != 3 || stats.Embedded != 0 {
+		t.Fatalf("unexpected dry run result: %+v, %v", stats, err)
+	}
+
+	// Fail partway; nothing may be switched over yet
+	failing := &lengthEmbedder{dimensions: 3, failAfter: 2}
+	if _, err := store.Reembed(ctx, failing, 3, ReembedOptions{BatchSize: 1}); err == nil {
+		t.Fatal("expected the failing embedder to abort the re-embedding")
+	}
+	for _, user := range []string{"alice", "bob"} {
+		items, _ := store.load(user)
+		if len(items[0].Vector) != 2 {
+			t.Fatalf("memories of %s were switched before the re-embedding finished", user)
+		}
+	}
+
+	stats, err = store.Reembed(ctx, &lengthEmbedder{dimensions: 3}, 3, ReembedOptions{DryRun: true})
+	if err != nil || stats.Done != 2 {
+		t.Fatalf("expected the dry run to count 2 memories as done, got %+v, %v", stats, err)
+	}
+
+	embedder := &lengthEmbedder{dimensions: 3}
+	stats, err = store.Reembed(ctx, embedder, 3, ReembedOptions{BatchSize: 1})
+	if err != nil {
+		t.Fatalf("Reembed failed: %v", err)
+	}
+	if stats.Total != 3 || stats.Done != 2 || stats.Embedded != 1 || embedder.embedded != 1 {
+		t.Errorf("expected the resumed run to embed only the remaining memory, got %+v after %d texts", stats, embedder.embedded)
+	}
+
+	results, err := store.SearchMemories(ctx, "alice", "", []float32{9, 9, 9}, 2)
+	if err != nil || len(results) != 2 {
+		t.Fatalf("expected alice's memories to be searchable with new vectors, got %v, %v", results, err)
+	}
+	items, _ := store.load("bob")
+	if len(items) != 1 || len(items[0].Vector) != 3 || items[0].Text != "lives in Oslo" {
+		t.Errorf("unexpected memories for bob after re-embedding: %+v", items)
+	}
+	for _, leftover := range []string{
+		filepath.Join(dir, fileReembedStateName),
+		filepath.Join(dir, "bob", "memory.json"+reembedSuffix),
+	} {
+		if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed after the switch", leftover)
+		}
+	}
+}
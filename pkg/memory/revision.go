@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Relations of a new fact to a similar stored memory
+const (
+	RelationDuplicate   = "duplicate"   // Says the same thing; the new fact is dropped
+	RelationRefines     = "refines"     // Adds to it; the two are merged
+	RelationSupersedes  = "supersedes"  // The stored fact is out of date, e.g. they moved
+	RelationContradicts = "contradicts" // Says the opposite; the newer fact wins
+)
+
+// Relations lists every relation.
+var Relations = []string{RelationDuplicate, RelationRefines, RelationSupersedes, RelationContradicts}
+
+// ValidRelation reports whether relation is one of Relations.
+func ValidRelation(relation string) bool {
+	return slices.Contains(Relations, relation)
+}
+
+// KeyedRelation relates two facts about the same profile key without asking
+// anyone: the same value is a duplicate and another one supersedes it. It
+// returns false if the facts aren't about the same key.
+func KeyedRelation(existing, m Memory) (string, bool) {
+	if existing.Key == "" || existing.Key != m.Key {
+		return "", false
+	}
+	if strings.EqualFold(strings.TrimSpace(existing.Value), strings.TrimSpace(m.Value)) {
+		return RelationDuplicate, true
+	}
+	return RelationSupersedes, true
+}
+
+// Revision is an earlier version of a memory, kept when a newer fact
+// replaced it.
+type Revision struct {
+	Text       string `json:"text"`
+	Category   string `json:"category,omitempty"`
+	Timestamp  int64  `json:"timestamp"`   // When this version was stored
+	Relation   string `json:"relation"`    // How the newer fact related to it
+	ReplacedAt int64  `json:"replaced_at"` // Unix timestamp
+}
+
+// DuplicateError is returned by Store.Add when a new fact is too close to a
+// stored memory to be added next to it. Whether it repeats, updates or
+// contradicts that memory is up to the caller, who can replace it with
+// Store.Revise.
+type DuplicateError struct {
+	ID         string // ID of the stored memory
+	Existing   Memory
+	New        Memory
+	Similarity float64
+}
+
+func (e *DuplicateError) Error() string {
+	return fmt.Sprintf("duplicate memory detected (similarity: %.4f): existing='%s', new='%s'",
+		e.Similarity, e.Existing.Text, e.New.Text)
+}
+
+// newMemoryID returns a random ID for a memory in a FileStore.
+func newMemoryID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// legacyMemoryID derives an ID for a memory stored before memories had IDs,
+// so that it stays the same until the memory is next saved.
+func legacyMemoryID(item MemoryItem) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d:%s", item.Timestamp, item.Text))
+	return hex.EncodeToString(sum[:8])
+}
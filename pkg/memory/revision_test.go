@@ -0,0 +1,29 @@
+package memory
+
+import "testing"
+
+func TestKeyedRelation(t *testing.T) {
+	city := func(value string) Memory {
+		return Memory{Text: "Lives in " + value, Key: "city", Value: value}
+	}
+	tests := []struct {
+		name     string
+		existing Memory
+		m        Memory
+		want     string
+		wantOK   bool
+	}{
+		{name: "Same value", existing: city("Paris"), m: city(" paris"), want: RelationDuplicate, wantOK: true},
+		{name: "New value", existing: city("Paris"), m: city("Berlin"), want: RelationSupersedes, wantOK: true},
+		{name: "Other key", existing: city("Paris"), m: Memory{Text: "Works in Paris", Key: "job", Value: "Paris"}},
+		{name: "No key", existing: Memory{Text: "Has a cat"}, m: Memory{Text: "Has a cat named Mochi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := KeyedRelation(tt.existing, tt.m)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("KeyedRelation() = %q, %v; want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
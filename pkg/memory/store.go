@@ -1,35 +1,71 @@
 package memory
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"sync"
 	"time"
 )
 
 type MemoryItem struct {
-	Text      string    `json:"text"`
-	Vector    []float32 `json:"vector"`
-	Timestamp int64     `json:"timestamp"` // Unix timestamp
+	ID string `json:"id,omitempty"`
+	Memory
+	Vector    []float32  `json:"vector"`
+	Timestamp int64      `json:"timestamp"`         // Unix timestamp
+	History   []Revision `json:"history,omitempty"` // Earlier versions, oldest first
 }
 
+// SearchResult is a memory found by SearchMemories, with what it was
+// ranked by.
+type SearchResult struct {
+	ID         string
+	Text       string
+	Category   string
+	Timestamp  int64   // When the memory was stored or last revised
+	Score      float64 // Ranking score, between 0 and 1
+	Similarity float64 // Cosine similarity to the query
+}
+
+// ErrNotFound is returned for a memory ID the user has no memory with.
+var ErrNotFound = errors.New("memory not found")
+
+// Store persists long-term memories, user profiles and the recent messages
+// cache. Every call takes a context so that slow backends can be cancelled.
 type Store interface {
-	Add(userId string, text string, vector []float32) error
-	Search(userId string, queryVector []float32, limit int) ([]string, error)
+	// Add returns a *DuplicateError if m is too close to a stored memory
+	Add(ctx context.Context, userId string, m Memory, vector []float32) error
+	// Revise replaces the memory with the given ID by m, keeping the old
+	// version in its history with how m related to it
+	Revise(ctx context.Context, userId, id string, m Memory, vector []float32, relation string) error
+	// SearchMemories ranks the user's memories for query, whose embedding
+	// is queryVector, and returns the best limit of them, highest first
+	SearchMemories(ctx context.Context, userId, query string, queryVector []float32, limit int) ([]SearchResult, error)
+	// List returns the user's memories, newest first, without their vectors
+	List(ctx context.Context, userId string) ([]MemoryItem, error)
+	// Delete removes one of the user's memories, or returns ErrNotFound
+	Delete(ctx context.Context, userId, id string) error
+	// Profile of consolidated keyed facts; UpdateProfile removes the fields
+	// given an empty value
+	GetProfile(ctx context.Context, userId string) (Profile, error)
+	UpdateProfile(ctx context.Context, userId string, fields map[string]string) error
 	// Recent messages cache
-	AddRecentMessage(userId, message string) error
-	GetRecentMessages(userId string) ([]string, error)
-	ClearRecentMessages(userId string) error
+	AddRecentMessage(ctx context.Context, userId, message string) error
+	GetRecentMessages(ctx context.Context, userId string) ([]string, error)
+	ClearRecentMessages(ctx context.Context, userId string) error
 	// User data management
-	DeleteUserData(userId string) error
+	DeleteUserData(ctx context.Context, userId string) error
 }
 
 type FileStore struct {
 	storageDir string
+	ranking    Ranking
 	mu         sync.RWMutex
 }
 
@@ -37,9 +73,15 @@ func NewFileStore(storageDir string) *FileStore {
 	_ = os.MkdirAll(storageDir, 0755)
 	return &FileStore{
 		storageDir: storageDir,
+		ranking:    DefaultRanking(),
 	}
 }
 
+// SetRanking changes how SearchMemories ranks memories.
+func (vs *FileStore) SetRanking(r Ranking) {
+	vs.ranking = r
+}
+
 func (vs *FileStore) getUserDir(userId string) string {
 	return filepath.Join(vs.storageDir, userId)
 }
@@ -65,6 +107,11 @@ func (vs *FileStore) load(userId string) ([]MemoryItem, error) {
 	if err := json.Unmarshal(data, &items); err != nil {
 		return nil, err
 	}
+	for i := range items {
+		if items[i].ID == "" {
+			items[i].ID = legacyMemoryID(items[i])
+		}
+	}
 	return items, nil
 }
 
@@ -77,7 +124,7 @@ func (vs *FileStore) save(userId string, items []MemoryItem) error {
 	return os.WriteFile(path, data, 0644)
 }
 
-func (vs *FileStore) Add(userId string, text string, vector []float32) error {
+func (vs *FileStore) Add(_ context.Context, userId string, m Memory, vector []float32) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -88,16 +135,20 @@ func (vs *FileStore) Add(userId string, text string, vector []float32) error {
 
 	// Check for duplicates using embedding similarity
 	const duplicateThreshold = 0.8
+	var closest *DuplicateError
 	for _, item := range items {
 		similarity := cosineSimilarity(vector, item.Vector)
-		if similarity >= duplicateThreshold {
-			// This is a duplicate, skip adding
-			return fmt.Errorf("duplicate memory detected (similarity: %.4f): %s", similarity, text)
+		if similarity >= duplicateThreshold && (closest == nil || similarity > closest.Similarity) {
+			closest = &DuplicateError{ID: item.ID, Existing: item.Memory, New: m, Similarity: similarity}
 		}
 	}
+	if closest != nil {
+		return closest
+	}
 
 	items = append(items, MemoryItem{
-		Text:      text,
+		ID:        newMemoryID(),
+		Memory:    m,
 		Vector:    vector,
 		Timestamp: time.Now().Unix(),
 	})
@@ -105,7 +156,37 @@ func (vs *FileStore) Add(userId string, text string, vector []float32) error {
 	return vs.save(userId, items)
 }
 
-func (vs *FileStore) Search(userId string, queryVector []float32, limit int) ([]string, error) {
+func (vs *FileStore) Revise(_ context.Context, userId, id string, m Memory, vector []float32, relation string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	items, err := vs.load(userId)
+	if err != nil {
+		return err
+	}
+
+	for i := range items {
+		item := &items[i]
+		if item.ID != id {
+			continue
+		}
+		now := time.Now().Unix()
+		item.History = append(item.History, Revision{
+			Text:       item.Text,
+			Category:   item.Category,
+			Timestamp:  item.Timestamp,
+			Relation:   relation,
+			ReplacedAt: now,
+		})
+		item.Memory = m
+		item.Vector = vector
+		item.Timestamp = now
+		return vs.save(userId, items)
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
+}
+
+func (vs *FileStore) SearchMemories(_ context.Context, userId, query string, queryVector []float32, limit int) ([]SearchResult, error) {
 	vs.mu.RLock()
 	defer vs.mu.RUnlock()
 
@@ -114,31 +195,47 @@ func (vs *FileStore) Search(userId string, queryVector []float32, limit int) ([]
 		return nil, err
 	}
 
-	type match struct {
-		Text  string
-		Score float64
+	candidates := make([]candidate, len(items))
+	for i, item := range items {
+		candidates[i] = candidate{MemoryItem: item, Similarity: cosineSimilarity(queryVector, item.Vector)}
 	}
 
-	var matches []match
-	for _, item := range items {
-		score := cosineSimilarity(queryVector, item.Vector)
-		matches = append(matches, match{Text: item.Text, Score: score})
+	var results []SearchResult
+	for _, c := range vs.ranking.rank(query, candidates, time.Now(), limit) {
+		results = append(results, c.result())
 	}
+	return results, nil
+}
 
-	sort.Slice(matches, func(i, j int) bool {
-		return matches[i].Score > matches[j].Score
-	})
+func (vs *FileStore) List(_ context.Context, userId string) ([]MemoryItem, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
 
-	if len(matches) > limit {
-		matches = matches[:limit]
+	items, err := vs.load(userId)
+	if err != nil {
+		return nil, err
 	}
-
-	var results []string
-	for _, m := range matches {
-		results = append(results, m.Text)
+	for i := range items {
+		items[i].Vector = nil
 	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Timestamp > items[j].Timestamp })
+	return items, nil
+}
 
-	return results, nil
+func (vs *FileStore) Delete(_ context.Context, userId, id string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	items, err := vs.load(userId)
+	if err != nil {
+		return err
+	}
+	for i, item := range items {
+		if item.ID == id {
+			return vs.save(userId, slices.Delete(items, i, i+1))
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrNotFound, id)
 }
 
 func cosineSimilarity(a, b []float32) float64 {
@@ -157,6 +254,67 @@ func cosineSimilarity(a, b []float32) float64 {
 	return dotProduct / (math.Sqrt(normA) * math.Sqrt(normB))
 }
 
+// Profile methods
+
+func (vs *FileStore) getProfilePath(userId string) string {
+	userDir := vs.getUserDir(userId)
+	_ = os.MkdirAll(userDir, 0755) // Ensure user directory exists
+	return filepath.Join(userDir, "profile.json")
+}
+
+func (vs *FileStore) loadProfile(userId string) (Profile, error) {
+	data, err := os.ReadFile(vs.getProfilePath(userId))
+	if os.IsNotExist(err) {
+		return Profile{}, nil
+	} else if err != nil {
+		return Profile{}, err
+	}
+
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return Profile{}, err
+	}
+	return profile, nil
+}
+
+// GetProfile returns the user's profile, which is empty until a keyed fact
+// is stored.
+func (vs *FileStore) GetProfile(_ context.Context, userId string) (Profile, error) {
+	vs.mu.RLock()
+	defer vs.mu.RUnlock()
+
+	return vs.loadProfile(userId)
+}
+
+// UpdateProfile sets the given fields of the user's profile, keeping the
+// others. Fields given an empty value are removed.
+func (vs *FileStore) UpdateProfile(_ context.Context, userId string, fields map[string]string) error {
+	vs.mu.Lock()
+	defer vs.mu.Unlock()
+
+	profile, err := vs.loadProfile(userId)
+	if err != nil {
+		return err
+	}
+	if profile.Fields == nil {
+		profile.Fields = make(map[string]string)
+	}
+	for key, value := range fields {
+		if value == "" {
+			delete(profile.Fields, key)
+		} else {
+			profile.Fields[key] = value
+		}
+	}
+	profile.UpdatedAt = time.Now().Unix()
+
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(vs.getProfilePath(userId), data, 0644)
+}
+
 // Recent messages cache methods
 
 func (vs *FileStore) getRecentFilePath(userId string) string {
@@ -193,7 +351,7 @@ func (vs *FileStore) saveRecentMessages(userId string, messages []string) error
 }
 
 // AddRecentMessage adds a message to the recent messages cache (max 15)
-func (vs *FileStore) AddRecentMessage(userId, message string) error {
+func (vs *FileStore) AddRecentMessage(_ context.Context, userId, message string) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -213,7 +371,7 @@ func (vs *FileStore) AddRecentMessage(userId, message string) error {
 }
 
 // GetRecentMessages retrieves the recent messages for a user
-func (vs *FileStore) GetRecentMessages(userId string) ([]string, error) {
+func (vs *FileStore) GetRecentMessages(_ context.Context, userId string) ([]string, error) {
 	vs.mu.RLock()
 	defer vs.mu.RUnlock()
 
@@ -221,7 +379,7 @@ func (vs *FileStore) GetRecentMessages(userId string) ([]string, error) {
 }
 
 // ClearRecentMessages clears the recent messages cache for a user
-func (vs *FileStore) ClearRecentMessages(userId string) error {
+func (vs *FileStore) ClearRecentMessages(_ context.Context, userId string) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
@@ -233,8 +391,8 @@ func (vs *FileStore) ClearRecentMessages(userId string) error {
 	return nil
 }
 
-// DeleteUserData deletes all data for a user (memory + recent messages)
-func (vs *FileStore) DeleteUserData(userId string) error {
+// DeleteUserData deletes all data for a user (memory, profile and recent messages)
+func (vs *FileStore) DeleteUserData(_ context.Context, userId string) error {
 	vs.mu.Lock()
 	defer vs.mu.Unlock()
 
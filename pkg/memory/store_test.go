@@ -1,6 +1,8 @@
 package memory
 
 import (
+	"context"
+	"errors"
 	"os"
 	"testing"
 )
@@ -14,56 +16,57 @@ func TestFileStore(t *testing.T) {
 	defer os.RemoveAll(tmpDir)
 
 	store := NewFileStore(tmpDir)
+	ctx := context.Background()
 	userId := "test_user"
 
 	// Test Add
-	err = store.Add(userId, "Hello world", []float32{1.0, 0.0, 0.0})
+	err = store.Add(ctx, userId, Memory{Text: "Hello world"}, []float32{1.0, 0.0, 0.0})
 	if err != nil {
 		t.Errorf("Failed to add item: %v", err)
 	}
 
-	err = store.Add(userId, "Pizza is good", []float32{0.0, 1.0, 0.0})
+	err = store.Add(ctx, userId, Memory{Text: "Pizza is good"}, []float32{0.0, 1.0, 0.0})
 	if err != nil {
 		t.Errorf("Failed to add second item: %v", err)
 	}
 
 	// Test Search (Exact match)
-	results, err := store.Search(userId, []float32{1.0, 0.0, 0.0}, 1)
+	results, err := store.SearchMemories(ctx, userId, "", []float32{1.0, 0.0, 0.0}, 1)
 	if err != nil {
 		t.Errorf("Failed to search: %v", err)
 	}
 	if len(results) != 1 {
 		t.Errorf("Expected 1 result, got %d", len(results))
 	}
-	if results[0] != "Hello world" {
-		t.Errorf("Expected 'Hello world', got '%s'", results[0])
+	if results[0].Text != "Hello world" {
+		t.Errorf("Expected 'Hello world', got '%s'", results[0].Text)
 	}
 
 	// Test Search (Similarity)
 	// Vector {0.1, 0.9, 0.0} should be closer to {0.0, 1.0, 0.0} than {1.0, 0.0, 0.0}
-	results, err = store.Search(userId, []float32{0.1, 0.9, 0.0}, 1)
+	results, err = store.SearchMemories(ctx, userId, "", []float32{0.1, 0.9, 0.0}, 1)
 	if err != nil {
 		t.Errorf("Failed to search: %v", err)
 	}
 	if len(results) != 1 {
 		t.Errorf("Expected 1 result, got %d", len(results))
 	}
-	if results[0] != "Pizza is good" {
-		t.Errorf("Expected 'Pizza is good', got '%s'", results[0])
+	if results[0].Text != "Pizza is good" {
+		t.Errorf("Expected 'Pizza is good', got '%s'", results[0].Text)
 	}
 
 	// Test Recent Messages
-	err = store.AddRecentMessage(userId, "Test message 1")
+	err = store.AddRecentMessage(ctx, userId, "Test message 1")
 	if err != nil {
 		t.Errorf("Failed to add recent message: %v", err)
 	}
 
-	err = store.AddRecentMessage(userId, "Test message 2")
+	err = store.AddRecentMessage(ctx, userId, "Test message 2")
 	if err != nil {
 		t.Errorf("Failed to add second recent message: %v", err)
 	}
 
-	recent, err := store.GetRecentMessages(userId)
+	recent, err := store.GetRecentMessages(ctx, userId)
 	if err != nil {
 		t.Errorf("Failed to get recent messages: %v", err)
 	}
@@ -72,12 +75,12 @@ func TestFileStore(t *testing.T) {
 	}
 
 	// Test Clear Recent Messages
-	err = store.ClearRecentMessages(userId)
+	err = store.ClearRecentMessages(ctx, userId)
 	if err != nil {
 		t.Errorf("Failed to clear recent messages: %v", err)
 	}
 
-	recent, err = store.GetRecentMessages(userId)
+	recent, err = store.GetRecentMessages(ctx, userId)
 	if err != nil {
 		t.Errorf("Failed to get recent messages after clear: %v", err)
 	}
@@ -86,12 +89,12 @@ func TestFileStore(t *testing.T) {
 	}
 
 	// Test Delete User Data
-	err = store.DeleteUserData(userId)
+	err = store.DeleteUserData(ctx, userId)
 	if err != nil {
 		t.Errorf("Failed to delete user data: %v", err)
 	}
 
-	results, err = store.Search(userId, []float32{1.0, 0.0, 0.0}, 1)
+	results, err = store.SearchMemories(ctx, userId, "", []float32{1.0, 0.0, 0.0}, 1)
 	if err != nil {
 		t.Errorf("Failed to search after delete: %v", err)
 	}
@@ -136,4 +139,140 @@ func TestCosineSimilarity(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestFileStoreProfile(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+
+	profile, err := store.GetProfile(ctx, "alice")
+	if err != nil || len(profile.Fields) != 0 {
+		t.Fatalf("expected an empty profile, got %+v, %v", profile, err)
+	}
+
+	if err := store.UpdateProfile(ctx, "alice", map[string]string{"name": "Alice", "birthday": "03-14"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if err := store.UpdateProfile(ctx, "alice", map[string]string{"name": "Ally"}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	profile, err = store.GetProfile(ctx, "alice")
+	if err != nil || profile.Fields["name"] != "Ally" || profile.Fields["birthday"] != "03-14" || profile.UpdatedAt == 0 {
+		t.Errorf("expected the latest name and the kept birthday, got %+v, %v", profile, err)
+	}
+
+	if err := store.UpdateProfile(ctx, "alice", map[string]string{"birthday": ""}); err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if profile, _ := store.GetProfile(ctx, "alice"); len(profile.Fields) != 1 || profile.Fields["name"] != "Ally" {
+		t.Errorf("expected an empty value to remove the field, got %+v", profile)
+	}
+
+	// Structured fields are stored along with the text
+	fact := Memory{Text: "Alice's birthday is March 14", Category: CategoryIdentity, Key: "birthday", Value: "03-14", SourceMessageID: "m1", Confidence: 0.9}
+	if err := store.Add(ctx, "alice", fact, []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	items, err := store.load("alice")
+	if err != nil || len(items) != 1 || items[0].Memory != fact {
+		t.Errorf("expected the memory to keep its fields, got %+v, %v", items, err)
+	}
+
+	if err := store.DeleteUserData(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteUserData() error = %v", err)
+	}
+	if profile, _ := store.GetProfile(ctx, "alice"); len(profile.Fields) != 0 {
+		t.Errorf("expected the profile to be deleted with the user's data, got %+v", profile)
+	}
+}
+
+func TestFileStoreRevise(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+
+	paris := Memory{Text: "Lives in Paris", Category: CategoryIdentity}
+	if err := store.Add(ctx, "alice", paris, []float32{1, 0, 0}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// A close fact is rejected with the memory it is close to
+	berlin := Memory{Text: "Lives in Berlin", Category: CategoryIdentity}
+	var dup *DuplicateError
+	if err := store.Add(ctx, "alice", berlin, []float32{0.9, 0.1, 0}); !errors.As(err, &dup) {
+		t.Fatalf("expected a *DuplicateError, got %v", err)
+	}
+	if dup.ID == "" || dup.Existing != paris || dup.New != berlin || dup.Similarity < 0.8 {
+		t.Errorf("unexpected duplicate error %+v", dup)
+	}
+
+	if err := store.Revise(ctx, "alice", dup.ID, berlin, []float32{0.9, 0.1, 0}, RelationSupersedes); err != nil {
+		t.Fatalf("Revise() error = %v", err)
+	}
+	items, err := store.load("alice")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected the memory to be replaced, got %+v, %v", items, err)
+	}
+	item := items[0]
+	if item.ID != dup.ID || item.Memory != berlin || item.Vector[1] != 0.1 {
+		t.Errorf("expected the new fact under the same ID, got %+v", item)
+	}
+	if len(item.History) != 1 || item.History[0].Text != paris.Text || item.History[0].Relation != RelationSupersedes || item.History[0].ReplacedAt == 0 {
+		t.Errorf("expected the old fact in the history, got %+v", item.History)
+	}
+
+	if err := store.Revise(ctx, "alice", "missing", berlin, nil, RelationSupersedes); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for an unknown ID, got %v", err)
+	}
+}
+
+func TestFileStoreLegacyIDs(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	legacy := []MemoryItem{{Memory: Memory{Text: "Has a cat"}, Vector: []float32{1, 0}, Timestamp: 1700000000}}
+	if err := store.save("alice", legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	first, _ := store.load("alice")
+	again, _ := store.load("alice")
+	if first[0].ID == "" || first[0].ID != again[0].ID {
+		t.Errorf("expected memories without an ID to get a stable one, got %q and %q", first[0].ID, again[0].ID)
+	}
+}
+
+func TestFileStoreListDelete(t *testing.T) {
+	store := NewFileStore(t.TempDir())
+	ctx := context.Background()
+
+	legacy := []MemoryItem{
+		{Memory: Memory{Text: "Has a cat"}, Vector: []float32{1, 0}, Timestamp: 100},
+		{ID: "b", Memory: Memory{Text: "Lives in Berlin"}, Vector: []float32{0, 1}, Timestamp: 300},
+		{ID: "c", Memory: Memory{Text: "Plays the piano"}, Vector: []float32{1, 1}, Timestamp: 200},
+	}
+	if err := store.save("alice", legacy); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := store.List(ctx, "alice")
+	if err != nil || len(items) != 3 {
+		t.Fatalf("List() = %+v, %v", items, err)
+	}
+	if items[0].ID != "b" || items[1].ID != "c" || items[2].ID == "" || items[0].Vector != nil {
+		t.Errorf("expected the memories newest first, with IDs and without vectors, got %+v", items)
+	}
+
+	// Memories from before IDs can be deleted by the ID they are listed with
+	if err := store.Delete(ctx, "alice", items[2].ID); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := store.Delete(ctx, "alice", "b"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if items, _ := store.List(ctx, "alice"); len(items) != 1 || items[0].ID != "c" {
+		t.Errorf("expected only the other memory to be left, got %+v", items)
+	}
+	if err := store.Delete(ctx, "alice", "b"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a deleted memory, got %v", err)
+	}
+	if err := store.Delete(ctx, "bob", "c"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound for another user's memory, got %v", err)
+	}
+}
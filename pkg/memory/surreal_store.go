@@ -1,20 +1,30 @@
 package memory
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"ninoai/pkg/surreal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// defaultMemoriesTable holds the memories until they are first re-embedded
+const defaultMemoriesTable = "memories"
+
 type SurrealStore struct {
-	client *surreal.Client
+	client  *surreal.Client
+	table   string // Table holding the memories, switched by Reembed
+	ranking Ranking
 }
 
 type SurrealMemoryItem struct {
-	ID        string    `json:"id,omitempty"`
-	UserID    string    `json:"user_id"`
-	Text      string    `json:"text"`
+	ID     string `json:"id,omitempty"`
+	UserID string `json:"user_id"`
+	Memory
 	Embedding []float32 `json:"vector"`
 	Timestamp int64     `json:"timestamp"`
 }
@@ -27,155 +37,414 @@ type RecentMessageItem struct {
 }
 
 func NewSurrealStore(client *surreal.Client) *SurrealStore {
-	store := &SurrealStore{
-		client: client,
-	}
-	if err := store.Init(); err != nil {
-		// Log error but don't fail startup, as DB might be reachable later or schema exists
-		// In production, you might want to handle this more strictly
-		fmt.Printf("Warning: Failed to initialize SurrealDB schema: %v\n", err)
+	return &SurrealStore{
+		client:  client,
+		table:   defaultMemoriesTable,
+		ranking: DefaultRanking(),
 	}
-	return store
 }
 
-func (s *SurrealStore) Init() error {
-	// Define schema for memories
-	// We use a transaction-like block or just sequential queries
-	query := `
-		DEFINE TABLE IF NOT EXISTS memories SCHEMAFULL;
-		DEFINE FIELD IF NOT EXISTS user_id ON memories TYPE string;
-		DEFINE FIELD IF NOT EXISTS text ON memories TYPE string;
-		DEFINE FIELD IF NOT EXISTS timestamp ON memories TYPE int;
-		-- We define the vector field with 2048 dimensions
-		DEFINE FIELD IF NOT EXISTS vector ON memories TYPE array<float> ASSERT array::len($value) == 2048;
-		DEFINE INDEX IF NOT EXISTS vector_idx ON memories FIELDS vector MTREE DIMENSION 2048 DIST COSINE;
+// SetRanking changes how SearchMemories ranks memories.
+func (s *SurrealStore) SetRanking(r Ranking) {
+	s.ranking = r
+}
+
+var (
+	vectorAssertPattern   = regexp.MustCompile(`array::len\(\$value\)\s*==\s*(\d+)`)
+	indexDimensionPattern = regexp.MustCompile(`DIMENSION\s+(\d+)`)
+)
+
+// DimensionMismatchError reports that the memories table was defined for
+// vectors of a different length than the embedding model produces, which
+// would make every insert fail.
+type DimensionMismatchError struct {
+	Schema    int // Length the memories table requires
+	Embedding int // Length the embedding model produces
+}
+
+func (e *DimensionMismatchError) Error() string {
+	return fmt.Sprintf("memories table holds %d-dimensional vectors but the embedding model produces %d: "+
+		"run `ninoai reembed` to re-embed the stored memories with the new model, or switch back to a %d-dimensional model, "+
+		"or point the bot at a fresh database", e.Schema, e.Embedding, e.Schema)
+}
+
+// Init defines the schema, with vectors of the given length. If the memories
+// table already exists for another length it returns a
+// *DimensionMismatchError and leaves the schema alone.
+func (s *SurrealStore) Init(ctx context.Context, dimensions int) error {
+	existing, err := s.SchemaDimensions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read memories schema: %w", err)
+	}
+	if existing != 0 && existing != dimensions {
+		return &DimensionMismatchError{Schema: existing, Embedding: dimensions}
+	}
 
+	if err := s.defineMemories(ctx, s.table, dimensions); err != nil {
+		return err
+	}
+	query := `
 		DEFINE TABLE IF NOT EXISTS recent_messages SCHEMAFULL;
 		DEFINE FIELD IF NOT EXISTS user_id ON recent_messages TYPE string;
 		DEFINE FIELD IF NOT EXISTS text ON recent_messages TYPE string;
 		DEFINE FIELD IF NOT EXISTS timestamp ON recent_messages TYPE int;
+		DEFINE TABLE IF NOT EXISTS profiles SCHEMALESS;
 	`
-	_, err := s.client.Query(query, map[string]interface{}{})
+	_, err = s.client.Query(ctx, query, map[string]interface{}{})
 	return err
 }
 
-func (s *SurrealStore) detectDuplicate(userId string, vector []float32, threshold float64) (bool, float64, string, error) {
-	rows, err := s.client.VectorSearch("memories", "vector", vector, 1, map[string]interface{}{
-		"user_id": userId,
-	})
+// defineMemories defines a table of memories with vectors of the given length.
+// Fields added after the first release are optional, as older memories lack
+// them.
+func (s *SurrealStore) defineMemories(ctx context.Context, table string, dimensions int) error {
+	query := fmt.Sprintf(`
+		DEFINE TABLE IF NOT EXISTS %[1]s SCHEMAFULL;
+		DEFINE FIELD IF NOT EXISTS user_id ON %[1]s TYPE string;
+		DEFINE FIELD IF NOT EXISTS text ON %[1]s TYPE string;
+		DEFINE FIELD IF NOT EXISTS timestamp ON %[1]s TYPE int;
+		DEFINE FIELD IF NOT EXISTS category ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS ⟨key⟩ ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS ⟨value⟩ ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS source_message_id ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS source_channel_id ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS confidence ON %[1]s TYPE option<float>;
+		DEFINE FIELD IF NOT EXISTS history ON %[1]s TYPE option<array<object>>;
+		DEFINE FIELD IF NOT EXISTS history[*].text ON %[1]s TYPE string;
+		DEFINE FIELD IF NOT EXISTS history[*].category ON %[1]s TYPE option<string>;
+		DEFINE FIELD IF NOT EXISTS history[*].timestamp ON %[1]s TYPE int;
+		DEFINE FIELD IF NOT EXISTS history[*].relation ON %[1]s TYPE string;
+		DEFINE FIELD IF NOT EXISTS history[*].replaced_at ON %[1]s TYPE int;
+		DEFINE FIELD IF NOT EXISTS vector ON %[1]s TYPE array<float> ASSERT array::len($value) == %[2]d;
+		DEFINE INDEX IF NOT EXISTS vector_idx ON %[1]s FIELDS vector MTREE DIMENSION %[2]d DIST COSINE;
+	`, table, dimensions)
+	_, err := s.client.Query(ctx, query, map[string]interface{}{})
+	return err
+}
+
+// loadTable looks up which table holds the memories, as Reembed moves them
+// to a new one.
+func (s *SurrealStore) loadTable(ctx context.Context) error {
+	result, err := s.client.QueryFirst(ctx, "SELECT VALUE table FROM store_meta:memories;", map[string]interface{}{})
 	if err != nil {
-		return false, 0, "", err
+		return err
 	}
+	if tables, ok := result.([]interface{}); ok && len(tables) > 0 {
+		if table, ok := tables[0].(string); ok && table != "" {
+			s.table = table
+		}
+	}
+	return nil
+}
 
-	if len(rows) == 0 {
-		return false, 0, "", nil
+// SchemaDimensions returns the vector length the memories table was defined
+// with, or 0 if it has not been defined yet.
+func (s *SurrealStore) SchemaDimensions(ctx context.Context) (int, error) {
+	if err := s.loadTable(ctx); err != nil {
+		return 0, err
 	}
+	return s.tableDimensions(ctx, s.table)
+}
 
-	rowMap, ok := rows[0].(map[string]interface{})
-	if !ok {
-		return false, 0, "", fmt.Errorf("unexpected row format")
+func (s *SurrealStore) tableDimensions(ctx context.Context, table string) (int, error) {
+	dbInfo, err := s.client.QueryFirst(ctx, "INFO FOR DB;", map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	tables := infoSection(dbInfo, "tables")
+	if _, ok := tables[table]; !ok {
+		return 0, nil
 	}
 
-	// Extract similarity
-	var simScore float64
-	switch v := rowMap["similarity"].(type) {
-	case float64:
-		simScore = v
-	case float32:
-		simScore = float64(v)
+	tableInfo, err := s.client.QueryFirst(ctx, fmt.Sprintf("INFO FOR TABLE %s;", table), map[string]interface{}{})
+	if err != nil {
+		return 0, err
 	}
+	return parseSchemaDimensions(tableInfo)
+}
+
+// parseSchemaDimensions reads the vector length from the definitions of the
+// vector field and its index in the output of INFO FOR TABLE.
+func parseSchemaDimensions(tableInfo interface{}) (int, error) {
+	fields := infoSection(tableInfo, "fields")
+	indexes := infoSection(tableInfo, "indexes")
+
+	fieldDims := matchDimensions(vectorAssertPattern, fields["vector"])
+	indexDims := matchDimensions(indexDimensionPattern, indexes["vector_idx"])
+	if fieldDims != 0 && indexDims != 0 && fieldDims != indexDims {
+		return 0, fmt.Errorf("memories table is inconsistent: the vector field requires %d dimensions but vector_idx has %d", fieldDims, indexDims)
+	}
+	return max(fieldDims, indexDims), nil
+}
 
-	// Extract existing text
-	existingText, _ := rowMap["text"].(string)
+// infoSection returns one section of the output of an INFO statement, or nil.
+func infoSection(info interface{}, name string) map[string]interface{} {
+	infoMap, _ := info.(map[string]interface{})
+	section, _ := infoMap[name].(map[string]interface{})
+	return section
+}
 
-	if simScore >= threshold {
-		return true, simScore, existingText, nil
+func matchDimensions(pattern *regexp.Regexp, definition interface{}) int {
+	text, _ := definition.(string)
+	match := pattern.FindStringSubmatch(text)
+	if match == nil {
+		return 0
 	}
+	n, _ := strconv.Atoi(match[1])
+	return n
+}
+
+// similarMemories returns up to limit of the user's memories, most similar
+// to vector first, without their vectors.
+func (s *SurrealStore) similarMemories(ctx context.Context, userId string, vector []float32, limit int) ([]candidate, error) {
+	query := fmt.Sprintf(`
+		SELECT record::id(id) AS record_key, text, category, ⟨key⟩, ⟨value⟩,
+			source_message_id, source_channel_id, confidence, timestamp,
+			vector::similarity::cosine(vector, $vector) AS similarity
+		FROM %s
+		WHERE user_id = $user_id
+		ORDER BY similarity DESC
+		LIMIT %d;
+	`, s.table, limit)
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{
+		"user_id": userId,
+		"vector":  vector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	rows, _ := result.([]interface{})
+	candidates := make([]candidate, 0, len(rows))
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected row format")
+		}
+		var c candidate
+		c.ID, _ = row["record_key"].(string)
+		c.Memory = memoryFromRow(row)
+		c.Timestamp = int64(toInt(row["timestamp"]))
+		c.Similarity = toFloat(row["similarity"])
+		candidates = append(candidates, c)
+	}
+	return candidates, nil
+}
 
-	return false, simScore, existingText, nil
+// memoryFromRow reads the fields of a Memory from a query result row.
+func memoryFromRow(row map[string]interface{}) Memory {
+	var m Memory
+	m.Text, _ = row["text"].(string)
+	m.Category, _ = row["category"].(string)
+	m.Key, _ = row["key"].(string)
+	m.Value, _ = row["value"].(string)
+	m.SourceMessageID, _ = row["source_message_id"].(string)
+	m.SourceChannelID, _ = row["source_channel_id"].(string)
+	m.Confidence = toFloat(row["confidence"])
+	return m
 }
 
-func (s *SurrealStore) Add(userId string, text string, vector []float32) error {
+func toFloat(v interface{}) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case float32:
+		return float64(n)
+	}
+	return float64(toInt(v))
+}
+
+func (s *SurrealStore) Add(ctx context.Context, userId string, m Memory, vector []float32) error {
 	const duplicateThreshold = 0.8
 
-	isDup, sim, existingText, err := s.detectDuplicate(userId, vector, duplicateThreshold)
+	closest, err := s.similarMemories(ctx, userId, vector, 1)
 	if err != nil {
 		log.Printf("[DEBUG] Error checking for duplicates: %v", err)
-	} else if isDup {
-		return fmt.Errorf(
-			"duplicate memory detected (similarity: %.4f): existing='%s', new='%s'",
-			sim, existingText, text,
-		)
+	} else if len(closest) > 0 && closest[0].Similarity >= duplicateThreshold {
+		c := closest[0]
+		return &DuplicateError{ID: c.ID, Existing: c.Memory, New: m, Similarity: c.Similarity}
 	}
 
 	item := SurrealMemoryItem{
 		UserID:    userId,
-		Text:      text,
+		Memory:    m,
 		Embedding: vector,
 		Timestamp: time.Now().Unix(),
 	}
 
-	_, err = s.client.Create("memories", item)
+	_, err = s.client.Create(ctx, s.table, item)
 	return err
 }
 
-func (s *SurrealStore) Search(userId string, queryVector []float32, limit int) ([]string, error) {
-	log.Printf("[DEBUG] Search called: userId=%s, vectorLen=%d, limit=%d", userId, len(queryVector), limit)
-
-	// Use the client's VectorSearch method to avoid raw queries in the store
-	rows, err := s.client.VectorSearch("memories", "vector", queryVector, limit, map[string]interface{}{
-		"user_id": userId,
+func (s *SurrealStore) Revise(ctx context.Context, userId, id string, m Memory, vector []float32, relation string) error {
+	// The history is appended first, while the fields still hold the old version
+	query := fmt.Sprintf(`
+		UPDATE type::thing('%s', $id) SET
+			history = array::append(history ?? [], {
+				text: text,
+				category: category,
+				timestamp: timestamp,
+				relation: $relation,
+				replaced_at: $now
+			}),
+			text = $memory.text,
+			category = $memory.category,
+			⟨key⟩ = $memory.key,
+			⟨value⟩ = $memory.value,
+			source_message_id = $memory.source_message_id,
+			source_channel_id = $memory.source_channel_id,
+			confidence = $memory.confidence,
+			vector = $vector,
+			timestamp = $now
+		WHERE user_id = $user_id;
+	`, s.table)
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{
+		"id":       id,
+		"user_id":  userId,
+		"memory":   m,
+		"vector":   vector,
+		"relation": relation,
+		"now":      time.Now().Unix(),
 	})
 	if err != nil {
-		log.Printf("[DEBUG] VectorSearch error: %v", err)
+		return err
+	}
+	if rows, _ := result.([]interface{}); len(rows) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// searchCandidates caps the memories SearchMemories ranks, the most similar ones.
+// Users rarely have more, as close facts are merged.
+const searchCandidates = 500
+
+func (s *SurrealStore) SearchMemories(ctx context.Context, userId, query string, queryVector []float32, limit int) ([]SearchResult, error) {
+	candidates, err := s.similarMemories(ctx, userId, queryVector, searchCandidates)
+	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("[DEBUG] VectorSearch returned %d rows", len(rows))
+	var results []SearchResult
+	for _, c := range s.ranking.rank(query, candidates, time.Now(), limit) {
+		results = append(results, c.result())
+	}
+	return results, nil
+}
 
-	const similarityThreshold = 0.6 // Only include memories with good similarity
-	var texts []string
+func (s *SurrealStore) List(ctx context.Context, userId string) ([]MemoryItem, error) {
+	query := fmt.Sprintf(`
+		SELECT record::id(id) AS record_key, text, category, ⟨key⟩, ⟨value⟩,
+			source_message_id, source_channel_id, confidence, timestamp
+		FROM %s
+		WHERE user_id = $user_id
+		ORDER BY timestamp DESC;
+	`, s.table)
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{"user_id": userId})
+	if err != nil {
+		return nil, err
+	}
 
-	for _, row := range rows {
-		if rowMap, ok := row.(map[string]interface{}); ok {
-			if text, ok := rowMap["text"].(string); ok {
-				similarity := rowMap["similarity"]
-
-				// Check if similarity meets threshold
-				var simScore float64
-				switch v := similarity.(type) {
-				case float64:
-					simScore = v
-				case float32:
-					simScore = float64(v)
-				default:
-					log.Printf("Unknown similarity type: %T", similarity)
-					continue
-				}
-
-				if simScore >= similarityThreshold {
-					log.Printf("Memory match: '%s' (similarity: %.4f)", text, simScore)
-					texts = append(texts, text)
-				} else {
-					log.Printf("Skipping low-similarity memory: '%s' (similarity: %.4f)", text, simScore)
-				}
+	rows, _ := result.([]interface{})
+	items := make([]MemoryItem, 0, len(rows))
+	for _, r := range rows {
+		row, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected row format")
+		}
+		item := MemoryItem{Memory: memoryFromRow(row), Timestamp: int64(toInt(row["timestamp"]))}
+		item.ID, _ = row["record_key"].(string)
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *SurrealStore) Delete(ctx context.Context, userId, id string) error {
+	query := fmt.Sprintf(`DELETE type::thing('%s', $id) WHERE user_id = $user_id RETURN BEFORE;`, s.table)
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{"id": id, "user_id": userId})
+	if err != nil {
+		return err
+	}
+	if rows, _ := result.([]interface{}); len(rows) == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Profiles
+
+// GetProfile returns the user's profile, which is empty until a keyed fact
+// is stored.
+func (s *SurrealStore) GetProfile(ctx context.Context, userId string) (Profile, error) {
+	query := `SELECT fields, updated_at FROM type::thing('profiles', $user_id);`
+	result, err := s.client.QueryFirst(ctx, query, map[string]interface{}{"user_id": userId})
+	if err != nil {
+		return Profile{}, err
+	}
+
+	var profile Profile
+	rows, _ := result.([]interface{})
+	if len(rows) == 0 {
+		return profile, nil
+	}
+	row, _ := rows[0].(map[string]interface{})
+	if fields, ok := row["fields"].(map[string]interface{}); ok {
+		profile.Fields = make(map[string]string, len(fields))
+		for key, value := range fields {
+			if text, ok := value.(string); ok {
+				profile.Fields[key] = text
 			}
 		}
 	}
+	profile.UpdatedAt = int64(toInt(row["updated_at"]))
+	return profile, nil
+}
+
+// UpdateProfile sets the given fields of the user's profile, keeping the
+// others. Fields given an empty value are removed.
+func (s *SurrealStore) UpdateProfile(ctx context.Context, userId string, fields map[string]string) error {
+	set := make(map[string]string, len(fields))
+	var removed []string
+	for key, value := range fields {
+		if value == "" {
+			// Keys are normalized to letters, digits and underscores, so they
+			// are safe to escape
+			removed = append(removed, fmt.Sprintf("fields.⟨%s⟩ = NONE", NormalizeKey(key)))
+		} else {
+			set[key] = value
+		}
+	}
 
-	return texts, nil
+	// MERGE merges nested objects, so only the given fields change
+	query := `
+		UPSERT type::thing('profiles', $user_id) MERGE {
+			user_id: $user_id,
+			fields: $fields,
+			updated_at: $now
+		};
+	`
+	if len(removed) > 0 {
+		sort.Strings(removed)
+		query += fmt.Sprintf("UPDATE type::thing('profiles', $user_id) SET %s;", strings.Join(removed, ", "))
+	}
+	_, err := s.client.Query(ctx, query, map[string]interface{}{
+		"user_id": userId,
+		"fields":  set,
+		"now":     time.Now().Unix(),
+	})
+	return err
 }
 
 // Recent messages cache
 
-func (s *SurrealStore) AddRecentMessage(userId, message string) error {
+func (s *SurrealStore) AddRecentMessage(ctx context.Context, userId, message string) error {
 	item := RecentMessageItem{
 		UserID:    userId,
 		Text:      message,
 		Timestamp: time.Now().Unix(),
 	}
 
-	_, err := s.client.Create("recent_messages", item)
+	_, err := s.client.Create(ctx, "recent_messages", item)
 	if err != nil {
 		return err
 	}
@@ -192,11 +461,11 @@ func (s *SurrealStore) AddRecentMessage(userId, message string) error {
 			LIMIT 15
 		).id;
 	`
-	_, err = s.client.Query(query, map[string]interface{}{"user_id": userId})
+	_, err = s.client.Query(ctx, query, map[string]interface{}{"user_id": userId})
 	return err
 }
 
-func (s *SurrealStore) GetRecentMessages(userId string) ([]string, error) {
+func (s *SurrealStore) GetRecentMessages(ctx context.Context, userId string) ([]string, error) {
 	// Include 'timestamp' in SELECT since we're ordering by it
 	query := `
 		SELECT text, timestamp FROM recent_messages
@@ -204,7 +473,7 @@ func (s *SurrealStore) GetRecentMessages(userId string) ([]string, error) {
 		ORDER BY timestamp ASC;
 	`
 
-	result, err := s.client.Query(query, map[string]interface{}{"user_id": userId})
+	result, err := s.client.Query(ctx, query, map[string]interface{}{"user_id": userId})
 	if err != nil {
 		return nil, err
 	}
@@ -238,17 +507,18 @@ func (s *SurrealStore) GetRecentMessages(userId string) ([]string, error) {
 	return messages, nil
 }
 
-func (s *SurrealStore) ClearRecentMessages(userId string) error {
+func (s *SurrealStore) ClearRecentMessages(ctx context.Context, userId string) error {
 	query := `DELETE recent_messages WHERE user_id = $user_id;`
-	_, err := s.client.Query(query, map[string]interface{}{"user_id": userId})
+	_, err := s.client.Query(ctx, query, map[string]interface{}{"user_id": userId})
 	return err
 }
 
-func (s *SurrealStore) DeleteUserData(userId string) error {
-	query := `
-		DELETE memories WHERE user_id = $user_id;
+func (s *SurrealStore) DeleteUserData(ctx context.Context, userId string) error {
+	query := fmt.Sprintf(`
+		DELETE %s WHERE user_id = $user_id;
 		DELETE recent_messages WHERE user_id = $user_id;
-	`
-	_, err := s.client.Query(query, map[string]interface{}{"user_id": userId})
+		DELETE type::thing('profiles', $user_id);
+	`, s.table)
+	_, err := s.client.Query(ctx, query, map[string]interface{}{"user_id": userId})
 	return err
 }
@@ -0,0 +1,51 @@
+package memory
+
+import "testing"
+
+func TestParseSchemaDimensions(t *testing.T) {
+	tableInfo := func(field, index string) interface{} {
+		return map[string]interface{}{
+			"fields": map[string]interface{}{
+				"text":   "DEFINE FIELD text ON memories TYPE string PERMISSIONS FULL",
+				"vector": field,
+			},
+			"indexes": map[string]interface{}{
+				"vector_idx": index,
+			},
+		}
+	}
+	const (
+		field2048 = "DEFINE FIELD vector ON memories TYPE array<float> ASSERT array::len($value) == 2048 PERMISSIONS FULL"
+		index2048 = "DEFINE INDEX vector_idx ON memories FIELDS vector MTREE DIMENSION 2048 DIST COSINE TYPE F64 CAPACITY 40"
+		index768  = "DEFINE INDEX vector_idx ON memories FIELDS vector MTREE DIMENSION 768 DIST COSINE TYPE F64 CAPACITY 40"
+	)
+
+	tests := []struct {
+		name    string
+		info    interface{}
+		want    int
+		wantErr bool
+	}{
+		{name: "Field and index", info: tableInfo(field2048, index2048), want: 2048},
+		{name: "Field only", info: tableInfo(field2048, ""), want: 2048},
+		{name: "Index only", info: tableInfo("", index768), want: 768},
+		{name: "No vector schema", info: map[string]interface{}{}, want: 0},
+		{name: "Unexpected format", info: nil, want: 0},
+		{name: "Field and index disagree", info: tableInfo(field2048, index768), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSchemaDimensions(tt.info)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %d", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("expected %d, got %d, %v", tt.want, got, err)
+			}
+		})
+	}
+}
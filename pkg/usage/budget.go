@@ -0,0 +1,112 @@
+package usage
+
+import (
+	"fmt"
+	"time"
+)
+
+// Scopes a budget applies to
+const (
+	ScopeGlobal = "global"
+	ScopeGuild  = "guild"
+	ScopeUser   = "user"
+)
+
+// Windows a budget is counted over. Both reset on the UTC calendar boundary.
+const (
+	WindowDaily  = "daily"
+	WindowHourly = "hourly"
+)
+
+// Limits caps the tokens spent per window; 0 means unlimited.
+type Limits struct {
+	Daily  int
+	Hourly int
+}
+
+// Budget holds the token limits for the whole bot, for each guild and for
+// each user. A user's budget covers their usage across all guilds and DMs.
+type Budget struct {
+	Global Limits
+	Guild  Limits
+	User   Limits
+}
+
+// BudgetError says which budget a call would exceed and when it resets.
+type BudgetError struct {
+	Scope   string
+	Window  string
+	Limit   int
+	Spent   int
+	ResetAt time.Time
+}
+
+func (e *BudgetError) Error() string {
+	return fmt.Sprintf("%s %s budget exhausted (%d of %d tokens) until %s",
+		e.Scope, e.Window, e.Spent, e.Limit, e.ResetAt.Format(time.RFC3339))
+}
+
+// CheckBudget returns a *BudgetError if userID, writing in guildID, has used
+// up any budget that applies to them, or nil if a new call may be made. The
+// user's own budget is checked first, then the guild's, then the global one.
+func (t *Tracker) CheckBudget(b Budget, userID, guildID string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().UTC()
+	today := now.Format(dateLayout)
+	hour := now.Format(hourLayout)
+
+	var spent [3][2]int // scope (user, guild, global) x window (daily, hourly)
+	for _, e := range t.entries {
+		if e.Date != today {
+			continue
+		}
+		spent[2][0] += e.Tokens()
+		if guildID != "" && e.GuildID == guildID {
+			spent[1][0] += e.Tokens()
+		}
+		if e.UserID == userID {
+			spent[0][0] += e.Tokens()
+		}
+	}
+	for _, h := range t.hours {
+		if h.Hour != hour {
+			continue
+		}
+		spent[2][1] += h.Tokens()
+		if guildID != "" && h.GuildID == guildID {
+			spent[1][1] += h.Tokens()
+		}
+		if h.UserID == userID {
+			spent[0][1] += h.Tokens()
+		}
+	}
+
+	nextDay := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	nextHour := now.Truncate(time.Hour).Add(time.Hour)
+
+	scopes := []struct {
+		name   string
+		limits Limits
+		skip   bool
+	}{
+		{ScopeUser, b.User, false},
+		{ScopeGuild, b.Guild, guildID == ""},
+		{ScopeGlobal, b.Global, false},
+	}
+	for i, scope := range scopes {
+		if scope.skip {
+			continue
+		}
+		// The daily window is checked first as it is the later of the two resets
+		if scope.limits.Daily > 0 && spent[i][0] >= scope.limits.Daily {
+			return &BudgetError{Scope: scope.name, Window: WindowDaily, Limit: scope.limits.Daily, Spent: spent[i][0], ResetAt: nextDay}
+		}
+		if scope.limits.Hourly > 0 && spent[i][1] >= scope.limits.Hourly {
+			return &BudgetError{Scope: scope.name, Window: WindowHourly, Limit: scope.limits.Hourly, Spent: spent[i][1], ResetAt: nextHour}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package usage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTracker_CheckBudget(t *testing.T) {
+	now := time.Date(2025, 3, 14, 12, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		budget     Budget
+		userID     string
+		guildID    string
+		wantScope  string
+		wantWindow string
+	}{
+		{name: "Unlimited", budget: Budget{}, userID: "alice", guildID: "g1"},
+		{name: "Under user limit", budget: Budget{User: Limits{Daily: 1000}}, userID: "alice", guildID: "g1"},
+		{name: "User daily", budget: Budget{User: Limits{Daily: 300}}, userID: "alice", guildID: "g1", wantScope: ScopeUser, wantWindow: WindowDaily},
+		{name: "User hourly", budget: Budget{User: Limits{Hourly: 100}}, userID: "alice", guildID: "g1", wantScope: ScopeUser, wantWindow: WindowHourly},
+		{name: "Other user", budget: Budget{User: Limits{Daily: 300}}, userID: "bob", guildID: "g1"},
+		{name: "Guild daily", budget: Budget{Guild: Limits{Daily: 400}}, userID: "bob", guildID: "g1", wantScope: ScopeGuild, wantWindow: WindowDaily},
+		{name: "Other guild", budget: Budget{Guild: Limits{Daily: 400}}, userID: "bob", guildID: "g2"},
+		{name: "Guild limit skipped in DMs", budget: Budget{Guild: Limits{Daily: 1}}, userID: "bob"},
+		{name: "Global hourly", budget: Budget{Global: Limits{Hourly: 200}}, userID: "carol", guildID: "g2", wantScope: ScopeGlobal, wantWindow: WindowHourly},
+		{name: "User before global", budget: Budget{User: Limits{Daily: 300}, Global: Limits{Daily: 1}}, userID: "alice", guildID: "g1", wantScope: ScopeUser, wantWindow: WindowDaily},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := newTestTracker(t, now.Add(-2*time.Hour))
+			record(tracker, "alice", "g1", KindReply, "m", 200, 0) // Earlier today
+			tracker.now = func() time.Time { return now }
+			record(tracker, "alice", "g1", KindReply, "m", 100, 50)
+			record(tracker, "bob", "g1", KindReply, "m", 90, 10)
+
+			err := tracker.CheckBudget(tt.budget, tt.userID, tt.guildID)
+			if tt.wantScope == "" {
+				if err != nil {
+					t.Fatalf("CheckBudget() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var budgetErr *BudgetError
+			if !errors.As(err, &budgetErr) {
+				t.Fatalf("CheckBudget() error = %v, want a *BudgetError", err)
+			}
+			if budgetErr.Scope != tt.wantScope || budgetErr.Window != tt.wantWindow {
+				t.Errorf("CheckBudget() = %s %s, want %s %s", budgetErr.Scope, budgetErr.Window, tt.wantScope, tt.wantWindow)
+			}
+		})
+	}
+}
+
+func TestTracker_BudgetResets(t *testing.T) {
+	now := time.Date(2025, 3, 14, 23, 30, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "usage.json")
+	budget := Budget{User: Limits{Daily: 100, Hourly: 100}}
+
+	tracker := NewTracker(path)
+	tracker.now = func() time.Time { return now }
+	record(tracker, "alice", "", KindReply, "m", 100, 0)
+	tracker.Flush()
+
+	// Budgets survive a restart
+	reloaded := NewTracker(path)
+	reloaded.now = func() time.Time { return now }
+	var budgetErr *BudgetError
+	if err := reloaded.CheckBudget(budget, "alice", ""); !errors.As(err, &budgetErr) {
+		t.Fatalf("expected the budget to survive a restart, got %v", err)
+	}
+	if want := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC); !budgetErr.ResetAt.Equal(want) {
+		t.Errorf("ResetAt = %s, want %s", budgetErr.ResetAt, want)
+	}
+
+	// ...and reset at midnight UTC
+	reloaded.now = func() time.Time { return now.Add(time.Hour) }
+	if err := reloaded.CheckBudget(budget, "alice", ""); err != nil {
+		t.Errorf("expected the budget to reset, got %v", err)
+	}
+}
@@ -0,0 +1,373 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"ninoai/pkg/cerebras"
+)
+
+// Kinds of LLM calls the bot makes
+const (
+	KindReply            = "reply"
+	KindTaskRefusal      = "task_refusal"
+	KindEmojiFilter      = "emoji_filter"
+	KindMemoryExtraction = "memory_extraction"
+	KindMemoryComparison = "memory_comparison"
+	KindClassification   = "classification"
+)
+
+// retentionDays is how long daily aggregates are kept
+const retentionDays = 90
+
+// saveDelay is how long after a recorded call the tracker writes to disk, so
+// that a burst of calls is saved at once
+const saveDelay = 5 * time.Second
+
+const (
+	dateLayout = "2006-01-02"
+	hourLayout = "2006-01-02T15"
+)
+
+// Attribution says on whose behalf an LLM call is made.
+type Attribution struct {
+	UserID  string
+	GuildID string // Empty in DMs
+	Kind    string
+}
+
+type attributionKey struct{}
+
+// WithAttribution returns a context whose LLM calls are attributed to a.
+func WithAttribution(ctx context.Context, a Attribution) context.Context {
+	return context.WithValue(ctx, attributionKey{}, a)
+}
+
+// WithKind returns a context with the same attribution as ctx but a different kind of call.
+func WithKind(ctx context.Context, kind string) context.Context {
+	a := AttributionFrom(ctx)
+	a.Kind = kind
+	return WithAttribution(ctx, a)
+}
+
+// AttributionFrom returns the attribution attached to ctx, if any.
+func AttributionFrom(ctx context.Context) Attribution {
+	a, _ := ctx.Value(attributionKey{}).(Attribution)
+	return a
+}
+
+// Totals are the summed token counts of a number of calls.
+type Totals struct {
+	Calls            int `json:"calls"`
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// Tokens returns the total number of tokens spent.
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+func (t *Totals) add(o Totals) {
+	t.Calls += o.Calls
+	t.PromptTokens += o.PromptTokens
+	t.CompletionTokens += o.CompletionTokens
+}
+
+// Entry is the daily aggregate for one user, guild, model and kind of call.
+type Entry struct {
+	Date    string `json:"date"` // UTC, YYYY-MM-DD
+	UserID  string `json:"user_id"`
+	GuildID string `json:"guild_id,omitempty"`
+	Model   string `json:"model"`
+	Kind    string `json:"kind"`
+	Totals
+}
+
+type entryKey struct {
+	date, userID, guildID, model, kind string
+}
+
+// HourEntry is the usage of one user in one guild during an hour. Only the
+// current hour is kept, for hourly budgets.
+type HourEntry struct {
+	Hour    string `json:"hour"` // UTC, YYYY-MM-DDTHH
+	UserID  string `json:"user_id"`
+	GuildID string `json:"guild_id,omitempty"`
+	Totals
+}
+
+type hourKey struct {
+	hour, userID, guildID string
+}
+
+// usageFile is the on-disk format of a tracker
+type usageFile struct {
+	Daily  []*Entry     `json:"daily"`
+	Hourly []*HourEntry `json:"hourly"`
+}
+
+// Tracker aggregates token usage per day and persists it to a JSON file,
+// saveDelay after the first call recorded since the last save. It implements
+// cerebras.UsageRecorder.
+type Tracker struct {
+	mu        sync.Mutex
+	path      string
+	entries   map[entryKey]*Entry
+	hours     map[hourKey]*HourEntry
+	now       func() time.Time
+	saveTimer *time.Timer // Pending save, nil if none
+	saveMu    sync.Mutex  // Serializes writes to path
+}
+
+// NewTracker creates a tracker backed by the file at path, loading any usage
+// recorded by earlier runs.
+func NewTracker(path string) *Tracker {
+	t := &Tracker{
+		path:    path,
+		entries: make(map[entryKey]*Entry),
+		hours:   make(map[hourKey]*HourEntry),
+		now:     time.Now,
+	}
+	t.load()
+	return t
+}
+
+// RecordUsage adds a completion's usage to today's aggregate for the
+// attribution found in ctx.
+func (t *Tracker) RecordUsage(ctx context.Context, model string, u cerebras.Usage) {
+	a := AttributionFrom(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now().UTC()
+	totals := Totals{Calls: 1, PromptTokens: u.PromptTokens, CompletionTokens: u.CompletionTokens}
+
+	date := now.Format(dateLayout)
+	key := entryKey{date, a.UserID, a.GuildID, model, a.Kind}
+	e, ok := t.entries[key]
+	if !ok {
+		e = &Entry{Date: date, UserID: a.UserID, GuildID: a.GuildID, Model: model, Kind: a.Kind}
+		t.entries[key] = e
+	}
+	e.add(totals)
+
+	hour := now.Format(hourLayout)
+	hk := hourKey{hour, a.UserID, a.GuildID}
+	h, ok := t.hours[hk]
+	if !ok {
+		h = &HourEntry{Hour: hour, UserID: a.UserID, GuildID: a.GuildID}
+		t.hours[hk] = h
+	}
+	h.add(totals)
+
+	t.prune()
+	if t.saveTimer == nil {
+		t.saveTimer = time.AfterFunc(saveDelay, t.Flush)
+	}
+}
+
+// Flush saves any usage recorded since the last save right away. Call it
+// before exiting so that no usage is lost.
+func (t *Tracker) Flush() {
+	t.saveMu.Lock()
+	defer t.saveMu.Unlock()
+
+	t.mu.Lock()
+	if t.saveTimer == nil {
+		t.mu.Unlock()
+		return
+	}
+	t.saveTimer.Stop()
+	t.saveTimer = nil
+	data, err := t.marshal()
+	t.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Error marshaling usage: %v", err)
+		return
+	}
+	if err := t.save(data); err != nil {
+		log.Printf("Error saving usage: %v", err)
+	}
+}
+
+// Ranked is the usage of one user, guild, model or kind.
+type Ranked struct {
+	Key string
+	Totals
+}
+
+// Summary is the usage over a range of days, broken down several ways. Each
+// breakdown is sorted by tokens spent, highest first.
+type Summary struct {
+	Total   Totals
+	ByUser  []Ranked
+	ByGuild []Ranked // DMs are listed under an empty key
+	ByModel []Ranked
+	ByKind  []Ranked
+}
+
+// Summary returns the usage of the last days days, including today.
+func (t *Tracker) Summary(days int) Summary {
+	return t.summary(days, func(*Entry) bool { return true })
+}
+
+// GuildSummary returns the usage of the last days days in one guild,
+// including today. Its ByGuild breakdown is left empty.
+func (t *Tracker) GuildSummary(days int, guildID string) Summary {
+	s := t.summary(days, func(e *Entry) bool { return e.GuildID == guildID })
+	s.ByGuild = nil
+	return s
+}
+
+func (t *Tracker) summary(days int, include func(*Entry) bool) Summary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	since := t.now().UTC().AddDate(0, 0, -(days - 1)).Format(dateLayout)
+	byUser := make(map[string]*Totals)
+	byGuild := make(map[string]*Totals)
+	byModel := make(map[string]*Totals)
+	byKind := make(map[string]*Totals)
+
+	var s Summary
+	for _, e := range t.entries {
+		if e.Date < since || !include(e) {
+			continue
+		}
+		s.Total.add(e.Totals)
+		addTo(byUser, e.UserID, e.Totals)
+		addTo(byGuild, e.GuildID, e.Totals)
+		addTo(byModel, e.Model, e.Totals)
+		addTo(byKind, e.Kind, e.Totals)
+	}
+
+	s.ByUser = rank(byUser)
+	s.ByGuild = rank(byGuild)
+	s.ByModel = rank(byModel)
+	s.ByKind = rank(byKind)
+	return s
+}
+
+func addTo(m map[string]*Totals, key string, totals Totals) {
+	if m[key] == nil {
+		m[key] = &Totals{}
+	}
+	m[key].add(totals)
+}
+
+func rank(m map[string]*Totals) []Ranked {
+	ranked := make([]Ranked, 0, len(m))
+	for key, totals := range m {
+		ranked = append(ranked, Ranked{Key: key, Totals: *totals})
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Tokens() != ranked[j].Tokens() {
+			return ranked[i].Tokens() > ranked[j].Tokens()
+		}
+		return ranked[i].Key < ranked[j].Key
+	})
+	return ranked
+}
+
+// prune drops daily aggregates older than the retention period and hourly
+// ones from before the current hour.
+func (t *Tracker) prune() {
+	now := t.now().UTC()
+	cutoff := now.AddDate(0, 0, -retentionDays).Format(dateLayout)
+	for key, e := range t.entries {
+		if e.Date < cutoff {
+			delete(t.entries, key)
+		}
+	}
+
+	hour := now.Format(hourLayout)
+	for key, h := range t.hours {
+		if h.Hour < hour {
+			delete(t.hours, key)
+		}
+	}
+}
+
+// load reads the aggregates from disk
+func (t *Tracker) load() {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Error loading usage: %v", err)
+		}
+		return
+	}
+
+	var file usageFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		log.Printf("Error unmarshaling usage: %v", err)
+		return
+	}
+
+	for _, e := range file.Daily {
+		t.entries[entryKey{e.Date, e.UserID, e.GuildID, e.Model, e.Kind}] = e
+	}
+	for _, h := range file.Hourly {
+		t.hours[hourKey{h.Hour, h.UserID, h.GuildID}] = h
+	}
+	log.Printf("Loaded %d usage aggregates", len(file.Daily))
+}
+
+// marshal encodes the aggregates in the on-disk format. The caller must hold t.mu.
+func (t *Tracker) marshal() ([]byte, error) {
+	entries := make([]*Entry, 0, len(t.entries))
+	for _, e := range t.entries {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.Date != b.Date {
+			return a.Date < b.Date
+		}
+		if a.UserID != b.UserID {
+			return a.UserID < b.UserID
+		}
+		if a.GuildID != b.GuildID {
+			return a.GuildID < b.GuildID
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Kind < b.Kind
+	})
+
+	hours := make([]*HourEntry, 0, len(t.hours))
+	for _, h := range t.hours {
+		hours = append(hours, h)
+	}
+	sort.Slice(hours, func(i, j int) bool {
+		if hours[i].UserID != hours[j].UserID {
+			return hours[i].UserID < hours[j].UserID
+		}
+		return hours[i].GuildID < hours[j].GuildID
+	})
+
+	return json.MarshalIndent(usageFile{Daily: entries, Hourly: hours}, "", "  ")
+}
+
+// save writes data to disk through a temporary file, so that a crash while
+// writing cannot leave a truncated file behind.
+func (t *Tracker) save(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return err
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
@@ -0,0 +1,119 @@
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ninoai/pkg/cerebras"
+)
+
+func newTestTracker(t *testing.T, now time.Time) *Tracker {
+	t.Helper()
+	tracker := NewTracker(filepath.Join(t.TempDir(), "usage.json"))
+	tracker.now = func() time.Time { return now }
+	return tracker
+}
+
+func record(tracker *Tracker, userID, guildID, kind, model string, prompt, completion int) {
+	ctx := WithAttribution(context.Background(), Attribution{UserID: userID, GuildID: guildID, Kind: kind})
+	tracker.RecordUsage(ctx, model, cerebras.Usage{PromptTokens: prompt, CompletionTokens: completion})
+}
+
+func TestTracker_Summary(t *testing.T) {
+	now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(t, now)
+
+	record(tracker, "alice", "g1", KindReply, "cerebras/a", 100, 10)
+	record(tracker, "alice", "g1", KindReply, "cerebras/a", 100, 10)
+	record(tracker, "bob", "", KindEmojiFilter, "cerebras/b", 500, 50)
+
+	// Yesterday's usage only counts towards multi-day summaries
+	tracker.now = func() time.Time { return now.AddDate(0, 0, -1) }
+	record(tracker, "carol", "g2", KindTaskRefusal, "cerebras/a", 1000, 0)
+	tracker.now = func() time.Time { return now }
+
+	today := tracker.Summary(1)
+	if today.Total != (Totals{Calls: 3, PromptTokens: 700, CompletionTokens: 70}) {
+		t.Errorf("unexpected total: %+v", today.Total)
+	}
+	if len(today.ByUser) != 2 || today.ByUser[0].Key != "bob" || today.ByUser[1].Calls != 2 {
+		t.Errorf("unexpected users: %+v", today.ByUser)
+	}
+	if len(today.ByModel) != 2 || today.ByModel[0].Key != "cerebras/b" {
+		t.Errorf("unexpected models: %+v", today.ByModel)
+	}
+
+	week := tracker.Summary(7)
+	if week.Total.Calls != 4 || week.ByUser[0].Key != "carol" || week.ByGuild[0].Key != "g2" {
+		t.Errorf("unexpected weekly summary: %+v", week)
+	}
+
+	guild := tracker.GuildSummary(7, "g1")
+	if guild.Total.Calls != 2 || len(guild.ByUser) != 1 || guild.ByUser[0].Key != "alice" || guild.ByGuild != nil {
+		t.Errorf("unexpected summary of g1: %+v", guild)
+	}
+}
+
+func TestTracker_Persists(t *testing.T) {
+	now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	path := filepath.Join(t.TempDir(), "usage.json")
+
+	tracker := NewTracker(path)
+	tracker.now = func() time.Time { return now }
+	record(tracker, "alice", "g1", KindReply, "cerebras/a", 100, 10)
+	tracker.Flush()
+
+	reloaded := NewTracker(path)
+	reloaded.now = func() time.Time { return now }
+	record(reloaded, "alice", "g1", KindReply, "cerebras/a", 100, 10)
+
+	if got := reloaded.Summary(1).Total; got != (Totals{Calls: 2, PromptTokens: 200, CompletionTokens: 20}) {
+		t.Errorf("expected usage to survive a restart, got %+v", got)
+	}
+}
+
+func TestTracker_DefersSaves(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage.json")
+	tracker := NewTracker(path)
+	record(tracker, "alice", "", KindReply, "m", 100, 10)
+	record(tracker, "bob", "", KindReply, "m", 100, 10)
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no write on every call, got %v", err)
+	}
+
+	tracker.Flush()
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("expected the temporary file to be renamed, got %v", err)
+	}
+	if got := NewTracker(path).Summary(1).Total.Calls; got != 2 {
+		t.Errorf("expected both calls to be saved, got %d", got)
+	}
+}
+
+func TestTracker_Prunes(t *testing.T) {
+	now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+	tracker := newTestTracker(t, now.AddDate(0, 0, -retentionDays-1))
+	record(tracker, "alice", "", KindReply, "m", 100, 10)
+
+	tracker.now = func() time.Time { return now }
+	record(tracker, "bob", "", KindReply, "m", 1, 1)
+
+	if len(tracker.entries) != 1 {
+		t.Errorf("expected old aggregates to be pruned, got %d entries", len(tracker.entries))
+	}
+}
+
+func TestWithKind(t *testing.T) {
+	ctx := WithAttribution(context.Background(), Attribution{UserID: "alice", GuildID: "g1", Kind: KindReply})
+	got := AttributionFrom(WithKind(ctx, KindEmojiFilter))
+	if got != (Attribution{UserID: "alice", GuildID: "g1", Kind: KindEmojiFilter}) {
+		t.Errorf("WithKind() = %+v", got)
+	}
+	if AttributionFrom(ctx).Kind != KindReply {
+		t.Error("WithKind() must not change the parent context")
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"ninoai/pkg/config"
+	"ninoai/pkg/embedding"
+	"ninoai/pkg/memory"
+)
+
+// runReembed implements `ninoai reembed`, which re-embeds every stored memory
+// with the current embedding model after the model has changed. It can be
+// interrupted and run again to resume.
+func runReembed(cfg *config.Config, args []string) {
+	flags := flag.NewFlagSet("reembed", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "only count the memories that would be re-embedded")
+	batchSize := flags.Int("batch-size", cfg.Embedding.BatchSize, "memories embedded per API request")
+	fileDir := flags.String("file-store", "", "re-embed the file store in this directory instead of SurrealDB")
+	flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Use the API directly, as the cache may hold vectors of the previous model
+	embeddingAPI, _ := newEmbeddingAPI(cfg)
+	dimensions, err := embedding.Dimensions(ctx, embeddingAPI, cfg.Embedding.Dimensions, 0)
+	if err != nil {
+		log.Fatalf("Failed to determine embedding dimensions: %v", err)
+	}
+
+	var store memory.Reembedder
+	if *fileDir != "" {
+		store = memory.NewFileStore(*fileDir)
+	} else {
+		surrealClient := connectSurreal(cfg)
+		defer surrealClient.Close()
+		store = memory.NewSurrealStore(surrealClient)
+	}
+
+	log.Printf("Re-embedding memories with %d-dimensional vectors", dimensions)
+	stats, err := store.Reembed(ctx, embeddingAPI, dimensions, memory.ReembedOptions{
+		BatchSize: *batchSize,
+		DryRun:    *dryRun,
+	})
+	if *dryRun {
+		if err != nil {
+			log.Fatalf("Dry run failed: %v", err)
+		}
+		log.Printf("Dry run: %d memories, %d already re-embedded, %d to go", stats.Total, stats.Done, stats.Total-stats.Done)
+		return
+	}
+	if err != nil {
+		log.Fatalf("Re-embedding stopped after %d memories, run it again to resume: %v", stats.Done+stats.Embedded, err)
+	}
+	log.Printf("Re-embedded %d memories; the bot now uses the new vectors", stats.Total)
+}